@@ -1,34 +1,181 @@
 package main
 
 import (
+    "LoadBalancer/internal/config"
     "LoadBalancer/internal/lb"
+    "LoadBalancer/internal/lbalgo"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
     "flag"
+    "fmt"
     "log"
     "os"
     "os/signal"
+    "strings"
     "syscall"
     "time"
 )
 
 func main() {
     // scanPeriod is defaulted to 10 seconds.
-    scanPeriod := flag.Int("t", 10, "scan period")
+    scanPeriod := flag.Int("t", 10, "scan period in seconds")
 
     // algoBrief is defaulted to Round-Robin.
-    algoBrief := flag.String("algo", "RR", "load balancing algorithm")
+    algoBrief := flag.String("algo", "RR", "load balancing algorithm of the default pool")
+
+    // algoParams is defaulted to empty, the algorithm uses its default parameters.
+    algoParams := flag.String("params", "", "parameters of the algorithm of the default pool in the form key=value,key=value")
+
+    // listAlgos prints the available algorithms and exits.
+    listAlgos := flag.Bool("list", false, "list the available load balancing algorithms")
+
+    // seed is defaulted to 0, which seeds randomized algorithms with the current time.
+    seed := flag.Int64("seed", 0, "seed of randomized algorithms, 0 for a random seed")
+
+    // failover is defaulted to 70 percent.
+    failover := flag.Int("failover", lb.DefaultFailoverThreshold, "percentage of healthy servers below which a priority tier spills over to the next one")
+
+    // slowStart is defaulted to 0 seconds, which disables slow-start.
+    slowStart := flag.Int("slowstart", 0, "slow-start window in seconds of servers that become healthy")
+
+    // slowStartMode is defaulted to linear.
+    slowStartMode := flag.String("slowstart-mode", model.SlowStartLinear, "slow-start ramp, linear or exponential")
+
+    // region and zone are defaulted to empty, which disables zone-aware load balancing.
+    region := flag.String("region", "", "region of the load balancer")
+    zone := flag.String("zone", "", "zone of the load balancer, servers of the same zone are preferred")
+
+    // minLocal is defaulted to 1 server.
+    minLocal := flag.Int("min-local", 1, "healthy servers a zone needs before it stops spilling traffic to other zones")
+
+    // overflow is defaulted to 50 percent.
+    overflow := flag.Int("overflow", lbalgo.DefaultOverflow, "percentage of clients spilled to other zones while the zone is short of servers")
+
+    // cookieMode is defaulted to empty, which doesn't pin clients with a cookie.
+    cookieMode := flag.String("cookie", "", "sticky cookie mode, insert or app")
+    cookieName := flag.String("cookie-name", "", "name of the sticky cookie, LBSERVER in insert mode and JSESSIONID in app mode by default")
+    cookieSecret := flag.String("cookie-secret", "", "secret signing the insert cookie, random when empty")
+
+    // admin is defaulted to empty, which serves the admin API on the client listeners.
+    admin := flag.String("admin", "", "address the admin API is served on instead of the client listeners, e.g. 127.0.0.1:9000")
+
+    // registryFile is defaulted to empty, which keeps the registrations in memory only.
+    registryFile := flag.String("registry", "", "file persisting the registrations across restarts")
+
+    // configPath is defaulted to empty, the flags above describe the whole load balancer.
+    configPath := flag.String("config", "", "configuration file, YAML or JSON, overridden by the flags given")
+
+    // watch is defaulted to false, the configuration file is only reloaded on SIGHUP.
+    watch := flag.Bool("watch", false, "reload the configuration file when it changes")
 
     flag.Parse()
 
-    srv, err := lb.New(8000, *scanPeriod, *algoBrief)
+    if *listAlgos {
+        fmt.Println(strings.Join(balancer.Algorithms(), "\n"))
+        return
+    }
+
+    // loadConfig reads the configuration file, if any, then overrides its values with the flags given on the command line.
+    loadConfig := func() (*config.Config, error) {
+        cfg := config.Default()
+        if *configPath != "" {
+            var err error
+            if cfg, err = config.Load(*configPath); err != nil {
+                return nil, err
+            }
+        }
+
+        var err error
+        flag.Visit(func(f *flag.Flag) {
+            switch f.Name {
+            case "t":
+                cfg.HealthCheck.Interval = time.Duration(*scanPeriod) * time.Second
+            case "algo":
+                cfg.Pools[0].Algorithm = *algoBrief
+            case "params":
+                cfg.Pools[0].Params, err = balancer.ParseParams(*algoParams)
+            case "seed":
+                cfg.Seed = *seed
+            case "failover":
+                cfg.Failover = *failover
+            case "slowstart":
+                cfg.SlowStart.Window = time.Duration(*slowStart) * time.Second
+            case "slowstart-mode":
+                cfg.SlowStart.Mode = *slowStartMode
+            case "region":
+                cfg.Locality.Region = *region
+            case "zone":
+                cfg.Locality.Zone = *zone
+            case "min-local":
+                cfg.Locality.MinLocal = *minLocal
+            case "overflow":
+                cfg.Locality.Overflow = *overflow
+            case "cookie":
+                cfg.Cookie.Mode = *cookieMode
+            case "cookie-name":
+                cfg.Cookie.Name = *cookieName
+            case "cookie-secret":
+                cfg.Cookie.Secret = *cookieSecret
+            case "admin":
+                cfg.AdminListeners = []config.Listener{{Address: *admin}}
+            case "registry":
+                cfg.Registry.File = *registryFile
+            }
+        })
+        if err != nil {
+            return nil, err
+        }
+        return cfg, cfg.Validate()
+    }
+
+    cfg, err := loadConfig()
+    if err != nil {
+        log.Fatal(err)
+    }
+    srv, err := lb.NewFromConfig(cfg)
     if err != nil {
         panic(err)
     }
     srv.Start()
 
+    // reload applies the configuration file again, an invalid one is rejected and the running one kept.
+    reload := func() {
+        cfg, err := loadConfig()
+        if err == nil {
+            var changes []string
+            if changes, err = srv.Reload(cfg); err == nil && len(changes) == 0 {
+                log.Println("Configuration reloaded, nothing changed.")
+                return
+            } else if err == nil {
+                log.Printf("Configuration reloaded: %s.\n", strings.Join(changes, ", "))
+                return
+            }
+        }
+        log.Printf("Configuration rejected, keeping the running one: %v\n", err)
+    }
+
+    done := make(chan struct{})
+    defer close(done)
+    var changed <-chan struct{}
+    if *watch && *configPath != "" {
+        changed = config.Watch(*configPath, time.Second, done)
+    }
+
     sigChan := make(chan os.Signal, 1)
-    signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-    // Wait for os signal to come in.
-    <-sigChan
+    signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+    // Wait for os signal to come in, SIGHUP reloads the configuration.
+    for shutdown := false; !shutdown; {
+        select {
+        case sig := <-sigChan:
+            if sig == syscall.SIGHUP {
+                reload()
+            } else {
+                shutdown = true
+            }
+        case <-changed:
+            reload()
+        }
+    }
     log.Println("Shutting down load balancer.")
 
     // Wait for 5 seconds.
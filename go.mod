@@ -1,3 +1,8 @@
 module LoadBalancer
 
 go 1.21
+
+require (
+	golang.org/x/net v0.33.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,750 @@
+package config
+
+import (
+    "LoadBalancer/internal/lbalgo"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/url"
+    "os"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Defaults of the configuration, the ones of the command line flags.
+const (
+    DefaultListener      = ":8000"
+    DefaultPool          = "default"
+    DefaultAlgorithm     = "RR"
+    DefaultScanInterval  = 10 * time.Second
+    DefaultHealthTimeout = 5 * time.Second
+    DefaultHealthPath    = "/health"
+    DefaultFailover      = 70
+    DefaultMinLocal      = 1
+)
+
+// Config describes the whole load balancer. It's read from a YAML or JSON file, JSON being a subset of YAML.
+type Config struct {
+    Listeners []Listener `yaml:"listeners"`
+    // The admin API is served on AdminListeners only, on the client listeners when there are none.
+    AdminListeners []Listener  `yaml:"admin_listeners"`
+    Pools          []Pool      `yaml:"pools"`
+    HealthCheck    HealthCheck `yaml:"health_check"`
+    Timeouts       Timeouts    `yaml:"timeouts"`
+    Failover       int         `yaml:"failover"` // Percentage of healthy members below which a priority tier spills over.
+    SlowStart      SlowStart   `yaml:"slow_start"`
+    Locality       Locality    `yaml:"locality"`
+    Cookie         Cookie      `yaml:"cookie"`
+    Seed           int64       `yaml:"seed"` // Seed of randomized algorithms, 0 for a random seed.
+    Registry       Registry    `yaml:"registry"`
+    Discovery      []Discovery `yaml:"discovery"`
+    // Requests are sent to the pool of the first route they match, else to the pool of the virtual host of their Host
+    // header, else to the first pool.
+    Routes       []Route       `yaml:"routes"`
+    VirtualHosts []VirtualHost `yaml:"virtual_hosts"`
+}
+
+// Listener is an address the load balancer accepts clients on, e.g. ":8000" or "127.0.0.1:8080".
+type Listener struct {
+    Address string `yaml:"address"`
+}
+
+// Pool is a group of backend servers load balanced by one algorithm, e.g. the servers of one service.
+type Pool struct {
+    Name        string          `yaml:"name"`
+    Algorithm   string          `yaml:"algorithm"`
+    Params      balancer.Params `yaml:"params"`
+    Backends    []Backend       `yaml:"backends"`     // Static backend servers, registered at startup.
+    HealthCheck HealthCheck     `yaml:"health_check"` // Path and timeout of the probes, the global ones when empty.
+    Cookie      Cookie          `yaml:"cookie"`       // Sticky cookie of the pool, the global one when the mode is empty.
+}
+
+// HealthCheckOf returns the health check of pool, the global one where the pool leaves it empty.
+// The scan period is the same for all pools.
+func (c *Config) HealthCheckOf(pool Pool) HealthCheck {
+    health := c.HealthCheck
+    if pool.HealthCheck.Timeout != 0 {
+        health.Timeout = pool.HealthCheck.Timeout
+    }
+    if pool.HealthCheck.Path != "" {
+        health.Path = pool.HealthCheck.Path
+    }
+    return health
+}
+
+// CookieOf returns the sticky cookie of pool, the global one when the pool has none.
+func (c *Config) CookieOf(pool Pool) Cookie {
+    if pool.Cookie.Enabled() {
+        return pool.Cookie
+    }
+    return c.Cookie
+}
+
+// Backend is a static backend server.
+type Backend struct {
+    Address  string `yaml:"address"`
+    Weight   int    `yaml:"weight"` // 1 when omitted.
+    Priority int    `yaml:"priority"`
+    Region   string `yaml:"region"`
+    Zone     string `yaml:"zone"`
+}
+
+// HealthCheck describes how backend servers are probed.
+type HealthCheck struct {
+    Interval time.Duration `yaml:"interval"` // Scan period.
+    Timeout  time.Duration `yaml:"timeout"`  // Time a probe may take, 0 for no limit.
+    Path     string        `yaml:"path"`     // Probed with a GET request, healthy on status 200.
+}
+
+// Timeouts of the connections, 0 for no limit.
+type Timeouts struct {
+    Read    time.Duration `yaml:"read"`    // Reading a client request, body included.
+    Write   time.Duration `yaml:"write"`   // Writing the response to a client.
+    Idle    time.Duration `yaml:"idle"`    // Keep-alive connection waiting for the next client request.
+    Backend time.Duration `yaml:"backend"` // Whole request to a backend server.
+}
+
+// SlowStart describes the ramp-up of servers that become healthy.
+type SlowStart struct {
+    Window time.Duration `yaml:"window"` // 0 disables slow-start.
+    Mode   string        `yaml:"mode"`   // model.SlowStartLinear or model.SlowStartExponential.
+}
+
+// Locality is the zone of the load balancer, zone-aware load balancing is on when the region or the zone is set.
+type Locality struct {
+    Region   string `yaml:"region"`
+    Zone     string `yaml:"zone"`
+    MinLocal int    `yaml:"min_local"`
+    Overflow int    `yaml:"overflow"`
+}
+
+// Enabled reports whether the load balancer prefers servers of its zone.
+func (l Locality) Enabled() bool {
+    return l.Region != "" || l.Zone != ""
+}
+
+// Cookie is the sticky cookie, clients are pinned with a cookie when the mode is set.
+type Cookie struct {
+    Mode   string `yaml:"mode"` // lbalgo.CookieInsert or lbalgo.CookieApp.
+    Name   string `yaml:"name"`
+    Secret string `yaml:"secret"`
+}
+
+// Enabled reports whether clients are pinned with a cookie.
+func (c Cookie) Enabled() bool {
+    return c.Mode != ""
+}
+
+// VirtualHost sends the requests for its hosts to a pool. A host is an exact name, e.g. api.example.com, a wildcard
+// matching the subdomains of a domain, e.g. *.example.com, or * matching any host.
+// An exact name is preferred to a wildcard, a longer wildcard to a shorter one, and any wildcard to *.
+type VirtualHost struct {
+    Hosts []string `yaml:"hosts"`
+    Pool  string   `yaml:"pool"`
+}
+
+// hostPattern matches the hosts of a virtual host: a name, possibly after "*.", or "*".
+var hostPattern = regexp.MustCompile(`^(\*|(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*)$`)
+
+// Route sends the requests it matches to a pool, possibly with another path. Routes of higher priority are tried
+// first, and routes of the same priority in the order of the file. Routes are listed as JSON by the admin API.
+type Route struct {
+    Name     string     `yaml:"name" json:"name"`
+    Priority int        `yaml:"priority" json:"priority,omitempty"`
+    Match    RouteMatch `yaml:"match" json:"match,omitempty"` // Matches any request when empty.
+    Pool     string     `yaml:"pool" json:"pool,omitempty"`
+    Split    []SplitLeg `yaml:"split" json:"split,omitempty"`     // Instead of pool, divides the requests between pools.
+    HashOn   string     `yaml:"hash_on" json:"hash_on,omitempty"` // Keeps a client on one leg: header:NAME, cookie:NAME or ip.
+    Rewrite  Rewrite    `yaml:"rewrite" json:"rewrite,omitempty"`
+}
+
+// SplitLeg is a pool that takes a percentage of the requests of a route, e.g. the canary release of a service.
+type SplitLeg struct {
+    Pool   string `yaml:"pool" json:"pool"`
+    Weight int    `yaml:"weight" json:"weight"` // Percentage of the requests, the weights of a route sum up to 100.
+    Force  Force  `yaml:"force" json:"force,omitempty"`
+}
+
+// Force sends the requests that carry one of its headers or cookies to a leg, whatever the weights.
+type Force struct {
+    Headers map[string]string `yaml:"headers" json:"headers,omitempty"` // Name: exact value, e.g. X-Canary: always.
+    Cookies map[string]string `yaml:"cookies" json:"cookies,omitempty"` // Name: exact value.
+}
+
+// Keys a split route may hash on, a header or cookie name follows the prefix.
+const (
+    HashOnIP     = "ip"
+    HashOnHeader = "header:"
+    HashOnCookie = "cookie:"
+)
+
+// RouteMatch describes the requests of a route, they must match every condition that is set.
+type RouteMatch struct {
+    PathPrefix string            `yaml:"path_prefix" json:"path_prefix,omitempty"` // e.g. /billing/.
+    PathRegex  string            `yaml:"path_regex" json:"path_regex,omitempty"`   // Unanchored, e.g. ^/users/[0-9]+$.
+    Methods    []string          `yaml:"methods" json:"methods,omitempty"`         // One of them.
+    Headers    map[string]string `yaml:"headers" json:"headers,omitempty"`         // Name: exact value.
+}
+
+// Rewrite changes the path of the requests of a route before they're sent to a backend server.
+type Rewrite struct {
+    StripPrefix bool   `yaml:"strip_prefix" json:"strip_prefix,omitempty"` // Removes the path_prefix of the route, e.g. /billing/v1/x becomes /v1/x.
+    Regex       string `yaml:"regex" json:"regex,omitempty"`               // Replaced by Replacement, which may refer to its groups as $1.
+    Replacement string `yaml:"replacement" json:"replacement,omitempty"`
+}
+
+// tokenPattern matches the HTTP methods and header names.
+var tokenPattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// Registry describes where the registrations are persisted, they're kept in memory only when the file is empty.
+type Registry struct {
+    File string `yaml:"file"`
+}
+
+// Types of discovery providers.
+const (
+    DiscoveryFile = "file"
+    DiscoveryDNS  = "dns"
+    DiscoveryHTTP = "http"
+)
+
+// DNS records resolved by a dns discovery provider.
+const (
+    DNSRecordA   = "A"   // A and AAAA records, the servers listen on a fixed port.
+    DNSRecordSRV = "SRV" // SRV records, with the port, weight and priority of every server.
+)
+
+// Discovery is a provider of backend servers besides the register API.
+type Discovery struct {
+    Type     string        `yaml:"type"`     // DiscoveryFile, DiscoveryDNS or DiscoveryHTTP.
+    Pool     string        `yaml:"pool"`     // Pool the servers are registered in, the first one when empty.
+    Interval time.Duration `yaml:"interval"` // How often the source is checked, the default of the provider when 0.
+
+    // file
+    Path string `yaml:"path"` // File listing the servers.
+
+    // dns
+    Name     string `yaml:"name"`     // Name resolved, e.g. web.example.com or _http._tcp.example.com.
+    Record   string `yaml:"record"`   // DNSRecordA, the default, or DNSRecordSRV.
+    Port     int    `yaml:"port"`     // Port of the servers of A and AAAA records.
+    Scheme   string `yaml:"scheme"`   // Scheme of the addresses of the servers, http by default. Also used by http.
+    Resolver string `yaml:"resolver"` // host:port of the DNS server, the first nameserver of /etc/resolv.conf by default.
+
+    // http
+    URL  string        `yaml:"url"`  // Catalog listing the servers.
+    Tags []string      `yaml:"tags"` // Tags a server needs to be registered, all of them.
+    Wait time.Duration `yaml:"wait"` // How long the catalog may hold a long-polling request, the default of the provider when 0.
+}
+
+// Default returns the configuration of the load balancer started without a file nor flags.
+func Default() *Config {
+    return &Config{
+        Listeners: []Listener{{Address: DefaultListener}},
+        Pools:     []Pool{{Name: DefaultPool, Algorithm: DefaultAlgorithm}},
+        HealthCheck: HealthCheck{
+            Interval: DefaultScanInterval,
+            Timeout:  DefaultHealthTimeout,
+            Path:     DefaultHealthPath,
+        },
+        Failover:  DefaultFailover,
+        SlowStart: SlowStart{Mode: model.SlowStartLinear},
+        Locality:  Locality{MinLocal: DefaultMinLocal, Overflow: lbalgo.DefaultOverflow},
+    }
+}
+
+// Error is an invalid value of the configuration at a line of the file.
+type Error struct {
+    File  string // Empty when the configuration wasn't read from a file.
+    Line  int    // 0 when the value isn't in the file, e.g. a default.
+    Field string // Path of the value, e.g. pools[0].backends[1].weight.
+    Msg   string
+}
+
+func (e *Error) Error() string {
+    var b strings.Builder
+    if e.File != "" {
+        b.WriteString(e.File + ":")
+    }
+    if e.Line > 0 {
+        b.WriteString(strconv.Itoa(e.Line) + ":")
+    }
+    if b.Len() > 0 {
+        b.WriteString(" ")
+    }
+    if e.Field != "" {
+        b.WriteString(e.Field + ": ")
+    }
+    b.WriteString(e.Msg)
+    return b.String()
+}
+
+// Load reads the configuration file at path. Values missing from the file keep their default.
+// The returned error joins one *Error per invalid value.
+func Load(path string) (*Config, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    cfg, err := Parse(data)
+    if err != nil {
+        return nil, withFile(err, path)
+    }
+    return cfg, nil
+}
+
+// withFile sets the file name of the errors joined in err.
+func withFile(err error, path string) error {
+    var errs []error
+    if joined, ok := err.(interface{ Unwrap() []error }); ok {
+        errs = joined.Unwrap()
+    } else {
+        errs = []error{err}
+    }
+    for _, err := range errs {
+        var cfgErr *Error
+        if errors.As(err, &cfgErr) {
+            cfgErr.File = path
+        }
+    }
+    return err
+}
+
+// Parse reads a YAML or JSON configuration and validates it. Unknown fields are rejected.
+func Parse(data []byte) (*Config, error) {
+    cfg := Default()
+    decoder := yaml.NewDecoder(bytes.NewReader(data))
+    decoder.KnownFields(true)
+    if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+        return nil, decodeErrors(err)
+    }
+
+    // The document once more, for the lines of the values.
+    lines := make(map[string]int)
+    var root yaml.Node
+    if err := yaml.Unmarshal(data, &root); err == nil {
+        indexLines(&root, "", lines)
+    }
+
+    cfg.setDefaults()
+    if err := cfg.validate(lines); err != nil {
+        return nil, err
+    }
+    return cfg, nil
+}
+
+// decodeLine matches the line of a decoding error of yaml, e.g. "line 3: field wieght not found in type config.Backend".
+var decodeLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// decodeErrors turns the decoding errors of yaml into errors with a line.
+func decodeErrors(err error) error {
+    var typeErr *yaml.TypeError
+    if !errors.As(err, &typeErr) {
+        msg := strings.TrimPrefix(err.Error(), "yaml: ")
+        if m := decodeLine.FindStringSubmatch(msg); m != nil {
+            line, _ := strconv.Atoi(m[1])
+            return &Error{Line: line, Msg: m[2]}
+        }
+        return &Error{Msg: msg}
+    }
+
+    errs := make([]error, 0, len(typeErr.Errors))
+    for _, msg := range typeErr.Errors {
+        if m := decodeLine.FindStringSubmatch(msg); m != nil {
+            line, _ := strconv.Atoi(m[1])
+            errs = append(errs, &Error{Line: line, Msg: m[2]})
+        } else {
+            errs = append(errs, &Error{Msg: msg})
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// indexLines records the line of every value under node, by path such as pools[0].backends[1].weight.
+func indexLines(node *yaml.Node, path string, lines map[string]int) {
+    switch node.Kind {
+    case yaml.DocumentNode:
+        for _, child := range node.Content {
+            indexLines(child, path, lines)
+        }
+        return
+    case yaml.MappingNode:
+        for i := 0; i+1 < len(node.Content); i += 2 {
+            key := node.Content[i].Value
+            if path != "" {
+                key = path + "." + key
+            }
+            lines[key] = node.Content[i].Line
+            indexLines(node.Content[i+1], key, lines)
+        }
+    case yaml.SequenceNode:
+        for i, child := range node.Content {
+            key := fmt.Sprintf("%s[%d]", path, i)
+            lines[key] = child.Line
+            indexLines(child, key, lines)
+        }
+    }
+    if _, ok := lines[path]; !ok && path != "" {
+        lines[path] = node.Line
+    }
+}
+
+// setDefaults fills in the values that are left empty in the file.
+func (c *Config) setDefaults() {
+    for i := range c.Pools {
+        pool := &c.Pools[i]
+        if pool.Name == "" && len(c.Pools) == 1 {
+            pool.Name = DefaultPool
+        }
+        if pool.Algorithm == "" {
+            pool.Algorithm = DefaultAlgorithm
+        }
+        for j := range pool.Backends {
+            if pool.Backends[j].Weight == 0 {
+                pool.Backends[j].Weight = 1
+            }
+        }
+    }
+    for i := range c.Discovery {
+        d := &c.Discovery[i]
+        if d.Type == DiscoveryDNS && d.Record == "" {
+            d.Record = DNSRecordA
+        }
+        if (d.Type == DiscoveryDNS || d.Type == DiscoveryHTTP) && d.Scheme == "" {
+            d.Scheme = "http"
+        }
+    }
+}
+
+// Validate checks the configuration, e.g. once command line flags have overridden some values.
+// The returned error joins one *Error per invalid value.
+func (c *Config) Validate() error {
+    return c.validate(nil)
+}
+
+// validate checks the configuration, lines gives the line of the values read from a file.
+func (c *Config) validate(lines map[string]int) error {
+    var errs []error
+    fail := func(field string, format string, args ...any) {
+        // The line of the value, or of the closest enclosing one.
+        line := 0
+        for path := field; path != "" && line == 0; {
+            line = lines[path]
+            if i := strings.LastIndexAny(path, ".["); i >= 0 {
+                path = path[:i]
+            } else {
+                path = ""
+            }
+        }
+        errs = append(errs, &Error{Line: line, Field: field, Msg: fmt.Sprintf(format, args...)})
+    }
+
+    if len(c.Listeners) == 0 {
+        fail("listeners", "at least one listener is required")
+    }
+    listeners := make(map[string]bool)
+    for i, listener := range c.Listeners {
+        field := fmt.Sprintf("listeners[%d].address", i)
+        if _, port, err := net.SplitHostPort(listener.Address); err != nil {
+            fail(field, "invalid address %q, expected host:port", listener.Address)
+        } else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+            fail(field, "invalid port %q", port)
+        } else if listeners[listener.Address] {
+            fail(field, "duplicate listener %q", listener.Address)
+        }
+        listeners[listener.Address] = true
+    }
+    for i, listener := range c.AdminListeners {
+        field := fmt.Sprintf("admin_listeners[%d].address", i)
+        if _, port, err := net.SplitHostPort(listener.Address); err != nil {
+            fail(field, "invalid address %q, expected host:port", listener.Address)
+        } else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+            fail(field, "invalid port %q", port)
+        } else if listeners[listener.Address] {
+            fail(field, "duplicate listener %q", listener.Address)
+        }
+        listeners[listener.Address] = true
+    }
+
+    // The first pool is the default one, requests that no route sends elsewhere go to it.
+    if len(c.Pools) == 0 {
+        fail("pools", "at least one pool is required")
+    }
+    pools := make(map[string]bool)
+    // Address: name of the pool of the backend, a server belongs to one pool only.
+    backendPools := make(map[string]string)
+    for i, pool := range c.Pools {
+        field := fmt.Sprintf("pools[%d]", i)
+        if pool.Name == "" {
+            fail(field+".name", "name is required")
+        } else if pools[pool.Name] {
+            fail(field+".name", "duplicate pool %q", pool.Name)
+        }
+        pools[pool.Name] = true
+        if _, err := lbalgo.ChooseAlgo(pool.Algorithm, pool.Params); err != nil {
+            if errors.Is(err, balancer.ErrInvalidParams) {
+                fail(field+".params", "%v", err)
+            } else {
+                fail(field+".algorithm", "unknown algorithm %q, expected one of %s", pool.Algorithm, strings.Join(balancer.Algorithms(), ", "))
+            }
+        }
+
+        backends := make(map[string]bool)
+        for j, backend := range pool.Backends {
+            field := fmt.Sprintf("pools[%d].backends[%d]", i, j)
+            if u, err := url.Parse(backend.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+                fail(field+".address", "invalid address %q, expected an http or https URL", backend.Address)
+            } else if backends[backend.Address] {
+                fail(field+".address", "duplicate backend %q", backend.Address)
+            } else if other, ok := backendPools[backend.Address]; ok {
+                fail(field+".address", "backend %q is already in pool %q", backend.Address, other)
+            }
+            backends[backend.Address] = true
+            if _, ok := backendPools[backend.Address]; !ok {
+                backendPools[backend.Address] = pool.Name
+            }
+            if backend.Weight < 0 {
+                fail(field+".weight", "invalid weight %d, expected 1 or more", backend.Weight)
+            }
+            if backend.Priority < model.PriorityPrimary || backend.Priority > model.PriorityDisasterRecovery {
+                fail(field+".priority", "invalid priority %d, expected %d to %d", backend.Priority, model.PriorityPrimary, model.PriorityDisasterRecovery)
+            }
+        }
+
+        if pool.HealthCheck.Interval != 0 {
+            fail(field+".health_check.interval", "the interval is shared by all pools, set it under health_check")
+        }
+        if pool.HealthCheck.Timeout < 0 {
+            fail(field+".health_check.timeout", "invalid timeout %s, expected 0 or more", pool.HealthCheck.Timeout)
+        }
+        if pool.HealthCheck.Path != "" && !strings.HasPrefix(pool.HealthCheck.Path, "/") {
+            fail(field+".health_check.path", "invalid path %q, expected an absolute path", pool.HealthCheck.Path)
+        }
+        if pool.Cookie.Enabled() && pool.Cookie.Mode != lbalgo.CookieInsert && pool.Cookie.Mode != lbalgo.CookieApp {
+            fail(field+".cookie.mode", "unknown mode %q, expected %s or %s", pool.Cookie.Mode, lbalgo.CookieInsert, lbalgo.CookieApp)
+        }
+    }
+
+    if c.HealthCheck.Interval <= 0 {
+        fail("health_check.interval", "invalid interval %s, expected more than 0", c.HealthCheck.Interval)
+    }
+    if c.HealthCheck.Timeout < 0 {
+        fail("health_check.timeout", "invalid timeout %s, expected 0 or more", c.HealthCheck.Timeout)
+    }
+    if !strings.HasPrefix(c.HealthCheck.Path, "/") {
+        fail("health_check.path", "invalid path %q, expected an absolute path", c.HealthCheck.Path)
+    }
+
+    timeouts := []struct {
+        field   string
+        timeout time.Duration
+    }{
+        {field: "timeouts.read", timeout: c.Timeouts.Read},
+        {field: "timeouts.write", timeout: c.Timeouts.Write},
+        {field: "timeouts.idle", timeout: c.Timeouts.Idle},
+        {field: "timeouts.backend", timeout: c.Timeouts.Backend},
+    }
+    for _, t := range timeouts {
+        if t.timeout < 0 {
+            fail(t.field, "invalid timeout %s, expected 0 or more", t.timeout)
+        }
+    }
+
+    if c.Failover < 0 || c.Failover > 100 {
+        fail("failover", "invalid percentage %d, expected 0 to 100", c.Failover)
+    }
+    if c.SlowStart.Window < 0 {
+        fail("slow_start.window", "invalid window %s, expected 0 or more", c.SlowStart.Window)
+    }
+    if c.SlowStart.Mode != model.SlowStartLinear && c.SlowStart.Mode != model.SlowStartExponential {
+        fail("slow_start.mode", "unknown mode %q, expected %s or %s", c.SlowStart.Mode, model.SlowStartLinear, model.SlowStartExponential)
+    }
+    if c.Locality.MinLocal < 0 {
+        fail("locality.min_local", "invalid number of servers %d, expected 0 or more", c.Locality.MinLocal)
+    }
+    if c.Locality.Overflow < 0 || c.Locality.Overflow > 100 {
+        fail("locality.overflow", "invalid percentage %d, expected 0 to 100", c.Locality.Overflow)
+    }
+    for i, d := range c.Discovery {
+        field := fmt.Sprintf("discovery[%d]", i)
+        switch d.Type {
+        case DiscoveryFile:
+            if d.Path == "" {
+                fail(field+".path", "path is required")
+            }
+        case DiscoveryDNS:
+            if d.Name == "" {
+                fail(field+".name", "name is required")
+            }
+            if d.Record != DNSRecordA && d.Record != DNSRecordSRV {
+                fail(field+".record", "unknown record %q, expected %s or %s", d.Record, DNSRecordA, DNSRecordSRV)
+            }
+            if d.Record == DNSRecordA && (d.Port < 1 || d.Port > 65535) {
+                fail(field+".port", "invalid port %d, expected 1 to 65535", d.Port)
+            }
+            if d.Scheme != "http" && d.Scheme != "https" {
+                fail(field+".scheme", "unknown scheme %q, expected http or https", d.Scheme)
+            }
+            if _, _, err := net.SplitHostPort(d.Resolver); d.Resolver != "" && err != nil {
+                fail(field+".resolver", "invalid resolver %q, expected host:port", d.Resolver)
+            }
+        case DiscoveryHTTP:
+            if u, err := url.Parse(d.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+                fail(field+".url", "invalid url %q, expected an http or https URL", d.URL)
+            }
+            if d.Scheme != "http" && d.Scheme != "https" {
+                fail(field+".scheme", "unknown scheme %q, expected http or https", d.Scheme)
+            }
+            if d.Wait < 0 {
+                fail(field+".wait", "invalid wait %s, expected 0 or more", d.Wait)
+            }
+        default:
+            fail(field+".type", "unknown type %q, expected %s, %s or %s", d.Type, DiscoveryFile, DiscoveryDNS, DiscoveryHTTP)
+        }
+        if d.Interval < 0 {
+            fail(field+".interval", "invalid interval %s, expected 0 or more", d.Interval)
+        }
+        if d.Pool != "" && !pools[d.Pool] {
+            fail(field+".pool", "unknown pool %q", d.Pool)
+        }
+    }
+    routes := make(map[string]bool)
+    for i, route := range c.Routes {
+        field := fmt.Sprintf("routes[%d]", i)
+        if route.Name == "" {
+            fail(field+".name", "name is required")
+        } else if routes[route.Name] {
+            fail(field+".name", "duplicate route %q", route.Name)
+        }
+        routes[route.Name] = true
+        match := route.Match
+        if match.PathPrefix != "" && !strings.HasPrefix(match.PathPrefix, "/") {
+            fail(field+".match.path_prefix", "invalid prefix %q, expected an absolute path", match.PathPrefix)
+        }
+        if _, err := regexp.Compile(match.PathRegex); err != nil {
+            fail(field+".match.path_regex", "invalid regex %q: %v", match.PathRegex, err)
+        }
+        for j, method := range match.Methods {
+            if !tokenPattern.MatchString(method) {
+                fail(fmt.Sprintf("%s.match.methods[%d]", field, j), "invalid method %q", method)
+            }
+        }
+        names := make([]string, 0, len(match.Headers))
+        for name := range match.Headers {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        for _, name := range names {
+            if !tokenPattern.MatchString(name) {
+                fail(field+".match.headers", "invalid header name %q", name)
+            }
+        }
+        if len(route.Split) > 0 {
+            validateSplit(route, field, pools, fail)
+        } else {
+            if !pools[route.Pool] {
+                fail(field+".pool", "unknown pool %q", route.Pool)
+            }
+            if route.HashOn != "" {
+                fail(field+".hash_on", "a key is used only with split")
+            }
+        }
+        if route.Rewrite.StripPrefix && match.PathPrefix == "" {
+            fail(field+".rewrite.strip_prefix", "a prefix is stripped only with match.path_prefix")
+        }
+        if route.Rewrite.StripPrefix && route.Rewrite.Regex != "" {
+            fail(field+".rewrite.regex", "a regex can't be used with strip_prefix")
+        } else if _, err := regexp.Compile(route.Rewrite.Regex); err != nil {
+            fail(field+".rewrite.regex", "invalid regex %q: %v", route.Rewrite.Regex, err)
+        } else if route.Rewrite.Regex == "" && route.Rewrite.Replacement != "" {
+            fail(field+".rewrite.replacement", "a replacement is used only with rewrite.regex")
+        }
+    }
+    hosts := make(map[string]bool)
+    for i, vhost := range c.VirtualHosts {
+        field := fmt.Sprintf("virtual_hosts[%d]", i)
+        if len(vhost.Hosts) == 0 {
+            fail(field+".hosts", "at least one host is required")
+        }
+        for j, host := range vhost.Hosts {
+            field := fmt.Sprintf("%s.hosts[%d]", field, j)
+            if !hostPattern.MatchString(strings.ToLower(host)) {
+                fail(field, "invalid host %q, expected a name, *.domain or *", host)
+            } else if hosts[strings.ToLower(host)] {
+                fail(field, "duplicate host %q", host)
+            }
+            hosts[strings.ToLower(host)] = true
+        }
+        if !pools[vhost.Pool] {
+            fail(field+".pool", "unknown pool %q", vhost.Pool)
+        }
+    }
+    if c.Cookie.Enabled() && c.Cookie.Mode != lbalgo.CookieInsert && c.Cookie.Mode != lbalgo.CookieApp {
+        fail("cookie.mode", "unknown mode %q, expected %s or %s", c.Cookie.Mode, lbalgo.CookieInsert, lbalgo.CookieApp)
+    }
+
+    return errors.Join(errs...)
+}
+
+// validateSplit checks the split of route, whose path is field.
+func validateSplit(route Route, field string, pools map[string]bool, fail func(field string, format string, args ...any)) {
+    if route.Pool != "" {
+        fail(field+".pool", "a route has either a pool or a split")
+    }
+    if len(route.Split) < 2 {
+        fail(field+".split", "at least two legs are required")
+    }
+    total := 0
+    legs := make(map[string]bool)
+    for i, leg := range route.Split {
+        field := fmt.Sprintf("%s.split[%d]", field, i)
+        if !pools[leg.Pool] {
+            fail(field+".pool", "unknown pool %q", leg.Pool)
+        } else if legs[leg.Pool] {
+            fail(field+".pool", "duplicate pool %q", leg.Pool)
+        }
+        legs[leg.Pool] = true
+        if leg.Weight < 0 || leg.Weight > 100 {
+            fail(field+".weight", "invalid weight %d, expected a percentage from 0 to 100", leg.Weight)
+        }
+        total += leg.Weight
+        for _, names := range []struct {
+            field  string
+            values map[string]string
+        }{
+            {field: field + ".force.headers", values: leg.Force.Headers},
+            {field: field + ".force.cookies", values: leg.Force.Cookies},
+        } {
+            sorted := make([]string, 0, len(names.values))
+            for name := range names.values {
+                sorted = append(sorted, name)
+            }
+            sort.Strings(sorted)
+            for _, name := range sorted {
+                if !tokenPattern.MatchString(name) {
+                    fail(names.field, "invalid name %q", name)
+                }
+            }
+        }
+    }
+    if len(route.Split) >= 2 && total != 100 {
+        fail(field+".split", "the weights sum up to %d, expected 100", total)
+    }
+
+    hashOn := route.HashOn
+    switch {
+    case hashOn == "" || hashOn == HashOnIP:
+    case strings.HasPrefix(hashOn, HashOnHeader) && tokenPattern.MatchString(strings.TrimPrefix(hashOn, HashOnHeader)):
+    case strings.HasPrefix(hashOn, HashOnCookie) && tokenPattern.MatchString(strings.TrimPrefix(hashOn, HashOnCookie)):
+    default:
+        fail(field+".hash_on", "invalid key %q, expected %s, %sNAME or %sNAME", hashOn, HashOnIP, HashOnHeader, HashOnCookie)
+    }
+}
@@ -0,0 +1,414 @@
+package config
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestParse(t *testing.T) {
+    data := `
+listeners:
+  - address: ":8080"
+  - address: "127.0.0.1:9090"
+pools:
+  - name: web
+    algorithm: pewma
+    params:
+      decay: 5s
+    backends:
+      - address: http://127.0.0.1:1080
+        weight: 5
+      - address: http://127.0.0.1:1081
+        priority: 1
+        zone: eu-west-1a
+health_check:
+  interval: 5s
+  path: /healthz
+timeouts:
+  backend: 30s
+slow_start:
+  window: 30s
+`
+    cfg, err := Parse([]byte(data))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if len(cfg.Listeners) != 2 || cfg.Listeners[1].Address != "127.0.0.1:9090" {
+        t.Errorf("error parsing listeners: expected 2 listeners, got %#v.\n", cfg.Listeners)
+    }
+    pool := cfg.Pools[0]
+    if pool.Name != "web" || pool.Algorithm != "pewma" || pool.Params["decay"] != "5s" {
+        t.Errorf("error parsing pool: got %#v.\n", pool)
+    }
+    if len(pool.Backends) != 2 || pool.Backends[0].Weight != 5 || pool.Backends[1].Weight != 1 || pool.Backends[1].Priority != 1 {
+        t.Errorf("error parsing backends: got %#v.\n", pool.Backends)
+    }
+    if cfg.HealthCheck.Interval != 5*time.Second || cfg.HealthCheck.Path != "/healthz" || cfg.HealthCheck.Timeout != DefaultHealthTimeout {
+        t.Errorf("error parsing health check: got %#v.\n", cfg.HealthCheck)
+    }
+    if cfg.Timeouts.Backend != 30*time.Second || cfg.SlowStart.Window != 30*time.Second {
+        t.Errorf("error parsing timeouts: got %#v %#v.\n", cfg.Timeouts, cfg.SlowStart)
+    }
+    // Values missing from the file keep their default.
+    if cfg.Failover != DefaultFailover || cfg.SlowStart.Mode != "linear" || cfg.Locality.MinLocal != DefaultMinLocal {
+        t.Errorf("error keeping defaults: got %#v.\n", cfg)
+    }
+}
+
+func TestParse_JSON(t *testing.T) {
+    data := `{
+  "listeners": [{"address": ":8000"}],
+  "pools": [{"algorithm": "SRR", "params": {"size": 100}, "backends": [{"address": "http://127.0.0.1:1080", "weight": 2}]}],
+  "health_check": {"interval": "1s"}
+}`
+    cfg, err := Parse([]byte(data))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if cfg.Pools[0].Name != DefaultPool || cfg.Pools[0].Params["size"] != "100" || cfg.Pools[0].Backends[0].Weight != 2 {
+        t.Errorf("error parsing JSON: got %#v.\n", cfg.Pools[0])
+    }
+}
+
+func TestParse_Empty(t *testing.T) {
+    cfg, err := Parse(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if cfg.Listeners[0].Address != DefaultListener || cfg.Pools[0].Algorithm != DefaultAlgorithm {
+        t.Errorf("error parsing empty configuration: expected the defaults, got %#v.\n", cfg)
+    }
+}
+
+func TestParse_Pools(t *testing.T) {
+    cfg, err := Parse([]byte(`
+pools:
+  - name: api
+    algorithm: LC
+    backends:
+      - address: http://127.0.0.1:1080
+    health_check:
+      path: /ready
+    cookie:
+      mode: app
+  - name: static
+    backends:
+      - address: http://127.0.0.1:1081
+health_check:
+  timeout: 2s
+cookie:
+  mode: insert
+discovery:
+  - type: file
+    path: /etc/lb/api.txt
+    pool: api
+routes:
+  - name: billing
+    priority: 10
+    match:
+      path_prefix: /billing/
+      methods: [GET, post]
+      headers:
+        X-Tenant: acme
+    pool: static
+    rewrite:
+      strip_prefix: true
+  - name: checkout
+    split:
+      - pool: api
+        weight: 95
+      - pool: static
+        weight: 5
+        force:
+          headers:
+            X-Canary: always
+    hash_on: cookie:session
+virtual_hosts:
+  - hosts: [api.example.com, "*.API.example.com"]
+    pool: api
+  - hosts: ["*"]
+    pool: static
+`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(cfg.Pools) != 2 || cfg.Pools[0].Name != "api" || cfg.Pools[1].Algorithm != DefaultAlgorithm || cfg.Discovery[0].Pool != "api" {
+        t.Fatalf("error parsing pools: got %#v.\n", cfg.Pools)
+    }
+
+    if len(cfg.VirtualHosts) != 2 || cfg.VirtualHosts[0].Hosts[1] != "*.API.example.com" || cfg.VirtualHosts[1].Pool != "static" {
+        t.Errorf("error parsing virtual hosts: got %#v.\n", cfg.VirtualHosts)
+    }
+
+    route := cfg.Routes[0]
+    if route.Priority != 10 || route.Match.PathPrefix != "/billing/" || route.Match.Headers["X-Tenant"] != "acme" || !route.Rewrite.StripPrefix {
+        t.Errorf("error parsing routes: got %#v.\n", cfg.Routes)
+    }
+    if split := cfg.Routes[1].Split; len(split) != 2 || split[1].Weight != 5 || split[1].Force.Headers["X-Canary"] != "always" || cfg.Routes[1].HashOn != "cookie:session" {
+        t.Errorf("error parsing split: got %#v.\n", cfg.Routes[1])
+    }
+
+    api, static := cfg.Pools[0], cfg.Pools[1]
+    if health := cfg.HealthCheckOf(api); health.Path != "/ready" || health.Timeout != 2*time.Second || health.Interval != DefaultScanInterval {
+        t.Errorf("error merging health check of pool %s: got %#v.\n", api.Name, health)
+    }
+    if health := cfg.HealthCheckOf(static); health != cfg.HealthCheck {
+        t.Errorf("error merging health check of pool %s: expected %#v, got %#v.\n", static.Name, cfg.HealthCheck, health)
+    }
+    if cfg.CookieOf(api).Mode != "app" || cfg.CookieOf(static).Mode != "insert" {
+        t.Errorf("error merging cookies: expected %s and %s, got %s and %s.\n", "app", "insert", cfg.CookieOf(api).Mode, cfg.CookieOf(static).Mode)
+    }
+}
+
+func TestParse_Errors(t *testing.T) {
+    testCases := []struct {
+        name     string
+        data     string
+        expected []string
+    }{
+        {
+            name:     "Unknown field",
+            data:     "pools:\n  - backends:\n      - address: http://127.0.0.1:1080\n        wieght: 5\n",
+            expected: []string{"4: field wieght not found"},
+        },
+        {
+            name:     "Wrong type",
+            data:     "failover: many\n",
+            expected: []string{"1: cannot unmarshal"},
+        },
+        {
+            name: "Invalid values",
+            data: `listeners:
+  - address: "8000"
+pools:
+  - algorithm: XYZ
+    backends:
+      - address: 127.0.0.1:1080
+        weight: -1
+      - address: http://127.0.0.1:1081
+        priority: 3
+health_check:
+  interval: 0s
+`,
+            expected: []string{
+                `2: listeners[0].address: invalid address "8000"`,
+                `4: pools[0].algorithm: unknown algorithm "XYZ"`,
+                `6: pools[0].backends[0].address: invalid address "127.0.0.1:1080"`,
+                `7: pools[0].backends[0].weight: invalid weight -1`,
+                `9: pools[0].backends[1].priority: invalid priority 3`,
+                `11: health_check.interval: invalid interval 0s`,
+            },
+        },
+        {
+            name:     "Invalid admin listeners",
+            data:     "listeners:\n  - address: \":8000\"\nadmin_listeners:\n  - address: \":8000\"\n  - address: admin\n",
+            expected: []string{`4: admin_listeners[0].address: duplicate listener ":8000"`, `5: admin_listeners[1].address: invalid address "admin"`},
+        },
+        {
+            name:     "Invalid params",
+            data:     "pools:\n  - algorithm: PEWMA\n    params:\n      size: 10\n",
+            expected: []string{`3: pools[0].params: algorithm PEWMA: error invalid parameters`},
+        },
+        {
+            name:     "Duplicate backend",
+            data:     "pools:\n  - backends:\n      - address: http://a:1\n      - address: http://a:1\n",
+            expected: []string{`4: pools[0].backends[1].address: duplicate backend`},
+        },
+        {
+            name:     "Invalid discovery",
+            data:     "discovery:\n  - type: file\n  - type: zookeeper\n    path: /x\n",
+            expected: []string{`2: discovery[0].path: path is required`, `3: discovery[1].type: unknown type "zookeeper"`},
+        },
+        {
+            name:     "Invalid DNS discovery",
+            data:     "discovery:\n  - type: dns\n    name: web.example.com\n    record: MX\n  - type: dns\n    name: web.example.com\n",
+            expected: []string{`4: discovery[0].record: unknown record "MX"`, `5: discovery[1].port: invalid port 0`},
+        },
+        {
+            name:     "Invalid HTTP discovery",
+            data:     "discovery:\n  - type: http\n    url: catalog.example.com/services/web\n    wait: -1s\n",
+            expected: []string{`3: discovery[0].url: invalid url "catalog.example.com/services/web"`, `4: discovery[0].wait: invalid wait -1s`},
+        },
+        {
+            name: "Invalid pools",
+            data: `pools:
+  - name: api
+    backends:
+      - address: http://a:1
+    health_check:
+      interval: 5s
+  - name: api
+    backends:
+      - address: http://a:1
+    cookie:
+      mode: always
+  - algorithm: RR
+discovery:
+  - type: file
+    path: /etc/lb/servers.txt
+    pool: static
+`,
+            expected: []string{
+                `6: pools[0].health_check.interval: the interval is shared by all pools`,
+                `7: pools[1].name: duplicate pool "api"`,
+                `9: pools[1].backends[0].address: backend "http://a:1" is already in pool "api"`,
+                `11: pools[1].cookie.mode: unknown mode "always"`,
+                `12: pools[2].name: name is required`,
+                `16: discovery[0].pool: unknown pool "static"`,
+            },
+        },
+        {
+            name: "Invalid virtual hosts",
+            data: `virtual_hosts:
+  - hosts: [example.com, "api.*.com", "-x.com"]
+    pool: default
+  - hosts: [EXAMPLE.com]
+    pool: api
+  - pool: default
+`,
+            expected: []string{
+                `2: virtual_hosts[0].hosts[1]: invalid host "api.*.com"`,
+                `2: virtual_hosts[0].hosts[2]: invalid host "-x.com"`,
+                `4: virtual_hosts[1].hosts[0]: duplicate host "EXAMPLE.com"`,
+                `5: virtual_hosts[1].pool: unknown pool "api"`,
+                `6: virtual_hosts[2].hosts: at least one host is required`,
+            },
+        },
+        {
+            name: "Invalid routes",
+            data: `routes:
+  - name: billing
+    match:
+      path_prefix: billing
+      path_regex: "(["
+      methods: ["GET /"]
+      headers:
+        "X Tenant": acme
+    pool: billing
+    rewrite:
+      regex: "^/x"
+      strip_prefix: true
+  - name: billing
+    pool: default
+    rewrite:
+      replacement: /y
+  - pool: default
+    rewrite:
+      strip_prefix: true
+`,
+            expected: []string{
+                `4: routes[0].match.path_prefix: invalid prefix "billing"`,
+                `5: routes[0].match.path_regex: invalid regex "(["`,
+                `6: routes[0].match.methods[0]: invalid method "GET /"`,
+                `7: routes[0].match.headers: invalid header name "X Tenant"`,
+                `9: routes[0].pool: unknown pool "billing"`,
+                `11: routes[0].rewrite.regex: a regex can't be used with strip_prefix`,
+                `13: routes[1].name: duplicate route "billing"`,
+                `16: routes[1].rewrite.replacement: a replacement is used only with rewrite.regex`,
+                `17: routes[2].name: name is required`,
+                `19: routes[2].rewrite.strip_prefix: a prefix is stripped only with match.path_prefix`,
+            },
+        },
+        {
+            name: "Invalid splits",
+            data: `routes:
+  - name: checkout
+    pool: default
+    split:
+      - pool: default
+        weight: 90
+      - pool: default
+        weight: 20
+        force:
+          cookies:
+            "canary;": "1"
+    hash_on: body
+  - name: search
+    split:
+      - pool: canary
+        weight: 100
+  - name: orders
+    pool: default
+    hash_on: ip
+`,
+            expected: []string{
+                `3: routes[0].pool: a route has either a pool or a split`,
+                `7: routes[0].split[1].pool: duplicate pool "default"`,
+                `10: routes[0].split[1].force.cookies: invalid name "canary;"`,
+                `4: routes[0].split: the weights sum up to 110, expected 100`,
+                `12: routes[0].hash_on: invalid key "body"`,
+                `14: routes[1].split: at least two legs are required`,
+                `15: routes[1].split[0].pool: unknown pool "canary"`,
+                `19: routes[2].hash_on: a key is used only with split`,
+            },
+        },
+        {
+            name:     "No pool",
+            data:     "pools: []\n",
+            expected: []string{`1: pools: at least one pool is required`},
+        },
+        {
+            name:     "Unknown cookie mode",
+            data:     "cookie:\n  mode: always\n",
+            expected: []string{`2: cookie.mode: unknown mode "always"`},
+        },
+    }
+
+    for _, tc := range testCases {
+        t.Run(tc.name, func(t *testing.T) {
+            _, err := Parse([]byte(tc.data))
+            if err == nil {
+                t.Fatalf("error parsing invalid configuration: expected an error, got nil.\n")
+            }
+            var cfgErr *Error
+            if !errors.As(err, &cfgErr) {
+                t.Errorf("error incorrect error: expected an *Error, got %#v.\n", err)
+            }
+            msgs := strings.Split(err.Error(), "\n")
+            if len(msgs) != len(tc.expected) {
+                t.Fatalf("error incorrect errors: expected %d errors, got %q.\n", len(tc.expected), msgs)
+            }
+            for i, expected := range tc.expected {
+                if !strings.HasPrefix(msgs[i], expected) {
+                    t.Errorf("error incorrect error: expected %s, got %s.\n", expected, msgs[i])
+                }
+            }
+        })
+    }
+}
+
+func TestLoad(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "lb.yaml")
+    if err := os.WriteFile(path, []byte("failover: 50\nseed: x\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+
+    _, err := Load(path)
+    expected := path + ":2: "
+    if err == nil || !strings.HasPrefix(err.Error(), expected) {
+        t.Errorf("error loading invalid configuration: expected %s, got %v.\n", expected, err)
+    }
+
+    if _, err = Load(filepath.Join(t.TempDir(), "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+        t.Errorf("error loading missing configuration: expected %v, got %v.\n", os.ErrNotExist, err)
+    }
+}
+
+func TestConfig_Validate(t *testing.T) {
+    cfg := Default()
+    if err := cfg.Validate(); err != nil {
+        t.Fatalf("error validating defaults: expected nil, got %v.\n", err)
+    }
+
+    // Values overridden by flags have no line.
+    cfg.SlowStart.Mode = "steep"
+    expected := `slow_start.mode: unknown mode "steep", expected linear or exponential`
+    if err := cfg.Validate(); err == nil || err.Error() != expected {
+        t.Errorf("error validating configuration: expected %s, got %v.\n", expected, err)
+    }
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+    "crypto/sha256"
+    "os"
+    "time"
+)
+
+// Watch checks the file at path every period and signals on the returned channel when its content changed, until done
+// is closed. Signals aren't queued, a change seen while the previous one wasn't received yet is merged into it.
+// A file that can't be read, e.g. while an editor replaces it, counts as unchanged.
+func Watch(path string, period time.Duration, done <-chan struct{}) <-chan struct{} {
+    changed := make(chan struct{}, 1)
+    last, _ := checksum(path)
+
+    go func() {
+        ticker := time.NewTicker(period)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-done:
+                return
+            case <-ticker.C:
+                sum, err := checksum(path)
+                if err != nil || sum == last {
+                    continue
+                }
+                last = sum
+                select {
+                case changed <- struct{}{}:
+                default:
+                }
+            }
+        }
+    }()
+    return changed
+}
+
+// checksum returns the SHA-256 of the content of the file at path.
+func checksum(path string) ([sha256.Size]byte, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return [sha256.Size]byte{}, err
+    }
+    return sha256.Sum256(data), nil
+}
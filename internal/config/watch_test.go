@@ -0,0 +1,37 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestWatch(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "lb.yaml")
+    if err := os.WriteFile(path, []byte("failover: 50\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    done := make(chan struct{})
+    defer close(done)
+    changed := Watch(path, 10*time.Millisecond, done)
+
+    // Writing the same content isn't a change.
+    if err := os.WriteFile(path, []byte("failover: 50\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    select {
+    case <-changed:
+        t.Errorf("error watching file: expected no change for the same content.\n")
+    case <-time.After(50 * time.Millisecond):
+    }
+
+    if err := os.WriteFile(path, []byte("failover: 60\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    select {
+    case <-changed:
+    case <-time.After(time.Second):
+        t.Errorf("error watching file: expected a change to be signaled.\n")
+    }
+}
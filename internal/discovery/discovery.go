@@ -0,0 +1,47 @@
+package discovery
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/pkg/model"
+    "errors"
+    "fmt"
+)
+
+var ErrUnknownProvider = errors.New("error unknown discovery provider")
+
+// Update is the whole set of servers a provider currently knows of, or the error that kept it from finding them.
+type Update struct {
+    Servers []*model.BEServer
+    Err     error // The set of servers is left as is on error.
+    Drain   bool  // Servers missing from the update are drained before they're deregistered.
+}
+
+// Provider discovers backend servers, e.g. from a file, DNS records or a service catalog.
+type Provider interface {
+    // Name identifies the provider, e.g. file:/etc/lb/servers.txt.
+    Name() string
+    // Run sends an update at start, then one every time the set of servers changes, until done is closed.
+    Run(done <-chan struct{}, updates chan<- Update)
+}
+
+// send sends u on updates unless done is closed first.
+func send(done <-chan struct{}, updates chan<- Update, u Update) {
+    select {
+    case updates <- u:
+    case <-done:
+    }
+}
+
+// New creates the provider described by cfg.
+func New(cfg config.Discovery) (Provider, error) {
+    switch cfg.Type {
+    case config.DiscoveryFile:
+        return NewFile(cfg.Path, cfg.Interval), nil
+    case config.DiscoveryDNS:
+        return NewDNS(cfg), nil
+    case config.DiscoveryHTTP:
+        return NewHTTP(cfg), nil
+    default:
+        return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, cfg.Type)
+    }
+}
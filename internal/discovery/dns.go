@@ -0,0 +1,347 @@
+package discovery
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/pkg/model"
+    "bufio"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "math/rand"
+    "net"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "golang.org/x/net/dns/dnsmessage"
+)
+
+var ErrDNS = errors.New("error DNS query failed")
+
+// Defaults of the DNS provider.
+const (
+    DefaultDNSInterval = 30 * time.Second
+    DefaultDNSTimeout  = 2 * time.Second
+    DefaultResolver    = "127.0.0.1:53"
+)
+
+// DNS discovers the servers behind a DNS name, from its A and AAAA records or from its SRV records.
+// The records are resolved again when their TTL runs out. Servers whose records disappear are drained by the load
+// balancer before they're deregistered, as they may still be serving for a while. A name without any record, or that
+// doesn't exist, fails the update rather than removing every server, as it's more likely a zone mistake.
+type DNS struct {
+    Domain   string        // Name resolved, e.g. web.example.com or _http._tcp.example.com.
+    Record   string        // config.DNSRecordA or config.DNSRecordSRV.
+    Port     int           // Port of the servers of A and AAAA records.
+    Scheme   string        // Scheme of the addresses of the servers.
+    Resolver string        // host:port of the DNS server.
+    Interval time.Duration // Refresh period of records without TTL, and after a failure.
+    Timeout  time.Duration // Time a query may take.
+}
+
+// NewDNS creates a DNS provider described by cfg.
+func NewDNS(cfg config.Discovery) *DNS {
+    d := &DNS{
+        Domain:   cfg.Name,
+        Record:   cfg.Record,
+        Port:     cfg.Port,
+        Scheme:   cfg.Scheme,
+        Resolver: cfg.Resolver,
+        Interval: cfg.Interval,
+        Timeout:  DefaultDNSTimeout,
+    }
+    if d.Record == "" {
+        d.Record = config.DNSRecordA
+    }
+    if d.Scheme == "" {
+        d.Scheme = "http"
+    }
+    if d.Resolver == "" {
+        d.Resolver = systemResolver()
+    }
+    if d.Interval <= 0 {
+        d.Interval = DefaultDNSInterval
+    }
+    return d
+}
+
+// systemResolver returns the first nameserver of /etc/resolv.conf, DefaultResolver when there's none.
+func systemResolver() string {
+    f, err := os.Open("/etc/resolv.conf")
+    if err != nil {
+        return DefaultResolver
+    }
+    defer f.Close()
+
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        fields := strings.Fields(scanner.Text())
+        if len(fields) >= 2 && fields[0] == "nameserver" {
+            return net.JoinHostPort(fields[1], "53")
+        }
+    }
+    return DefaultResolver
+}
+
+func (d *DNS) Name() string {
+    return "dns:" + d.Domain
+}
+
+// Run resolves the records at start, then every time their TTL runs out.
+func (d *DNS) Run(done <-chan struct{}, updates chan<- Update) {
+    for {
+        servers, ttl, err := d.resolve()
+        send(done, updates, Update{Servers: servers, Err: err, Drain: true})
+
+        refresh := d.Interval
+        if err == nil && ttl > 0 {
+            refresh = ttl
+        }
+        timer := time.NewTimer(refresh)
+        select {
+        case <-done:
+            timer.Stop()
+            return
+        case <-timer.C:
+        }
+    }
+}
+
+// resolve returns the servers behind the name, and the lowest TTL of the records they come from.
+func (d *DNS) resolve() ([]*model.BEServer, time.Duration, error) {
+    var servers []*model.BEServer
+    var ttl time.Duration
+    if d.Record == config.DNSRecordSRV {
+        var err error
+        if servers, ttl, err = d.resolveSRV(); err != nil {
+            return nil, 0, err
+        }
+    } else {
+        ips, ipTTL, err := d.lookupIP(d.Domain, nil)
+        if err != nil {
+            return nil, 0, err
+        }
+        for _, ip := range ips {
+            servers = append(servers, model.NewBEServer(d.address(ip, d.Port), 1))
+        }
+        ttl = ipTTL
+    }
+    if len(servers) == 0 {
+        return nil, 0, fmt.Errorf("%w: %s has no %s records", ErrDNS, d.Domain, d.Record)
+    }
+
+    sort.Slice(servers, func(i, j int) bool {
+        return servers[i].Address < servers[j].Address
+    })
+    return servers, ttl, nil
+}
+
+// resolveSRV returns the servers of the SRV records of the name. The SRV weight is the weight of the server, and the
+// distinct SRV priorities, from the lowest value, are the primary, secondary and disaster recovery tiers.
+func (d *DNS) resolveSRV() ([]*model.BEServer, time.Duration, error) {
+    answers, additionals, err := d.query(d.Domain, dnsmessage.TypeSRV)
+    if err != nil {
+        return nil, 0, err
+    }
+
+    var records []*dnsmessage.SRVResource
+    ttl := time.Duration(0)
+    for _, answer := range answers {
+        if srv, ok := answer.Body.(*dnsmessage.SRVResource); ok {
+            records = append(records, srv)
+            ttl = minTTL(ttl, answer.Header.TTL)
+        }
+    }
+
+    priorities := make([]int, 0, len(records))
+    for _, record := range records {
+        priorities = append(priorities, int(record.Priority))
+    }
+    sort.Ints(priorities)
+    tiers := make(map[int]int)
+    for _, priority := range priorities {
+        if _, ok := tiers[priority]; !ok {
+            tiers[priority] = min(len(tiers), model.PriorityDisasterRecovery)
+        }
+    }
+
+    var servers []*model.BEServer
+    for _, record := range records {
+        target := record.Target.String()
+        ips, ipTTL, err := d.lookupIP(target, additionals)
+        if err != nil {
+            return nil, 0, err
+        }
+        ttl = minDuration(ttl, ipTTL)
+
+        weight := int(record.Weight)
+        if weight == 0 {
+            weight = 1
+        }
+        for _, ip := range ips {
+            srv := model.NewBEServer(d.address(ip, int(record.Port)), weight)
+            srv.Priority = tiers[int(record.Priority)]
+            servers = append(servers, srv)
+        }
+    }
+    return servers, ttl, nil
+}
+
+// lookupIP returns the IPv4 and IPv6 addresses of name, from the additional records of an SRV response when they
+// have them, and the lowest TTL of their records. The query of a family may fail as long as the other one returns
+// addresses, as some resolvers fail AAAA queries.
+func (d *DNS) lookupIP(name string, additionals []dnsmessage.Resource) ([]net.IP, time.Duration, error) {
+    ips, ttl := ipRecords(name, additionals)
+    if len(ips) > 0 {
+        return ips, ttl, nil
+    }
+
+    var failure error
+    for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+        answers, _, err := d.query(name, qtype)
+        if err != nil {
+            failure = err
+            continue
+        }
+        found, foundTTL := ipRecords(name, answers)
+        ips = append(ips, found...)
+        ttl = minDuration(ttl, foundTTL)
+    }
+    if len(ips) == 0 && failure != nil {
+        return nil, 0, failure
+    }
+    return ips, ttl, nil
+}
+
+// ipRecords returns the addresses of the A and AAAA records among resources, and their lowest TTL.
+// Records of other names are skipped, except for the targets of CNAME records of name.
+func ipRecords(name string, resources []dnsmessage.Resource) ([]net.IP, time.Duration) {
+    names := map[string]bool{canonical(name): true}
+    var ips []net.IP
+    ttl := time.Duration(0)
+    for _, r := range resources {
+        if !names[canonical(r.Header.Name.String())] {
+            continue
+        }
+        switch body := r.Body.(type) {
+        case *dnsmessage.CNAMEResource:
+            names[canonical(body.CNAME.String())] = true
+        case *dnsmessage.AResource:
+            ips = append(ips, net.IP(body.A[:]))
+            ttl = minTTL(ttl, r.Header.TTL)
+        case *dnsmessage.AAAAResource:
+            ips = append(ips, net.IP(body.AAAA[:]))
+            ttl = minTTL(ttl, r.Header.TTL)
+        }
+    }
+    return ips, ttl
+}
+
+// address returns the address of the server at ip and port.
+func (d *DNS) address(ip net.IP, port int) string {
+    return d.Scheme + "://" + net.JoinHostPort(ip.String(), strconv.Itoa(port))
+}
+
+// query sends a query of type qtype for name to the resolver, over UDP, then over TCP when the response is truncated.
+// It returns the answers and the additional records. A name that doesn't exist is an error.
+func (d *DNS) query(name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, []dnsmessage.Resource, error) {
+    qname, err := dnsmessage.NewName(canonical(name))
+    if err != nil {
+        return nil, nil, fmt.Errorf("%w: %s: %v", ErrDNS, name, err)
+    }
+    query := dnsmessage.Message{
+        Header:    dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: true},
+        Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+    }
+    packed, err := query.Pack()
+    if err != nil {
+        return nil, nil, fmt.Errorf("%w: %s: %v", ErrDNS, name, err)
+    }
+
+    resp, err := d.exchange("udp", packed, query.Header.ID)
+    if err == nil && resp.Header.Truncated {
+        resp, err = d.exchange("tcp", packed, query.Header.ID)
+    }
+    if err != nil {
+        return nil, nil, fmt.Errorf("%w: %s %s: %v", ErrDNS, name, qtype, err)
+    }
+
+    switch resp.Header.RCode {
+    case dnsmessage.RCodeSuccess:
+        return resp.Answers, resp.Additionals, nil
+    case dnsmessage.RCodeNameError:
+        return nil, nil, fmt.Errorf("%w: %s %s: no such name", ErrDNS, name, qtype)
+    default:
+        return nil, nil, fmt.Errorf("%w: %s %s: %s", ErrDNS, name, qtype, resp.Header.RCode)
+    }
+}
+
+// exchange sends the packed query over network and returns the response to it.
+func (d *DNS) exchange(network string, packed []byte, id uint16) (*dnsmessage.Message, error) {
+    conn, err := net.DialTimeout(network, d.Resolver, d.Timeout)
+    if err != nil {
+        return nil, err
+    }
+    defer conn.Close()
+    if err = conn.SetDeadline(time.Now().Add(d.Timeout)); err != nil {
+        return nil, err
+    }
+
+    buf := make([]byte, 65535)
+    var n int
+    if network == "tcp" {
+        // Over TCP, messages are prefixed with their length.
+        msg := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+        if _, err = conn.Write(append(msg, packed...)); err != nil {
+            return nil, err
+        }
+        if _, err = io.ReadFull(conn, buf[:2]); err != nil {
+            return nil, err
+        }
+        n = int(binary.BigEndian.Uint16(buf[:2]))
+        if _, err = io.ReadFull(conn, buf[:n]); err != nil {
+            return nil, err
+        }
+    } else {
+        if _, err = conn.Write(packed); err != nil {
+            return nil, err
+        }
+        if n, err = conn.Read(buf); err != nil {
+            return nil, err
+        }
+    }
+
+    var resp dnsmessage.Message
+    if err = resp.Unpack(buf[:n]); err != nil {
+        return nil, err
+    }
+    if resp.Header.ID != id || !resp.Header.Response {
+        return nil, errors.New("response doesn't match the query")
+    }
+    return &resp, nil
+}
+
+// canonical returns name fully qualified and in lower case.
+func canonical(name string) string {
+    name = strings.ToLower(name)
+    if !strings.HasSuffix(name, ".") {
+        name += "."
+    }
+    return name
+}
+
+// minTTL returns the lower of d and a TTL in seconds, d being unset when it's 0.
+func minTTL(d time.Duration, ttl uint32) time.Duration {
+    return minDuration(d, time.Duration(ttl)*time.Second)
+}
+
+// minDuration returns the lower of a and b, a value being unset when it's 0.
+func minDuration(a, b time.Duration) time.Duration {
+    if a == 0 || (b > 0 && b < a) {
+        return b
+    }
+    return a
+}
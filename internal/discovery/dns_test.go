@@ -0,0 +1,236 @@
+package discovery
+
+import (
+    "LoadBalancer/internal/config"
+    "errors"
+    "fmt"
+    "net"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsStandIn is an in-process DNS server answering from records it's given.
+type dnsStandIn struct {
+    sync.Mutex
+    conn    net.PacketConn
+    records map[dnsmessage.Question][]dnsmessage.Resource
+    rcodes  map[dnsmessage.Question]dnsmessage.RCode
+}
+
+func newDNSStandIn(t *testing.T) *dnsStandIn {
+    t.Helper()
+
+    conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    s := &dnsStandIn{conn: conn, records: make(map[dnsmessage.Question][]dnsmessage.Resource),
+        rcodes: make(map[dnsmessage.Question]dnsmessage.RCode)}
+    t.Cleanup(func() { _ = conn.Close() })
+    go s.serve()
+    return s
+}
+
+// set replaces the records of name and type with bodies, with a TTL in seconds.
+func (s *dnsStandIn) set(name string, ttl uint32, bodies ...dnsmessage.ResourceBody) {
+    s.Lock()
+    defer s.Unlock()
+
+    question := dnsmessage.Question{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET}
+    var resources []dnsmessage.Resource
+    for _, body := range bodies {
+        question.Type = resourceType(body)
+        resources = append(resources, dnsmessage.Resource{
+            Header: dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: dnsmessage.ClassINET, TTL: ttl},
+            Body:   body,
+        })
+    }
+    s.records[question] = resources
+}
+
+// clear removes the records of name and type.
+func (s *dnsStandIn) clear(name string, qtype dnsmessage.Type) {
+    s.Lock()
+    defer s.Unlock()
+    delete(s.records, dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: qtype, Class: dnsmessage.ClassINET})
+}
+
+// fail answers the queries of name and type with rcode.
+func (s *dnsStandIn) fail(name string, qtype dnsmessage.Type, rcode dnsmessage.RCode) {
+    s.Lock()
+    defer s.Unlock()
+    s.rcodes[dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: qtype, Class: dnsmessage.ClassINET}] = rcode
+}
+
+func resourceType(body dnsmessage.ResourceBody) dnsmessage.Type {
+    switch body.(type) {
+    case *dnsmessage.AResource:
+        return dnsmessage.TypeA
+    case *dnsmessage.AAAAResource:
+        return dnsmessage.TypeAAAA
+    default:
+        return dnsmessage.TypeSRV
+    }
+}
+
+func (s *dnsStandIn) serve() {
+    buf := make([]byte, 512)
+    for {
+        n, addr, err := s.conn.ReadFrom(buf)
+        if err != nil {
+            return
+        }
+        var query dnsmessage.Message
+        if err = query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+            continue
+        }
+
+        s.Lock()
+        answers := s.records[query.Questions[0]]
+        rcode := s.rcodes[query.Questions[0]]
+        s.Unlock()
+        resp := dnsmessage.Message{
+            Header:    dnsmessage.Header{ID: query.Header.ID, Response: true, RCode: rcode},
+            Questions: query.Questions,
+            Answers:   answers,
+        }
+        packed, err := resp.Pack()
+        if err != nil {
+            continue
+        }
+        _, _ = s.conn.WriteTo(packed, addr)
+    }
+}
+
+func TestDNS_ResolveA(t *testing.T) {
+    standIn := newDNSStandIn(t)
+    standIn.set("web.example.com.", 60, &dnsmessage.AResource{A: [4]byte{10, 0, 0, 2}}, &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}})
+    standIn.set("web.example.com.", 30, &dnsmessage.AAAAResource{AAAA: [16]byte{15: 1}})
+
+    d := NewDNS(config.Discovery{Type: config.DiscoveryDNS, Name: "web.example.com", Port: 8080, Resolver: standIn.conn.LocalAddr().String()})
+    servers, ttl, err := d.resolve()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    expected := []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://[::1]:8080"}
+    got := make([]string, 0, len(servers))
+    for _, srv := range servers {
+        got = append(got, srv.Address)
+    }
+    if strings.Join(got, ",") != strings.Join(expected, ",") {
+        t.Errorf("error resolving A records: expected %v, got %v.\n", expected, got)
+    }
+    if ttl != 30*time.Second {
+        t.Errorf("error resolving A records: expected TTL %s, got %s.\n", 30*time.Second, ttl)
+    }
+}
+
+func TestDNS_ResolveSRV(t *testing.T) {
+    standIn := newDNSStandIn(t)
+    standIn.set("_http._tcp.example.com.", 10,
+        &dnsmessage.SRVResource{Priority: 10, Weight: 5, Port: 8080, Target: dnsmessage.MustNewName("a.example.com.")},
+        &dnsmessage.SRVResource{Priority: 20, Weight: 0, Port: 8081, Target: dnsmessage.MustNewName("b.example.com.")},
+    )
+    standIn.set("a.example.com.", 60, &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}})
+    standIn.set("b.example.com.", 60, &dnsmessage.AResource{A: [4]byte{10, 0, 0, 2}})
+
+    d := NewDNS(config.Discovery{Type: config.DiscoveryDNS, Name: "_http._tcp.example.com", Record: config.DNSRecordSRV, Resolver: standIn.conn.LocalAddr().String()})
+    servers, ttl, err := d.resolve()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    expected := []string{"http://10.0.0.1:8080 weight 5 priority 0", "http://10.0.0.2:8081 weight 1 priority 1"}
+    got := make([]string, 0, len(servers))
+    for _, srv := range servers {
+        got = append(got, fmt.Sprintf("%s weight %d priority %d", srv.Address, srv.Weight, srv.Priority))
+    }
+    if strings.Join(got, ",") != strings.Join(expected, ",") {
+        t.Errorf("error resolving SRV records: expected %v, got %v.\n", expected, got)
+    }
+    if ttl != 10*time.Second {
+        t.Errorf("error resolving SRV records: expected TTL %s, got %s.\n", 10*time.Second, ttl)
+    }
+}
+
+func TestDNS_Run(t *testing.T) {
+    standIn := newDNSStandIn(t)
+    standIn.set("web.example.com.", 0, &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}})
+
+    d := NewDNS(config.Discovery{Type: config.DiscoveryDNS, Name: "web.example.com", Port: 80, Resolver: standIn.conn.LocalAddr().String()})
+    // Records without TTL are resolved again every interval.
+    d.Interval = 10 * time.Millisecond
+    done := make(chan struct{})
+    defer close(done)
+    updates := make(chan Update)
+    go d.Run(done, updates)
+
+    u := <-updates
+    if u.Err != nil || len(u.Servers) != 1 || !u.Drain {
+        t.Fatalf("error running DNS provider: expected 1 server to drain when it vanishes, got %#v.\n", u)
+    }
+
+    // The records disappear: the update fails, so that the servers are left as they are.
+    standIn.clear("web.example.com.", dnsmessage.TypeA)
+    deadline := time.After(time.Second)
+    for u.Err == nil {
+        if len(u.Servers) != 1 {
+            t.Fatalf("error running DNS provider: expected the server to stay, got %#v.\n", u)
+        }
+        select {
+        case u = <-updates:
+        case <-deadline:
+            t.Fatalf("error running DNS provider: expected the update to fail.\n")
+        }
+    }
+    if !errors.Is(u.Err, ErrDNS) || len(u.Servers) != 0 {
+        t.Errorf("error running DNS provider: expected %#v and no servers, got %#v.\n", ErrDNS, u)
+    }
+}
+
+func TestDNS_ResolveFamilies(t *testing.T) {
+    standIn := newDNSStandIn(t)
+    d := NewDNS(config.Discovery{Type: config.DiscoveryDNS, Name: "web.example.com", Port: 80, Resolver: standIn.conn.LocalAddr().String()})
+
+    // The AAAA query fails, the A query is enough.
+    standIn.set("web.example.com.", 60, &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}})
+    standIn.fail("web.example.com.", dnsmessage.TypeAAAA, dnsmessage.RCodeServerFailure)
+    servers, _, err := d.resolve()
+    if err != nil || len(servers) != 1 || servers[0].Address != "http://10.0.0.1:80" {
+        t.Fatalf("error resolving with a failed AAAA query: expected %s, got %v %v.\n", "http://10.0.0.1:80", servers, err)
+    }
+
+    // Both fail.
+    standIn.fail("web.example.com.", dnsmessage.TypeA, dnsmessage.RCodeServerFailure)
+    if _, _, err = d.resolve(); !errors.Is(err, ErrDNS) {
+        t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrDNS, err)
+    }
+
+    // The name doesn't exist.
+    standIn.fail("web.example.com.", dnsmessage.TypeA, dnsmessage.RCodeNameError)
+    standIn.fail("web.example.com.", dnsmessage.TypeAAAA, dnsmessage.RCodeNameError)
+    if _, _, err = d.resolve(); !errors.Is(err, ErrDNS) {
+        t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrDNS, err)
+    }
+}
+
+func TestDNS_ResolveError(t *testing.T) {
+    // Nothing listens on the resolver address.
+    conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    resolver := conn.LocalAddr().String()
+    _ = conn.Close()
+
+    d := NewDNS(config.Discovery{Type: config.DiscoveryDNS, Name: "web.example.com", Port: 80, Resolver: resolver})
+    d.Timeout = 100 * time.Millisecond
+    if _, _, err = d.resolve(); !errors.Is(err, ErrDNS) {
+        t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrDNS, err)
+    }
+}
@@ -0,0 +1,203 @@
+package discovery
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/pkg/model"
+    "bufio"
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "net/url"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
+)
+
+// DefaultFilePeriod is how often the file of a File provider is checked for changes.
+const DefaultFilePeriod = time.Second
+
+// File discovers the servers listed in a file written by config management.
+// Files ending in .json, .yaml or .yml hold a list of servers with an address, and optionally a weight, a priority, a
+// region and a zone. Any other file holds one server per line, e.g. "http://127.0.0.1:1080 5": an address and an
+// optional weight, with comments starting with #.
+type File struct {
+    Path   string
+    Period time.Duration
+}
+
+// NewFile creates a File provider that checks the file at path every period.
+func NewFile(path string, period time.Duration) *File {
+    if period <= 0 {
+        period = DefaultFilePeriod
+    }
+    return &File{Path: path, Period: period}
+}
+
+func (f *File) Name() string {
+    return "file:" + f.Path
+}
+
+// Run sends the servers of the file at start and whenever the content of the file changes.
+func (f *File) Run(done <-chan struct{}, updates chan<- Update) {
+    // Watch first, so that no change made while the file is read at start is missed.
+    changed := config.Watch(f.Path, f.Period, done)
+    for {
+        servers, err := f.read()
+        send(done, updates, Update{Servers: servers, Err: err})
+
+        select {
+        case <-done:
+            return
+        case <-changed:
+        }
+    }
+}
+
+// read reads and parses the file.
+func (f *File) read() ([]*model.BEServer, error) {
+    data, err := os.ReadFile(f.Path)
+    if err != nil {
+        return nil, err
+    }
+
+    var servers []*model.BEServer
+    switch strings.ToLower(filepath.Ext(f.Path)) {
+    case ".json", ".yaml", ".yml":
+        servers, err = parseList(data)
+    default:
+        servers, err = parseLines(data)
+    }
+    var lineErr *lineError
+    if errors.As(err, &lineErr) {
+        return nil, fmt.Errorf("%s:%d: %w", f.Path, lineErr.line, lineErr.err)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", f.Path, err)
+    }
+
+    seen := make(map[string]bool, len(servers))
+    for _, srv := range servers {
+        if seen[srv.Address] {
+            return nil, fmt.Errorf("%s: duplicate server %q", f.Path, srv.Address)
+        }
+        seen[srv.Address] = true
+    }
+    return servers, nil
+}
+
+// parseList parses a YAML or JSON list of servers.
+func parseList(data []byte) ([]*model.BEServer, error) {
+    var backends []config.Backend
+    decoder := yaml.NewDecoder(bytes.NewReader(data))
+    decoder.KnownFields(true)
+    if err := decoder.Decode(&backends); err != nil && !errors.Is(err, io.EOF) {
+        return nil, decodeError(err)
+    }
+
+    // Each server once more, for its line.
+    var root yaml.Node
+    _ = yaml.Unmarshal(data, &root)
+    line := func(i int) int {
+        if len(root.Content) == 1 && i < len(root.Content[0].Content) {
+            return root.Content[0].Content[i].Line
+        }
+        return 0
+    }
+
+    servers := make([]*model.BEServer, 0, len(backends))
+    for i, backend := range backends {
+        if backend.Weight == 0 {
+            backend.Weight = 1
+        }
+        srv, err := newServer(backend.Address, backend.Weight)
+        if err == nil && (backend.Priority < model.PriorityPrimary || backend.Priority > model.PriorityDisasterRecovery) {
+            err = fmt.Errorf("invalid priority %d, expected %d to %d", backend.Priority, model.PriorityPrimary, model.PriorityDisasterRecovery)
+        }
+        if err != nil {
+            return nil, &lineError{line: line(i), err: err}
+        }
+        srv.Priority = backend.Priority
+        srv.Region = backend.Region
+        srv.Zone = backend.Zone
+        servers = append(servers, srv)
+    }
+    return servers, nil
+}
+
+// decodeLine matches the line of a decoding error of yaml, e.g. "line 3: field wieght not found in type config.Backend".
+var decodeLine = regexp.MustCompile(`line (\d+): (.*)$`)
+
+// lineError is an error at a line of the file.
+type lineError struct {
+    line int
+    err  error
+}
+
+func (e *lineError) Error() string {
+    return fmt.Sprintf("%d: %v", e.line, e.err)
+}
+
+func (e *lineError) Unwrap() error {
+    return e.err
+}
+
+// decodeError turns the first decoding error of yaml into an error, a lineError when yaml tells its line.
+func decodeError(err error) error {
+    msg := strings.TrimPrefix(err.Error(), "yaml: ")
+    var typeErr *yaml.TypeError
+    if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+        msg = typeErr.Errors[0]
+    }
+    if m := decodeLine.FindStringSubmatch(msg); m != nil {
+        line, _ := strconv.Atoi(m[1])
+        return &lineError{line: line, err: errors.New(m[2])}
+    }
+    return errors.New(msg)
+}
+
+// parseLines parses lines of an address and an optional weight.
+func parseLines(data []byte) ([]*model.BEServer, error) {
+    var servers []*model.BEServer
+    scanner := bufio.NewScanner(bytes.NewReader(data))
+    for n := 1; scanner.Scan(); n++ {
+        line, _, _ := strings.Cut(scanner.Text(), "#")
+        fields := strings.Fields(line)
+        if len(fields) == 0 {
+            continue
+        }
+        if len(fields) > 2 {
+            return nil, &lineError{line: n, err: fmt.Errorf("expected an address and an optional weight, got %q", strings.TrimSpace(line))}
+        }
+
+        weight := 1
+        if len(fields) == 2 {
+            var err error
+            if weight, err = strconv.Atoi(fields[1]); err != nil {
+                return nil, &lineError{line: n, err: fmt.Errorf("invalid weight %q", fields[1])}
+            }
+        }
+        srv, err := newServer(fields[0], weight)
+        if err != nil {
+            return nil, &lineError{line: n, err: err}
+        }
+        servers = append(servers, srv)
+    }
+    return servers, scanner.Err()
+}
+
+// newServer checks the address and the weight of a discovered server.
+func newServer(address string, weight int) (*model.BEServer, error) {
+    if u, err := url.Parse(address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return nil, fmt.Errorf("invalid address %q, expected an http or https URL", address)
+    }
+    if weight < 1 {
+        return nil, fmt.Errorf("invalid weight %d, expected 1 or more", weight)
+    }
+    return model.NewBEServer(address, weight), nil
+}
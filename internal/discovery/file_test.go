@@ -0,0 +1,145 @@
+package discovery
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestFile_Read(t *testing.T) {
+    testCases := []struct {
+        name     string
+        file     string
+        data     string
+        expected []string // Address and weight of the servers.
+        err      string
+    }{
+        {
+            name:     "Lines",
+            file:     "servers.txt",
+            data:     "# Web servers\nhttp://127.0.0.1:1080 5\n\nhttp://127.0.0.1:1081  # default weight\n",
+            expected: []string{"http://127.0.0.1:1080 5", "http://127.0.0.1:1081 1"},
+        },
+        {
+            name: "Invalid weight",
+            file: "servers.txt",
+            data: "http://127.0.0.1:1080 5\nhttp://127.0.0.1:1081 x\n",
+            err:  `servers.txt:2: invalid weight "x"`,
+        },
+        {
+            name: "Invalid address",
+            file: "servers.txt",
+            data: "127.0.0.1:1080\n",
+            err:  `servers.txt:1: invalid address "127.0.0.1:1080"`,
+        },
+        {
+            name: "Too many fields",
+            file: "servers.txt",
+            data: "http://127.0.0.1:1080 5 eu\n",
+            err:  `servers.txt:1: expected an address and an optional weight`,
+        },
+        {
+            name:     "YAML",
+            file:     "servers.yaml",
+            data:     "- address: http://127.0.0.1:1080\n  weight: 5\n  zone: eu-west-1a\n- address: http://127.0.0.1:1081\n",
+            expected: []string{"http://127.0.0.1:1080 5", "http://127.0.0.1:1081 1"},
+        },
+        {
+            name:     "JSON",
+            file:     "servers.json",
+            data:     `[{"address": "http://127.0.0.1:1080", "weight": 2}]`,
+            expected: []string{"http://127.0.0.1:1080 2"},
+        },
+        {
+            name: "YAML invalid priority",
+            file: "servers.yml",
+            data: "- address: http://127.0.0.1:1080\n- address: http://127.0.0.1:1081\n  priority: 5\n",
+            err:  `servers.yml:2: invalid priority 5`,
+        },
+        {
+            name: "YAML unknown field",
+            file: "servers.yml",
+            data: "- address: http://127.0.0.1:1080\n  wieght: 5\n",
+            err:  `servers.yml:2: field wieght not found`,
+        },
+        {
+            name: "Duplicate",
+            file: "servers.txt",
+            data: "http://127.0.0.1:1080\nhttp://127.0.0.1:1080 2\n",
+            err:  `servers.txt: duplicate server "http://127.0.0.1:1080"`,
+        },
+    }
+
+    for _, tc := range testCases {
+        t.Run(tc.name, func(t *testing.T) {
+            dir := t.TempDir()
+            path := filepath.Join(dir, tc.file)
+            if err := os.WriteFile(path, []byte(tc.data), 0o644); err != nil {
+                t.Fatal(err)
+            }
+
+            servers, err := NewFile(path, 0).read()
+            if tc.err != "" {
+                expected := dir + string(filepath.Separator) + tc.err
+                if err == nil || !strings.HasPrefix(err.Error(), expected) {
+                    t.Errorf("error incorrect error: expected %s, got %v.\n", expected, err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            got := make([]string, 0, len(servers))
+            for _, srv := range servers {
+                got = append(got, fmt.Sprintf("%s %d", srv.Address, srv.Weight))
+            }
+            if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+                t.Errorf("error reading servers: expected %v, got %v.\n", tc.expected, got)
+            }
+        })
+    }
+}
+
+func TestFile_Run(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "servers.txt")
+    if err := os.WriteFile(path, []byte("http://127.0.0.1:1080\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    done := make(chan struct{})
+    defer close(done)
+    updates := make(chan Update)
+    go NewFile(path, 10*time.Millisecond).Run(done, updates)
+
+    receive := func() Update {
+        select {
+        case u := <-updates:
+            return u
+        case <-time.After(time.Second):
+            t.Fatalf("error running file provider: expected an update.\n")
+        }
+        return Update{}
+    }
+
+    if u := receive(); u.Err != nil || len(u.Servers) != 1 {
+        t.Errorf("error running file provider: expected 1 server, got %#v.\n", u)
+    }
+
+    // A parse error is reported, without servers.
+    if err := os.WriteFile(path, []byte("http://127.0.0.1:1080 x\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if u := receive(); u.Err == nil {
+        t.Errorf("error running file provider: expected a parse error, got %#v.\n", u)
+    }
+
+    if err := os.WriteFile(path, []byte("http://127.0.0.1:1080\nhttp://127.0.0.1:1081\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if u := receive(); u.Err != nil || len(u.Servers) != 2 {
+        t.Errorf("error running file provider: expected 2 servers, got %#v.\n", u)
+    }
+}
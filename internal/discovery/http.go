@@ -0,0 +1,318 @@
+package discovery
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/pkg/model"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+var ErrCatalog = errors.New("error catalog request failed")
+
+// Defaults of the HTTP provider.
+const (
+    DefaultCatalogInterval = 10 * time.Second
+    DefaultCatalogWait     = time.Minute
+    DefaultCatalogTimeout  = 10 * time.Second
+    DefaultCatalogMinWait  = time.Second
+    DefaultMinBackoff      = time.Second
+    DefaultMaxBackoff      = time.Minute
+)
+
+// IndexHeader is the header a catalog that supports long-polling sets to the index of its content. Requests that pass
+// it back in query parameter index are held, for up to query parameter wait, until the content changes.
+const IndexHeader = "X-Catalog-Index"
+
+// Health of a catalog entry, only passing entries, or entries without health, are registered.
+const HealthPassing = "passing"
+
+// CatalogEntry is a server listed by a catalog.
+type CatalogEntry struct {
+    Address  string   `json:"address"` // Host or URL of the server.
+    Port     int      `json:"port"`    // Appended to the address when it has none.
+    Weight   int      `json:"weight"`  // 1 when omitted.
+    Priority int      `json:"priority"`
+    Region   string   `json:"region"`
+    Zone     string   `json:"zone"`
+    Tags     []string `json:"tags"`
+    Health   string   `json:"health"` // HealthPassing or another state, e.g. warning or critical.
+}
+
+// HTTP discovers the servers listed by a JSON service catalog: an array of CatalogEntry.
+// The catalog is polled every interval, or long-polled when it sets IndexHeader. Responses with an ETag are requested
+// again with If-None-Match, so that an unchanged catalog can answer 304 Not Modified. When the catalog fails, it's
+// polled again after an exponential backoff, and the servers are left as they are. A catalog that answers a long poll
+// without advancing its index, e.g. because it ignores it, is polled again after MinWait rather than right away.
+type HTTP struct {
+    URL        string
+    Tags       []string      // Tags a server needs to be registered, all of them.
+    Scheme     string        // Scheme of the servers listed without one.
+    Interval   time.Duration // Polling period of a catalog that doesn't support long-polling.
+    Wait       time.Duration // How long the catalog may hold a long-polling request.
+    Timeout    time.Duration // Time a request may take, on top of the wait of long-polling.
+    MinWait    time.Duration // Least time between long polls when the index doesn't advance.
+    MinBackoff time.Duration // Backoff after the first failure, doubled with every failure in a row.
+    MaxBackoff time.Duration
+    Client     *http.Client
+}
+
+// NewHTTP creates an HTTP provider described by cfg.
+func NewHTTP(cfg config.Discovery) *HTTP {
+    h := &HTTP{
+        URL:        cfg.URL,
+        Tags:       cfg.Tags,
+        Scheme:     cfg.Scheme,
+        Interval:   cfg.Interval,
+        Wait:       cfg.Wait,
+        Timeout:    DefaultCatalogTimeout,
+        MinWait:    DefaultCatalogMinWait,
+        MinBackoff: DefaultMinBackoff,
+        MaxBackoff: DefaultMaxBackoff,
+        Client:     http.DefaultClient,
+    }
+    if h.Scheme == "" {
+        h.Scheme = "http"
+    }
+    if h.Interval <= 0 {
+        h.Interval = DefaultCatalogInterval
+    }
+    if h.Wait <= 0 {
+        h.Wait = DefaultCatalogWait
+    }
+    return h
+}
+
+func (h *HTTP) Name() string {
+    return "http:" + h.URL
+}
+
+// catalogState is what was learnt from the last response of the catalog.
+type catalogState struct {
+    index string // Value of IndexHeader, empty when the catalog doesn't support long-polling.
+    etag  string
+    reset bool // The index went backwards, the catalog was reset.
+}
+
+// Run polls the catalog until done is closed. An update is sent at the first response and whenever the content of the
+// catalog changes, and after every failure.
+func (h *HTTP) Run(done <-chan struct{}, updates chan<- Update) {
+    var state catalogState
+    var last []*model.BEServer
+    sent := false
+    backoff := time.Duration(0)
+    for {
+        servers, next, changed, err := h.poll(done, state)
+        var wait time.Duration
+        switch {
+        case err != nil:
+            // Keep the last known good set, and back off.
+            backoff = min(max(2*backoff, h.MinBackoff), h.MaxBackoff)
+            wait = backoff
+            send(done, updates, Update{Err: err})
+        default:
+            backoff = 0
+            if next.index != "" && state.index != "" && !next.reset && !advanced(state.index, next.index) {
+                wait = h.MinWait
+            }
+            state = next
+            if changed && (!sent || !sameServers(last, servers)) {
+                last, sent = servers, true
+                send(done, updates, Update{Servers: servers})
+            }
+            if state.index == "" {
+                wait = h.Interval
+            }
+        }
+
+        if wait > 0 {
+            timer := time.NewTimer(wait)
+            select {
+            case <-done:
+                timer.Stop()
+                return
+            case <-timer.C:
+            }
+        } else {
+            select {
+            case <-done:
+                return
+            default:
+            }
+        }
+    }
+}
+
+// advanced reports whether the index of the catalog went from prev to next, moving forward.
+func advanced(prev, next string) bool {
+    p, err := strconv.ParseUint(prev, 10, 64)
+    if err != nil {
+        return next != prev
+    }
+    n, err := strconv.ParseUint(next, 10, 64)
+    if err != nil {
+        return next != prev
+    }
+    return n > p
+}
+
+// poll requests the catalog. It returns the servers listed and the state of the catalog, changed is false when it
+// answered 304 Not Modified.
+func (h *HTTP) poll(done <-chan struct{}, state catalogState) ([]*model.BEServer, catalogState, bool, error) {
+    u, err := url.Parse(h.URL)
+    if err != nil {
+        return nil, state, false, fmt.Errorf("%w: %v", ErrCatalog, err)
+    }
+    timeout := h.Timeout
+    if state.index != "" {
+        query := u.Query()
+        query.Set("index", state.index)
+        query.Set("wait", h.Wait.String())
+        u.RawQuery = query.Encode()
+        timeout += h.Wait
+    }
+
+    req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+    if err != nil {
+        return nil, state, false, fmt.Errorf("%w: %v", ErrCatalog, err)
+    }
+    // Give up on the request when the provider stops.
+    ctx, cancel := contextUntil(done, timeout)
+    defer cancel()
+    req = req.WithContext(ctx)
+    if state.etag != "" {
+        req.Header.Set("If-None-Match", state.etag)
+    }
+
+    resp, err := h.Client.Do(req)
+    if err != nil {
+        return nil, state, false, fmt.Errorf("%w: %v", ErrCatalog, err)
+    }
+    defer resp.Body.Close()
+
+    next := catalogState{index: resp.Header.Get(IndexHeader), etag: resp.Header.Get("ETag")}
+    if next.index != "" && state.index != "" {
+        // An index that goes backwards means the catalog was reset, start over without waiting.
+        prev, _ := strconv.ParseUint(state.index, 10, 64)
+        if index, err := strconv.ParseUint(next.index, 10, 64); err == nil && index < prev {
+            next.index = "0"
+            next.reset = true
+        }
+    }
+    switch resp.StatusCode {
+    case http.StatusNotModified:
+        if next.etag == "" {
+            next.etag = state.etag
+        }
+        return nil, next, false, nil
+    case http.StatusOK:
+    default:
+        return nil, state, false, fmt.Errorf("%w: %s", ErrCatalog, resp.Status)
+    }
+
+    var entries []CatalogEntry
+    if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+        return nil, state, false, fmt.Errorf("%w: %v", ErrCatalog, err)
+    }
+    servers, err := h.servers(entries)
+    if err != nil {
+        return nil, state, false, fmt.Errorf("%w: %v", ErrCatalog, err)
+    }
+    return servers, next, true, nil
+}
+
+// servers maps the passing entries that have all the tags to servers.
+func (h *HTTP) servers(entries []CatalogEntry) ([]*model.BEServer, error) {
+    servers := make([]*model.BEServer, 0, len(entries))
+    seen := make(map[string]bool, len(entries))
+    for _, entry := range entries {
+        if (entry.Health != "" && entry.Health != HealthPassing) || !hasTags(entry.Tags, h.Tags) {
+            continue
+        }
+
+        address := entry.Address
+        if !strings.Contains(address, "://") {
+            address = h.Scheme + "://" + address
+        }
+        if u, err := url.Parse(address); err == nil && u.Port() == "" && entry.Port != 0 {
+            u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(entry.Port))
+            address = u.String()
+        }
+        weight := entry.Weight
+        if weight == 0 {
+            weight = 1
+        }
+        srv, err := newServer(address, weight)
+        if err != nil {
+            return nil, err
+        }
+        if entry.Priority < model.PriorityPrimary || entry.Priority > model.PriorityDisasterRecovery {
+            return nil, fmt.Errorf("%s: invalid priority %d, expected %d to %d", address, entry.Priority, model.PriorityPrimary, model.PriorityDisasterRecovery)
+        }
+        if seen[address] {
+            continue
+        }
+        seen[address] = true
+        srv.Priority = entry.Priority
+        srv.Region = entry.Region
+        srv.Zone = entry.Zone
+        servers = append(servers, srv)
+    }
+
+    sort.Slice(servers, func(i, j int) bool {
+        return servers[i].Address < servers[j].Address
+    })
+    return servers, nil
+}
+
+// contextUntil returns a context canceled when done is closed or after timeout.
+func contextUntil(done <-chan struct{}, timeout time.Duration) (context.Context, context.CancelFunc) {
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    go func() {
+        select {
+        case <-done:
+            cancel()
+        case <-ctx.Done():
+        }
+    }()
+    return ctx, cancel
+}
+
+// hasTags reports whether tags holds every one of wanted.
+func hasTags(tags, wanted []string) bool {
+    for _, w := range wanted {
+        found := false
+        for _, tag := range tags {
+            if tag == w {
+                found = true
+                break
+            }
+        }
+        if !found {
+            return false
+        }
+    }
+    return true
+}
+
+// sameServers reports whether a and b, sorted by address, hold the same servers.
+func sameServers(a, b []*model.BEServer) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i].Address != b[i].Address || a[i].Weight != b[i].Weight || a[i].Priority != b[i].Priority ||
+            a[i].Region != b[i].Region || a[i].Zone != b[i].Zone {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,268 @@
+package discovery
+
+import (
+    "LoadBalancer/internal/config"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// catalogStandIn is an in-process service catalog supporting long-polling with an index, and ETags.
+type catalogStandIn struct {
+    sync.Mutex
+    entries  []CatalogEntry
+    index    int
+    fail     bool
+    changed  chan struct{} // Closed when the entries change.
+    requests []*http.Request
+}
+
+func newCatalogStandIn(t *testing.T, entries ...CatalogEntry) (*catalogStandIn, *httptest.Server) {
+    t.Helper()
+
+    c := &catalogStandIn{entries: entries, index: 1, changed: make(chan struct{})}
+    server := httptest.NewServer(c)
+    t.Cleanup(server.Close)
+    return c, server
+}
+
+// set replaces the entries and bumps the index.
+func (c *catalogStandIn) set(entries ...CatalogEntry) {
+    c.Lock()
+    defer c.Unlock()
+    c.entries = entries
+    c.index++
+    close(c.changed)
+    c.changed = make(chan struct{})
+}
+
+func (c *catalogStandIn) setFail(fail bool) {
+    c.Lock()
+    defer c.Unlock()
+    c.fail = fail
+}
+
+func (c *catalogStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    c.Lock()
+    c.requests = append(c.requests, r)
+    if c.fail {
+        c.Unlock()
+        w.WriteHeader(http.StatusInternalServerError)
+        return
+    }
+    // Hold the request until the entries change past the index asked for.
+    if index, err := strconv.Atoi(r.URL.Query().Get("index")); err == nil && index >= c.index {
+        wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+        changed := c.changed
+        c.Unlock()
+        select {
+        case <-changed:
+        case <-time.After(wait):
+        case <-r.Context().Done():
+            return
+        }
+        c.Lock()
+    }
+    defer c.Unlock()
+
+    etag := fmt.Sprintf(`"%d"`, c.index)
+    w.Header().Set(IndexHeader, strconv.Itoa(c.index))
+    w.Header().Set("ETag", etag)
+    if r.Header.Get("If-None-Match") == etag {
+        w.WriteHeader(http.StatusNotModified)
+        return
+    }
+    _ = json.NewEncoder(w).Encode(c.entries)
+}
+
+func TestHTTP_Servers(t *testing.T) {
+    h := NewHTTP(config.Discovery{Type: config.DiscoveryHTTP, URL: "http://catalog", Tags: []string{"web"}})
+    servers, err := h.servers([]CatalogEntry{
+        {Address: "10.0.0.2", Port: 8080, Weight: 3, Priority: 1, Region: "eu", Zone: "eu-1a", Tags: []string{"web", "v2"}, Health: HealthPassing},
+        {Address: "https://10.0.0.1:8443", Tags: []string{"web"}},
+        {Address: "10.0.0.3", Port: 8080, Tags: []string{"web"}, Health: "critical"},
+        {Address: "10.0.0.4", Port: 8080, Tags: []string{"api"}},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    expected := []string{"http://10.0.0.2:8080 weight 3 priority 1 eu/eu-1a", "https://10.0.0.1:8443 weight 1 priority 0 /"}
+    got := make([]string, 0, len(servers))
+    for _, srv := range servers {
+        got = append(got, fmt.Sprintf("%s weight %d priority %d %s/%s", srv.Address, srv.Weight, srv.Priority, srv.Region, srv.Zone))
+    }
+    if strings.Join(got, ",") != strings.Join(expected, ",") {
+        t.Errorf("error mapping catalog entries: expected %v, got %v.\n", expected, got)
+    }
+
+    if _, err = h.servers([]CatalogEntry{{Address: "10.0.0.1", Port: 80, Tags: []string{"web"}, Priority: 5}}); err == nil {
+        t.Errorf("error mapping catalog entries: expected an error for priority 5, got none.\n")
+    }
+}
+
+func TestHTTP_LongPoll(t *testing.T) {
+    catalog, server := newCatalogStandIn(t, CatalogEntry{Address: "10.0.0.1", Port: 80})
+
+    h := NewHTTP(config.Discovery{Type: config.DiscoveryHTTP, URL: server.URL + "/catalog"})
+    // The catalog long-polls, the interval would delay the change past the deadline.
+    h.Interval = time.Hour
+    done := make(chan struct{})
+    defer close(done)
+    updates := make(chan Update)
+    go h.Run(done, updates)
+
+    u := <-updates
+    if u.Err != nil || len(u.Servers) != 1 || u.Servers[0].Address != "http://10.0.0.1:80" {
+        t.Fatalf("error running HTTP provider: expected http://10.0.0.1:80, got %#v.\n", u)
+    }
+
+    catalog.set(CatalogEntry{Address: "10.0.0.1", Port: 80}, CatalogEntry{Address: "10.0.0.2", Port: 80})
+    select {
+    case u = <-updates:
+    case <-time.After(time.Second):
+        t.Fatalf("error running HTTP provider: expected the change to arrive while long-polling.\n")
+    }
+    if u.Err != nil || len(u.Servers) != 2 {
+        t.Errorf("error running HTTP provider: expected 2 servers, got %#v.\n", u)
+    }
+
+    catalog.Lock()
+    defer catalog.Unlock()
+    second := catalog.requests[1]
+    if second.URL.Query().Get("index") != "1" || second.URL.Query().Get("wait") != DefaultCatalogWait.String() {
+        t.Errorf("error long-polling: expected index 1 and wait %s, got %s.\n", DefaultCatalogWait, second.URL.RawQuery)
+    }
+    if second.Header.Get("If-None-Match") != `"1"` {
+        t.Errorf("error long-polling: expected If-None-Match %q, got %q.\n", `"1"`, second.Header.Get("If-None-Match"))
+    }
+}
+
+func TestHTTP_LongPollIgnored(t *testing.T) {
+    // The catalog sets the index but answers right away, whatever the index asked for.
+    var mu sync.Mutex
+    requests := 0
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        requests++
+        mu.Unlock()
+        w.Header().Set(IndexHeader, "7")
+        _ = json.NewEncoder(w).Encode([]CatalogEntry{{Address: "10.0.0.1", Port: 80}})
+    }))
+    t.Cleanup(server.Close)
+
+    h := NewHTTP(config.Discovery{Type: config.DiscoveryHTTP, URL: server.URL})
+    h.MinWait = 50 * time.Millisecond
+    done := make(chan struct{})
+    updates := make(chan Update, 1)
+    go h.Run(done, updates)
+
+    if u := <-updates; u.Err != nil || len(u.Servers) != 1 {
+        t.Fatalf("error running HTTP provider: expected 1 server, got %#v.\n", u)
+    }
+    time.Sleep(275 * time.Millisecond)
+    close(done)
+
+    mu.Lock()
+    defer mu.Unlock()
+    // The first poll, then one at most every 50ms.
+    if requests > 7 {
+        t.Errorf("error long-polling: expected at most %d requests, got %d.\n", 7, requests)
+    }
+}
+
+func TestHTTP_IndexReset(t *testing.T) {
+    // The catalog is reset after the first poll, its index goes backwards, and it holds the polls at its index.
+    var mu sync.Mutex
+    var indexes []string
+    var times []time.Time
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        indexes = append(indexes, r.URL.Query().Get("index"))
+        times = append(times, time.Now())
+        polls := len(indexes)
+        mu.Unlock()
+        switch {
+        case polls == 1:
+            w.Header().Set(IndexHeader, "9")
+        case r.URL.Query().Get("index") == "3":
+            <-r.Context().Done()
+            return
+        default:
+            w.Header().Set(IndexHeader, "3")
+        }
+        _ = json.NewEncoder(w).Encode([]CatalogEntry{{Address: "10.0.0.1", Port: 80}})
+    }))
+    t.Cleanup(server.Close)
+
+    h := NewHTTP(config.Discovery{Type: config.DiscoveryHTTP, URL: server.URL})
+    h.MinWait = time.Second
+    done := make(chan struct{})
+    defer close(done)
+    updates := make(chan Update, 1)
+    go h.Run(done, updates)
+    <-updates
+    time.Sleep(200 * time.Millisecond)
+
+    mu.Lock()
+    defer mu.Unlock()
+    // The first poll, the one answered with the lower index, then the one starting over right away.
+    if len(indexes) < 4 || indexes[1] != "9" || indexes[2] != "0" || indexes[3] != "3" {
+        t.Fatalf("error long-polling: expected indexes %v, got %v.\n", []string{"", "9", "0", "3"}, indexes)
+    }
+    if elapsed := times[2].Sub(times[1]); elapsed >= h.MinWait {
+        t.Errorf("error long-polling: expected to start over without waiting, got %s.\n", elapsed)
+    }
+}
+
+func TestHTTP_NotModified(t *testing.T) {
+    _, server := newCatalogStandIn(t, CatalogEntry{Address: "10.0.0.1", Port: 80})
+    h := NewHTTP(config.Discovery{Type: config.DiscoveryHTTP, URL: server.URL})
+
+    servers, state, changed, err := h.poll(nil, catalogState{})
+    if err != nil || !changed || len(servers) != 1 {
+        t.Fatalf("error polling catalog: expected 1 server, got %d, %v.\n", len(servers), err)
+    }
+    // Without the index, the catalog answers right away, and the ETag tells it nothing changed.
+    state.index = ""
+    if _, _, changed, err = h.poll(nil, state); err != nil || changed {
+        t.Errorf("error polling catalog: expected 304 Not Modified, got changed %t, %v.\n", changed, err)
+    }
+}
+
+func TestHTTP_Backoff(t *testing.T) {
+    catalog, server := newCatalogStandIn(t, CatalogEntry{Address: "10.0.0.1", Port: 80})
+    catalog.setFail(true)
+
+    h := NewHTTP(config.Discovery{Type: config.DiscoveryHTTP, URL: server.URL})
+    h.MinBackoff = 10 * time.Millisecond
+    h.MaxBackoff = 40 * time.Millisecond
+    done := make(chan struct{})
+    defer close(done)
+    updates := make(chan Update)
+    go h.Run(done, updates)
+
+    // Failures are reported, backing off 10ms, 20ms, 40ms, 40ms.
+    start := time.Now()
+    for i := 0; i < 5; i++ {
+        if u := <-updates; !errors.Is(u.Err, ErrCatalog) || u.Servers != nil {
+            t.Fatalf("error incorrect error: expected %#v, got %#v.\n", ErrCatalog, u)
+        }
+    }
+    if elapsed := time.Since(start); elapsed < 110*time.Millisecond {
+        t.Errorf("error backing off: expected at least %s between 5 failures, got %s.\n", 110*time.Millisecond, elapsed)
+    }
+
+    catalog.setFail(false)
+    u := <-updates
+    if u.Err != nil || len(u.Servers) != 1 {
+        t.Errorf("error running HTTP provider: expected 1 server once the catalog recovers, got %#v.\n", u)
+    }
+}
@@ -0,0 +1,282 @@
+package lb
+
+import (
+    "LoadBalancer/internal/lb/response"
+    "LoadBalancer/internal/lbalgo"
+    "LoadBalancer/pkg/balancer"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+)
+
+// algoDriver is the algorithm in use together with its brief and parameters.
+type algoDriver struct {
+    lbalgo.LBAlgo
+    brief  string
+    params balancer.Params
+}
+
+// SetLocality makes the load balancer prefer servers of its own zone, the algorithm of every pool is rebuilt within
+// each locality level.
+func (l *LoadBalancer) SetLocality(config lbalgo.ZoneConfig) error {
+    l.Lock()
+    defer l.Unlock()
+
+    l.Locality = &config
+    return l.rebuildPools()
+}
+
+// SetStickyCookie pins the clients of every pool to servers with a cookie, the algorithms in use are wrapped to honor it.
+// Without a secret, a random one is generated and kept for every algorithm swapped in later.
+func (l *LoadBalancer) SetStickyCookie(config lbalgo.CookieConfig) error {
+    cookie, err := newCookie(config)
+    if err != nil {
+        return err
+    }
+
+    l.Lock()
+    defer l.Unlock()
+
+    for _, pool := range l.pools {
+        pool.Cookie = cookie
+    }
+    return l.rebuildPools()
+}
+
+// newCookie returns config with a random secret when it has none.
+func newCookie(config lbalgo.CookieConfig) (*lbalgo.CookieConfig, error) {
+    if len(config.Secret) == 0 {
+        config.Secret = make([]byte, sha256.Size)
+        if _, err := rand.Read(config.Secret); err != nil {
+            return nil, err
+        }
+    }
+    return &config, nil
+}
+
+// SetSeed seeds the algorithms in use, and every algorithm swapped in later, when they make random choices.
+func (l *LoadBalancer) SetSeed(seed int64) {
+    l.Lock()
+    defer l.Unlock()
+
+    l.Seed = seed
+    for _, pool := range l.pools {
+        if seeder, ok := pool.AlgoDriver().(lbalgo.Seeder); ok && seed != 0 {
+            seeder.Seed(seed)
+        }
+    }
+}
+
+// newAlgo creates the algorithm algoBrief of pool, wrapped in a ZoneAware balancer when the load balancer has a
+// locality and in a CookieSticky balancer when the clients of the pool are pinned with a cookie.
+// Callers must hold the lock.
+func (l *LoadBalancer) newAlgo(pool *Pool, algoBrief string, params balancer.Params) (lbalgo.LBAlgo, error) {
+    return newAlgo(algoBrief, params, l.Locality, pool.Cookie, l.Seed)
+}
+
+// newAlgo creates the algorithm algoBrief, wrapped in a ZoneAware balancer when locality is set and in a CookieSticky
+// balancer when cookie is set, and seeded with seed unless it's 0.
+func newAlgo(algoBrief string, params balancer.Params, locality *lbalgo.ZoneConfig, cookie *lbalgo.CookieConfig, seed int64) (lbalgo.LBAlgo, error) {
+    algo, err := lbalgo.ChooseAlgo(algoBrief, params)
+    if err != nil {
+        return nil, err
+    }
+
+    if locality != nil {
+        algo = lbalgo.NewZoneAware(*locality, func() lbalgo.LBAlgo {
+            // The parameters were checked by the first call.
+            algo, _ := lbalgo.ChooseAlgo(algoBrief, params)
+            return algo
+        }, nil)
+    }
+    if cookie != nil {
+        algo = lbalgo.NewCookieSticky(*cookie, algo)
+    }
+
+    if seeder, ok := algo.(lbalgo.Seeder); ok && seed != 0 {
+        seeder.Seed(seed)
+    }
+    return algo, nil
+}
+
+// SwapAlgo replaces the algorithm of the default pool with algoBrief without dropping requests.
+func (l *LoadBalancer) SwapAlgo(algoBrief string, params balancer.Params) error {
+    return l.SwapPoolAlgo("", algoBrief, params)
+}
+
+// SwapPoolAlgo replaces the algorithm of the pool name, the default pool when empty, with algoBrief without dropping
+// requests.
+// The new algorithm is seeded with the servers currently taking traffic before it's swapped in, and takes over the
+// client bindings of the old one when both pin clients to servers.
+// Requests in flight finish with the algorithm that chose their server.
+func (l *LoadBalancer) SwapPoolAlgo(name string, algoBrief string, params balancer.Params) error {
+    // Hold the lock so that no scan renews the old algorithm in between.
+    l.Lock()
+    defer l.Unlock()
+
+    pool, ok := l.poolNamed(name)
+    if !ok {
+        return ErrUnknownPool
+    }
+    next, err := l.newAlgo(pool, algoBrief, params)
+    if err != nil {
+        return err
+    }
+    l.swapAlgo(pool, next, algoBrief, params)
+    return nil
+}
+
+// swapAlgo renews next with the servers of pool taking traffic and swaps it in. Callers must hold the lock.
+// The clients pinned by the previous algorithm are handed over once next is in use, so that a client the previous
+// algorithm binds during the swap isn't lost. A client next already bound meanwhile keeps its server.
+func (l *LoadBalancer) swapAlgo(pool *Pool, next lbalgo.LBAlgo, algoBrief string, params balancer.Params) {
+    next.Renew(l.activeServers(pool))
+    prev := pool.AlgoDriver()
+    pool.algoDriver.Store(&algoDriver{LBAlgo: next, brief: strings.ToUpper(algoBrief), params: params})
+    l.watchDrains(pool)
+
+    if from, ok := prev.(lbalgo.Sticky); ok {
+        if to, ok := next.(lbalgo.Sticky); ok {
+            clients := from.Bindings()
+            for client := range to.Bindings() {
+                delete(clients, client)
+            }
+            to.Bind(clients)
+        }
+    }
+    if from, ok := prev.(*lbalgo.CookieSticky); ok {
+        if to, ok := next.(*lbalgo.CookieSticky); ok {
+            to.Adopt(from)
+        }
+    }
+}
+
+// rebuildPools swaps in a new instance of the algorithm of every pool, none is swapped when one can't be created.
+// Callers must hold the lock.
+func (l *LoadBalancer) rebuildPools() error {
+    next := make(map[*Pool]lbalgo.LBAlgo, len(l.pools))
+    for _, pool := range l.pools {
+        algo, err := l.newAlgo(pool, pool.AlgoBrief(), pool.AlgoParams())
+        if err != nil {
+            return err
+        }
+        next[pool] = algo
+    }
+    for pool, algo := range next {
+        l.swapAlgo(pool, algo, pool.AlgoBrief(), pool.AlgoParams())
+    }
+    return nil
+}
+
+// AlgoRequest is used for swapping the load balancing algorithm of a pool, the default pool when it's empty.
+type AlgoRequest struct {
+    Pool   string          `json:"pool,omitempty"`
+    Algo   string          `json:"algo"`
+    Params balancer.Params `json:"params,omitempty"`
+}
+
+// AlgoResponse describes the algorithm of a pool and the ones available.
+type AlgoResponse struct {
+    Pool      string          `json:"pool"`
+    Algo      string          `json:"algo"`
+    Params    balancer.Params `json:"params,omitempty"`
+    Available []string        `json:"available"`
+}
+
+// Algo is a handler that is used by endpoint '/admin/algo'.
+// GET returns the algorithm of the pool given by query parameter pool, POST swaps it. The default pool is meant
+// without a pool.
+func (l *LoadBalancer) Algo(w http.ResponseWriter, req *http.Request) {
+    switch req.Method {
+    case http.MethodGet:
+        name := req.URL.Query().Get("pool")
+        pool, err := l.LookupPool(name)
+        if err != nil {
+            l.writeUnknownPool(w, name)
+            return
+        }
+        response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(algoResponse(pool)))
+    case http.MethodPost:
+        var p AlgoRequest
+        decoder := json.NewDecoder(req.Body)
+        decoder.DisallowUnknownFields()
+        if err := decoder.Decode(&p); err != nil {
+            response.WriteJsonResponse(w, http.StatusBadRequest, response.NewErrorResponse(err))
+            return
+        }
+
+        pool, err := l.LookupPool(p.Pool)
+        if err != nil {
+            l.writeUnknownPool(w, p.Pool)
+            return
+        }
+        prev := pool.AlgoBrief()
+        if err = l.SwapPoolAlgo(p.Pool, p.Algo, p.Params); errors.Is(err, ErrUnknownPool) {
+            // Removed by a reload meanwhile.
+            l.writeUnknownPool(w, p.Pool)
+            return
+        } else if err != nil {
+            responsePayload := response.NewFailResponse(
+                struct {
+                    Title string `json:"title"`
+                }{Title: fmt.Sprintf("%v: %s.", err, p.Algo)})
+            response.WriteJsonResponse(w, http.StatusBadRequest, responsePayload)
+            return
+        }
+        log.Printf("Load balancing algorithm of pool %s swapped from %s to %s.\n", pool.Name, prev, pool.AlgoBrief())
+
+        response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(algoResponse(pool)))
+    default:
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s or %s, got %s.", http.MethodGet, http.MethodPost, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+    }
+}
+
+// algoResponse describes the algorithm of pool.
+func algoResponse(pool *Pool) AlgoResponse {
+    return AlgoResponse{
+        Pool:      pool.Name,
+        Algo:      pool.AlgoBrief(),
+        Params:    pool.AlgoParams(),
+        Available: balancer.Algorithms(),
+    }
+}
+
+// StickyStats is a handler that is used by endpoint '/admin/sticky'.
+// It returns the size, hits, misses and evictions of the sticky table of the algorithm of the pool given by query
+// parameter pool, the default pool without one.
+func (l *LoadBalancer) StickyStats(w http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodGet {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s, got %s.", http.MethodGet, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+        return
+    }
+
+    name := req.URL.Query().Get("pool")
+    pool, err := l.LookupPool(name)
+    if err != nil {
+        l.writeUnknownPool(w, name)
+        return
+    }
+    reporter, ok := pool.AlgoDriver().(lbalgo.StickyReporter)
+    if !ok {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Algorithm %s keeps no sticky table.", pool.AlgoBrief())})
+        response.WriteJsonResponse(w, http.StatusNotFound, responsePayload)
+        return
+    }
+    response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(reporter.StickyStats()))
+}
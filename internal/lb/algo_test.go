@@ -0,0 +1,117 @@
+package lb
+
+import (
+    "LoadBalancer/internal/lbalgo"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestLoadBalancer_SwapAlgo(t *testing.T) {
+    l, err := New(0, 10, "SRR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.AliveServers = model.BEServers{
+        "Address A": model.NewBEServer("Address A", 1),
+        "Address B": model.NewBEServer("Address B", 1),
+    }
+    l.AlgoDriver().Renew(l.AliveServers)
+
+    client := &http.Request{RemoteAddr: "10.0.0.1"}
+    pinned, _ := l.AlgoDriver().ChooseServer(client)
+
+    // SRR -> LC keeps serving from the same servers.
+    if err = l.SwapAlgo("lc", nil); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := l.AlgoDriver().(*lbalgo.LC); !ok || l.AlgoBrief() != lbalgo.LeastConnection {
+        t.Fatalf("error swapping algorithm: got %s %T.\n", l.AlgoBrief(), l.AlgoDriver())
+    }
+    if _, err = l.AlgoDriver().ChooseServer(client); err != nil {
+        t.Errorf("error choosing server after swap: got %#v.\n", err)
+    }
+
+    // Sticky bindings survive a swap between sticky algorithms.
+    l.algoDriver.Store(&algoDriver{LBAlgo: lbalgo.NewSRR(&l.AliveServers), brief: lbalgo.StickyRoundRobin})
+    l.AlgoDriver().(*lbalgo.SRR).AllClients.Put("10.0.0.1", pinned.Address)
+    if err = l.SwapAlgo("SRR", nil); err != nil {
+        t.Fatal(err)
+    }
+    if chosen, _ := l.AlgoDriver().ChooseServer(client); chosen.Address != pinned.Address {
+        t.Errorf("error migrating bindings: expected %s, got %s.\n", pinned.Address, chosen.Address)
+    }
+
+    if err = l.SwapAlgo("XYZ", nil); err == nil {
+        t.Errorf("error swapping to an unknown algorithm: expected an error.\n")
+    }
+}
+
+func TestLoadBalancer_Algo(t *testing.T) {
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    testCases := []struct {
+        method       string
+        body         string
+        expectedCode int
+        expectedAlgo string
+    }{
+        {method: http.MethodGet, expectedCode: http.StatusOK, expectedAlgo: "RR"},
+        {method: http.MethodPost, body: `{"algo": "wlc"}`, expectedCode: http.StatusOK, expectedAlgo: "WLC"},
+        {method: http.MethodPost, body: `{"algo": "XYZ"}`, expectedCode: http.StatusBadRequest, expectedAlgo: "WLC"},
+        {method: http.MethodPost, body: `{"name": "RR"}`, expectedCode: http.StatusBadRequest, expectedAlgo: "WLC"},
+        {method: http.MethodPost, body: `{"algo": "pewma", "params": {"decay": "5s"}}`, expectedCode: http.StatusOK, expectedAlgo: "PEWMA"},
+        {method: http.MethodPost, body: `{"algo": "pewma", "params": {"decay": "soon"}}`, expectedCode: http.StatusBadRequest, expectedAlgo: "PEWMA"},
+        {method: http.MethodPost, body: `{"algo": "RR", "params": {"vnodes": "100"}}`, expectedCode: http.StatusBadRequest, expectedAlgo: "PEWMA"},
+        {method: http.MethodDelete, expectedCode: http.StatusMethodNotAllowed, expectedAlgo: "PEWMA"},
+    }
+
+    for _, tc := range testCases {
+        rec := httptest.NewRecorder()
+        l.Algo(rec, httptest.NewRequest(tc.method, "/admin/algo", strings.NewReader(tc.body)))
+
+        if rec.Code != tc.expectedCode {
+            t.Errorf("%s %s: expected status %d, got %d.\n", tc.method, tc.body, tc.expectedCode, rec.Code)
+        }
+        if l.AlgoBrief() != tc.expectedAlgo {
+            t.Errorf("%s %s: expected algorithm %s, got %s.\n", tc.method, tc.body, tc.expectedAlgo, l.AlgoBrief())
+        }
+    }
+}
+
+func TestLoadBalancer_StickyStats(t *testing.T) {
+    l, err := New(0, 10, "SRR", balancer.Params{"size": "1"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.AliveServers = model.BEServers{"Address A": model.NewBEServer("Address A", 1)}
+    l.AlgoDriver().Renew(l.AliveServers)
+    _, _ = l.AlgoDriver().ChooseServer(&http.Request{RemoteAddr: "10.0.0.1"})
+    _, _ = l.AlgoDriver().ChooseServer(&http.Request{RemoteAddr: "10.0.0.2"})
+
+    rec := httptest.NewRecorder()
+    l.StickyStats(rec, httptest.NewRequest(http.MethodGet, "/admin/sticky", nil))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("error getting sticky stats: expected status %d, got %d.\n", http.StatusOK, rec.Code)
+    }
+    expected := `"size":1,"hits":0,"misses":2,"evictions":1`
+    if !strings.Contains(rec.Body.String(), expected) {
+        t.Errorf("error getting sticky stats: expected %s in %s.\n", expected, rec.Body.String())
+    }
+
+    // Algorithms without sticky table have no stats.
+    if err = l.SwapAlgo("RR", nil); err != nil {
+        t.Fatal(err)
+    }
+    rec = httptest.NewRecorder()
+    l.StickyStats(rec, httptest.NewRequest(http.MethodGet, "/admin/sticky", nil))
+    if rec.Code != http.StatusNotFound {
+        t.Errorf("error getting sticky stats: expected status %d, got %d.\n", http.StatusNotFound, rec.Code)
+    }
+}
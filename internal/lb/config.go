@@ -0,0 +1,146 @@
+package lb
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/internal/discovery"
+    "LoadBalancer/internal/lbalgo"
+    "LoadBalancer/internal/registry"
+    "LoadBalancer/pkg/model"
+)
+
+// NewFromConfig creates an instance of LoadBalancer described by cfg, which must be valid.
+// The static backends of the pools, and the registrations restored from the registry file, are added down. They're
+// probed as soon as the load balancer starts, which also starts the discovery providers.
+func NewFromConfig(cfg *config.Config) (*LoadBalancer, error) {
+    first := cfg.Pools[0]
+    l, err := New(0, 0, first.Algorithm, first.Params)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, listener := range cfg.Listeners {
+        l.Listeners = append(l.Listeners, listener.Address)
+    }
+    for _, listener := range cfg.AdminListeners {
+        l.AdminListeners = append(l.AdminListeners, listener.Address)
+    }
+    l.ScanPeriod = cfg.HealthCheck.Interval
+    l.ReadTimeout = cfg.Timeouts.Read
+    l.WriteTimeout = cfg.Timeouts.Write
+    l.IdleTimeout = cfg.Timeouts.Idle
+    l.Timeout = cfg.Timeouts.Backend
+    l.FailoverThreshold = cfg.Failover
+    l.SlowStart = cfg.SlowStart.Window
+    l.SlowStartMode = cfg.SlowStart.Mode
+    l.Locality = zoneConfig(cfg.Locality)
+    l.Seed = cfg.Seed
+
+    // The pools replace the default one created above, the first pool being the default one.
+    l.pools = make(map[string]*Pool, len(cfg.Pools))
+    for _, p := range cfg.Pools {
+        pool, err := l.poolFromConfig(cfg, p)
+        if err != nil {
+            return nil, err
+        }
+        l.pools[pool.Name] = pool
+        for _, backend := range p.Backends {
+            l.addServer(pool, backendServer(backend))
+        }
+    }
+    l.Pool = l.pools[first.Name]
+    if len(cfg.Routes) > 0 || len(cfg.VirtualHosts) > 0 {
+        if l.router, err = newRouter(cfg.Routes, cfg.VirtualHosts); err != nil {
+            return nil, err
+        }
+    }
+    l.publish()
+
+    for _, d := range cfg.Discovery {
+        provider, err := discovery.New(d)
+        if err != nil {
+            return nil, err
+        }
+        l.providers = append(l.providers, provider)
+        l.discoveryState(provider.Name()).pool = d.Pool
+    }
+    l.config = cfg
+
+    if cfg.Registry.File != "" {
+        l.Store = registry.NewFileStore(cfg.Registry.File)
+        if err = l.Restore(); err != nil {
+            return nil, err
+        }
+    }
+    return l, nil
+}
+
+// poolFromConfig creates the empty pool p of cfg, with its health check and sticky cookie.
+func (l *LoadBalancer) poolFromConfig(cfg *config.Config, p config.Pool) (*Pool, error) {
+    cookie, err := cookieConfig(cfg.CookieOf(p))
+    if err != nil {
+        return nil, err
+    }
+    algo, err := newAlgo(p.Algorithm, p.Params, l.Locality, cookie, l.Seed)
+    if err != nil {
+        return nil, err
+    }
+
+    pool := newPool(p.Name, algo, p.Algorithm, p.Params)
+    health := cfg.HealthCheckOf(p)
+    pool.HealthPath = health.Path
+    pool.HealthTimeout = health.Timeout
+    pool.Cookie = cookie
+    return pool, nil
+}
+
+// zoneConfig returns the zone of locality, nil when zone-aware load balancing is off.
+func zoneConfig(locality config.Locality) *lbalgo.ZoneConfig {
+    if !locality.Enabled() {
+        return nil
+    }
+    return &lbalgo.ZoneConfig{
+        Region:   locality.Region,
+        Zone:     locality.Zone,
+        MinLocal: locality.MinLocal,
+        Overflow: locality.Overflow,
+    }
+}
+
+// cookieConfig returns the sticky cookie of cookie, with a random secret when it has none, nil when clients aren't
+// pinned with a cookie.
+func cookieConfig(cookie config.Cookie) (*lbalgo.CookieConfig, error) {
+    if !cookie.Enabled() {
+        return nil, nil
+    }
+    return newCookie(lbalgo.CookieConfig{
+        Mode:   cookie.Mode,
+        Name:   cookie.Name,
+        Secret: []byte(cookie.Secret),
+    })
+}
+
+// backendServer creates the server of a static backend.
+func backendServer(backend config.Backend) *model.BEServer {
+    srv := model.NewBEServer(backend.Address, backend.Weight)
+    srv.Priority = backend.Priority
+    srv.Region = backend.Region
+    srv.Zone = backend.Zone
+    return srv
+}
+
+// AddServer registers srv in the default pool without probing it, it takes traffic once a scan finds it healthy.
+// A server that is already registered is left as is.
+func (l *LoadBalancer) AddServer(srv *model.BEServer) {
+    l.Lock()
+    defer l.Unlock()
+
+    l.addServer(l.Pool, srv)
+}
+
+// addServer registers srv down in pool unless it's registered already, in any pool. Callers must hold the lock.
+func (l *LoadBalancer) addServer(pool *Pool, srv *model.BEServer) {
+    if registered, _ := l.serverPool(srv.Address); registered != nil {
+        return
+    }
+    pool.DownServers[srv.Address] = srv
+}
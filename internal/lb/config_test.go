@@ -0,0 +1,68 @@
+package lb
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/internal/lbalgo"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestNewFromConfig(t *testing.T) {
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        if req.URL.Path != "/healthz" {
+            w.WriteHeader(http.StatusNotFound)
+        }
+    }))
+    defer backend.Close()
+
+    cfg, err := config.Parse([]byte(`
+listeners:
+  - address: ":8080"
+pools:
+  - algorithm: WRR
+    backends:
+      - address: ` + backend.URL + `
+        weight: 3
+        priority: 1
+health_check:
+  interval: 2s
+  timeout: 1s
+  path: /healthz
+timeouts:
+  backend: 30s
+cookie:
+  mode: insert
+`))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    l, err := NewFromConfig(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(l.Listeners) != 1 || l.Listeners[0] != ":8080" {
+        t.Errorf("error configuring listeners: expected %v, got %v.\n", []string{":8080"}, l.Listeners)
+    }
+    if l.ScanPeriod != 2*time.Second || l.HealthPath != "/healthz" || l.HealthTimeout != time.Second || l.Timeout != 30*time.Second {
+        t.Errorf("error configuring health check and timeouts: got %s %s %s %s.\n", l.ScanPeriod, l.HealthPath, l.HealthTimeout, l.Timeout)
+    }
+    if l.AlgoBrief() != "WRR" {
+        t.Errorf("error configuring algorithm: expected %s, got %s.\n", "WRR", l.AlgoBrief())
+    }
+    if _, ok := l.AlgoDriver().(*lbalgo.CookieSticky); !ok {
+        t.Errorf("error configuring sticky cookie: expected a *lbalgo.CookieSticky, got %T.\n", l.AlgoDriver())
+    }
+
+    // The static backend takes traffic once it's probed on the configured path.
+    srv, ok := l.DownServers[backend.URL]
+    if !ok || srv.Weight != 3 || srv.Priority != 1 {
+        t.Fatalf("error adding static backend: expected %s down with weight 3 and priority 1, got %#v.\n", backend.URL, srv)
+    }
+    l.scanServers()
+    if _, ok = l.AliveServers[backend.URL]; !ok {
+        t.Errorf("error probing static backend: expected %s to be alive.\n", backend.URL)
+    }
+}
@@ -0,0 +1,225 @@
+package lb
+
+import (
+    "LoadBalancer/internal/discovery"
+    "LoadBalancer/internal/lb/response"
+    "LoadBalancer/pkg/model"
+    "fmt"
+    "log"
+    "net/http"
+    "sort"
+    "time"
+)
+
+// discovered is what a discovery provider registered, and how its last update went.
+type discovered struct {
+    pool     string          // Pool the servers are registered in, the default pool when empty.
+    servers  map[string]bool // Addresses of the servers registered by the provider.
+    vanished map[string]bool // Addresses of the servers the provider dropped, draining until they're deregistered.
+    status   DiscoveryStatus
+}
+
+// DiscoveryStatus describes the last update of a discovery provider.
+type DiscoveryStatus struct {
+    Provider string    `json:"provider"`
+    Servers  int       `json:"servers"` // Servers registered by the provider.
+    Updated  time.Time `json:"updated"` // Last successful update.
+    Error    string    `json:"error,omitempty"`
+}
+
+// Discover registers the servers found by provider and reconciles them with every update, until the load balancer is
+// closed. An update that failed leaves the servers as they are.
+func (l *LoadBalancer) Discover(provider discovery.Provider) {
+    updates := make(chan discovery.Update)
+    go provider.Run(l.discoveryDone, updates)
+    go func() {
+        for {
+            select {
+            case <-l.discoveryDone:
+                return
+            case update := <-updates:
+                if update.Err != nil {
+                    l.discoveryFailed(provider.Name(), update.Err)
+                    continue
+                }
+                if changes := l.Reconcile(provider.Name(), update.Servers, update.Drain); len(changes) > 0 {
+                    log.Printf("Discovery %s: %s.\n", provider.Name(), changes)
+                }
+            }
+        }
+    }()
+}
+
+// discoveryState returns what the provider name registered. Callers must hold the lock.
+func (l *LoadBalancer) discoveryState(name string) *discovered {
+    state, ok := l.discovered[name]
+    if !ok {
+        state = &discovered{
+            servers:  make(map[string]bool),
+            vanished: make(map[string]bool),
+            status:   DiscoveryStatus{Provider: name},
+        }
+        l.discovered[name] = state
+    }
+    return state
+}
+
+// discoveryFailed records that the last update of provider name failed.
+func (l *LoadBalancer) discoveryFailed(name string, err error) {
+    l.Lock()
+    defer l.Unlock()
+
+    l.discoveryState(name).status.Error = err.Error()
+    log.Printf("Discovery %s failed, servers left as they are: %v\n", name, err)
+}
+
+// Reconcile makes the servers registered by provider name in its pool the given ones, and returns the changes it made.
+// New servers are registered down until a scan finds them healthy, and the ones whose weight, priority or locality
+// changed are updated, keeping their state. The ones that are gone are deregistered, unless drain is set and they have
+// requests in flight: then they're drained, and deregistered once the last request completes.
+// Servers registered otherwise, e.g. static backends or through the API, are left to their owner.
+func (l *LoadBalancer) Reconcile(name string, servers []*model.BEServer, drain bool) []string {
+    l.Lock()
+    defer l.Unlock()
+
+    state := l.discoveryState(name)
+    pool, ok := l.poolNamed(state.pool)
+    if !ok {
+        state.status.Error = fmt.Sprintf("pool %s unknown", state.pool)
+        return nil
+    }
+    var changes []string
+    added, updated := false, false
+    next := make(map[string]bool, len(servers))
+    for _, srv := range servers {
+        next[srv.Address] = true
+        prevPool, prev := l.serverPool(srv.Address)
+
+        switch {
+        case prevPool == nil:
+            l.addServer(pool, srv)
+            state.servers[srv.Address] = true
+            changes = append(changes, fmt.Sprintf("%s added", srv.Address))
+            added = true
+            continue
+        case !state.servers[srv.Address] || prevPool != pool:
+            // Registered otherwise.
+            continue
+        }
+
+        if state.vanished[srv.Address] {
+            // Back before it was drained.
+            delete(state.vanished, srv.Address)
+            l.tracker(srv.Address).vanished.Store(false)
+            pool.copyServer(srv.Address).Draining = false
+            changes = append(changes, fmt.Sprintf("%s back", srv.Address))
+            updated = true
+        }
+        if prev.Weight != srv.Weight || prev.Priority != srv.Priority || prev.Region != srv.Region || prev.Zone != srv.Zone {
+            l.updateServer(pool, srv)
+            changes = append(changes, fmt.Sprintf("%s updated", srv.Address))
+            updated = true
+        }
+    }
+    for addr := range state.servers {
+        switch {
+        case next[addr] || state.vanished[addr]:
+        case drain && l.connections(addr).Load() > 0:
+            if _, ok := pool.AliveServers[addr]; ok {
+                pool.copyServer(addr).Draining = true
+            }
+            state.vanished[addr] = true
+            l.tracker(addr).vanished.Store(true)
+            changes = append(changes, fmt.Sprintf("%s draining", addr))
+        default:
+            l.deregister(addr)
+            delete(state.servers, addr)
+            changes = append(changes, fmt.Sprintf("%s removed", addr))
+        }
+    }
+    state.status.Servers = len(state.servers)
+    state.status.Updated = time.Now()
+    state.status.Error = ""
+    sort.Strings(changes)
+
+    if updated {
+        // The algorithms keep the servers they know of, rebuild it for the new weights.
+        l.rebuildAlgo(pool)
+    } else if len(changes) > 0 {
+        l.renew(pool)
+    }
+    if added {
+        l.requestScan()
+    }
+    return changes
+}
+
+// removeVanished deregisters address if a discovery provider dropped it and it has no request in flight anymore.
+func (l *LoadBalancer) removeVanished(address string) {
+    l.Lock()
+    defer l.Unlock()
+
+    for name, state := range l.discovered {
+        if !state.vanished[address] || l.connections(address).Load() > 0 {
+            continue
+        }
+        pool := l.deregister(address)
+        delete(state.servers, address)
+        delete(state.vanished, address)
+        state.status.Servers = len(state.servers)
+        if pool != nil {
+            l.renew(pool)
+        }
+        log.Printf("Discovery %s: %s drained and removed.\n", name, address)
+    }
+}
+
+// rebuildAlgo swaps in a new instance of the algorithm of pool. Callers must hold the lock.
+func (l *LoadBalancer) rebuildAlgo(pool *Pool) {
+    // The parameters were checked when the algorithm was created.
+    next, err := l.newAlgo(pool, pool.AlgoBrief(), pool.AlgoParams())
+    if err != nil {
+        log.Println(err)
+        return
+    }
+    l.swapAlgo(pool, next, pool.AlgoBrief(), pool.AlgoParams())
+}
+
+// isDiscovered reports whether address was registered by a discovery provider. Callers must hold the lock.
+func (l *LoadBalancer) isDiscovered(address string) bool {
+    for _, state := range l.discovered {
+        if state.servers[address] {
+            return true
+        }
+    }
+    return false
+}
+
+// DiscoveryList returns the status of the discovery providers sorted by name.
+func (l *LoadBalancer) DiscoveryList() []DiscoveryStatus {
+    l.RLock()
+    defer l.RUnlock()
+
+    statuses := make([]DiscoveryStatus, 0, len(l.discovered))
+    for _, state := range l.discovered {
+        statuses = append(statuses, state.status)
+    }
+    sort.Slice(statuses, func(i, j int) bool {
+        return statuses[i].Provider < statuses[j].Provider
+    })
+    return statuses
+}
+
+// Discovery is a handler that is used by endpoint '/admin/discovery'.
+// It lists the discovery providers, the servers they registered and the error of their last update.
+func (l *LoadBalancer) Discovery(w http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodGet {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s, got %s.", http.MethodGet, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+        return
+    }
+    response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(l.DiscoveryList()))
+}
@@ -0,0 +1,134 @@
+package lb
+
+import (
+    "LoadBalancer/internal/discovery"
+    "LoadBalancer/pkg/model"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestLoadBalancer_Reconcile(t *testing.T) {
+    l, err := New(0, 10, "WRR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Registered through the API, left alone by the provider.
+    l.AliveServers["Address Z"] = model.NewBEServer("Address Z", 1)
+
+    changes := l.Reconcile("file:servers.txt", []*model.BEServer{
+        model.NewBEServer("Address A", 1),
+        model.NewBEServer("Address B", 1),
+        model.NewBEServer("Address Z", 9),
+    }, false)
+    expected := []string{"Address A added", "Address B added"}
+    if strings.Join(changes, ",") != strings.Join(expected, ",") {
+        t.Errorf("error reconciling servers: expected %v, got %v.\n", expected, changes)
+    }
+    if len(l.DownServers) != 2 || l.AliveServers["Address Z"].Weight != 1 {
+        t.Errorf("error reconciling servers: expected A and B down, Z untouched.\n")
+    }
+
+    // A comes up, then its weight changes and B goes away.
+    l.AliveServers["Address A"] = l.DownServers["Address A"]
+    delete(l.DownServers, "Address A")
+    l.AliveServers["Address A"].Draining = true
+    changes = l.Reconcile("file:servers.txt", []*model.BEServer{model.NewBEServer("Address A", 4)}, false)
+    expected = []string{"Address A updated", "Address B removed"}
+    if strings.Join(changes, ",") != strings.Join(expected, ",") {
+        t.Errorf("error reconciling servers: expected %v, got %v.\n", expected, changes)
+    }
+    if srv := l.AliveServers["Address A"]; srv.Weight != 4 || !srv.Draining {
+        t.Errorf("error updating server: expected A draining with weight 4, got %#v.\n", srv)
+    }
+    if _, ok := l.DownServers["Address B"]; ok {
+        t.Errorf("error removing server: expected B to be deregistered.\n")
+    }
+
+    // A failed update leaves the servers as they are.
+    l.discoveryFailed("file:servers.txt", errors.New("servers.txt:1: invalid weight"))
+    statuses := l.DiscoveryList()
+    if len(statuses) != 1 || statuses[0].Servers != 1 || statuses[0].Error == "" {
+        t.Errorf("error reporting discovery status: got %#v.\n", statuses)
+    }
+    if _, ok := l.AliveServers["Address A"]; !ok {
+        t.Errorf("error keeping servers on failure: expected A to stay registered.\n")
+    }
+}
+
+func TestLoadBalancer_Discover(t *testing.T) {
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+    defer backend.Close()
+    path := filepath.Join(t.TempDir(), "servers.txt")
+    if err := os.WriteFile(path, []byte(backend.URL+" 2\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer close(l.discoveryDone)
+    l.Discover(discovery.NewFile(path, 10*time.Millisecond))
+
+    deadline := time.Now().Add(time.Second)
+    for len(l.DiscoveryList()) == 0 || l.DiscoveryList()[0].Servers != 1 {
+        if time.Now().After(deadline) {
+            t.Fatalf("error discovering servers: expected %s to be registered.\n", backend.URL)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    rec := httptest.NewRecorder()
+    l.Discovery(rec, httptest.NewRequest(http.MethodGet, "/admin/discovery", nil))
+    expected := `"provider":"file:` + path + `","servers":1`
+    if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), expected) {
+        t.Errorf("error listing discovery: expected %s, got %d %s.\n", expected, rec.Code, rec.Body.String())
+    }
+}
+
+func TestLoadBalancer_ReconcileDrain(t *testing.T) {
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    servers := []*model.BEServer{model.NewBEServer("Address A", 1), model.NewBEServer("Address B", 1)}
+    l.Reconcile("dns:web.example.com", servers, true)
+    for _, srv := range servers {
+        l.AliveServers[srv.Address] = srv
+        delete(l.DownServers, srv.Address)
+    }
+
+    // A vanishes while serving a request, B while idle.
+    l.connections("Address A").Add(1)
+    changes := l.Reconcile("dns:web.example.com", nil, true)
+    expected := []string{"Address A draining", "Address B removed"}
+    if strings.Join(changes, ",") != strings.Join(expected, ",") {
+        t.Errorf("error reconciling servers: expected %v, got %v.\n", expected, changes)
+    }
+    if srv, ok := l.AliveServers["Address A"]; !ok || !srv.Draining {
+        t.Fatalf("error draining vanished server: expected A to stay registered and draining.\n")
+    }
+
+    // The last request of A completes.
+    tracker := l.tracker("Address A")
+    if !tracker.draining.Load() || !tracker.vanished.Load() {
+        t.Errorf("error tracking vanished server: expected A to be draining and vanished.\n")
+    }
+    tracker.requests.Add(-1)
+    l.reportDrained("Address A", tracker)
+    if _, ok := l.AliveServers["Address A"]; ok {
+        t.Errorf("error removing drained server: expected A to be deregistered.\n")
+    }
+    if _, ok := l.inflight.Load("Address A"); ok {
+        t.Errorf("error removing drained server: expected the tracker of A to be deleted.\n")
+    }
+    if statuses := l.DiscoveryList(); statuses[0].Servers != 0 {
+        t.Errorf("error removing drained server: expected no server left, got %d.\n", statuses[0].Servers)
+    }
+}
@@ -0,0 +1,178 @@
+package lb
+
+import (
+    "LoadBalancer/internal/lb/response"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "sync/atomic"
+    "time"
+)
+
+var ErrUnknownServer = errors.New("error unknown server")
+
+// drainPoll is how often a waiting drain request checks the connections of the server.
+const drainPoll = 100 * time.Millisecond
+
+// tracker counts the requests in flight to a server. It also tells the request that completes last, without taking
+// the lock, whether the server was waiting for it to drain.
+type tracker struct {
+    requests atomic.Int64
+    draining atomic.Bool // Alive and draining, kept up to date by watchDrains.
+    vanished atomic.Bool // Dropped by a discovery provider, deregistered once drained.
+}
+
+// tracker returns the tracker of the server at address.
+func (l *LoadBalancer) tracker(address string) *tracker {
+    t, _ := l.inflight.LoadOrStore(address, new(tracker))
+    return t.(*tracker)
+}
+
+// connections returns the counter of the requests in flight to address.
+func (l *LoadBalancer) connections(address string) *atomic.Int64 {
+    return &l.tracker(address).requests
+}
+
+// watchDrains marks the alive draining servers of pool in their trackers. Callers must hold the lock.
+func (l *LoadBalancer) watchDrains(pool *Pool) {
+    for addr, srv := range pool.AliveServers {
+        l.tracker(addr).draining.Store(srv.Draining)
+    }
+    for addr := range pool.DownServers {
+        l.tracker(addr).draining.Store(false)
+    }
+}
+
+// SetDraining sets or clears the draining state of a registered server and renews the algorithm right away.
+// A draining server gets no new clients, the requests of its sticky clients and the ones in flight go on.
+func (l *LoadBalancer) SetDraining(address string, draining bool) error {
+    defer l.save()
+    l.Lock()
+    defer l.Unlock()
+
+    pool, _ := l.serverPool(address)
+    if pool == nil {
+        return ErrUnknownServer
+    }
+    pool.copyServer(address).Draining = draining
+    l.renew(pool)
+    l.persist()
+    return nil
+}
+
+// DrainRequest is used for draining a backend server.
+type DrainRequest struct {
+    Address  string `json:"address"`
+    Draining bool   `json:"draining"`
+}
+
+// DrainResponse describes the draining state of a backend server.
+type DrainResponse struct {
+    Address     string `json:"address"`
+    Draining    bool   `json:"draining"`
+    Connections int64  `json:"connections"`
+    Drained     bool   `json:"drained"` // Draining and no request in flight, the server can be stopped.
+}
+
+// drainResponse describes the draining state of address.
+func (l *LoadBalancer) drainResponse(address string) (DrainResponse, error) {
+    l.RLock()
+    pool, srv := l.serverPool(address)
+    var draining bool
+    if pool != nil {
+        draining = srv.Draining
+    }
+    l.RUnlock()
+
+    if pool == nil {
+        return DrainResponse{}, ErrUnknownServer
+    }
+    connections := l.connections(address).Load()
+    return DrainResponse{
+        Address:     address,
+        Draining:    draining,
+        Connections: connections,
+        Drained:     draining && connections == 0,
+    }, nil
+}
+
+// Drain is a handler that is used by endpoint '/admin/drain'.
+// POST sets or clears the draining state of a server. GET returns the state of the server given by query parameter
+// address; with query parameter wait, e.g. wait=30s, it responds as soon as the server is drained or the time is up.
+func (l *LoadBalancer) Drain(w http.ResponseWriter, req *http.Request) {
+    switch req.Method {
+    case http.MethodGet:
+        address := req.URL.Query().Get("address")
+        var wait time.Duration
+        if query := req.URL.Query().Get("wait"); query != "" {
+            var err error
+            if wait, err = time.ParseDuration(query); err != nil {
+                response.WriteJsonResponse(w, http.StatusBadRequest, response.NewErrorResponse(err))
+                return
+            }
+        }
+
+        state, err := l.waitDrained(req, address, wait)
+        if err != nil {
+            l.writeUnknownServer(w, address)
+            return
+        }
+        response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(state))
+    case http.MethodPost:
+        var p DrainRequest
+        decoder := json.NewDecoder(req.Body)
+        decoder.DisallowUnknownFields()
+        if err := decoder.Decode(&p); err != nil {
+            response.WriteJsonResponse(w, http.StatusBadRequest, response.NewErrorResponse(err))
+            return
+        }
+
+        if err := l.SetDraining(p.Address, p.Draining); err != nil {
+            l.writeUnknownServer(w, p.Address)
+            return
+        }
+        log.Printf("Server %s draining: %t.\n", p.Address, p.Draining)
+
+        state, _ := l.drainResponse(p.Address)
+        response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(state))
+    default:
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s or %s, got %s.", http.MethodGet, http.MethodPost, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+    }
+}
+
+// waitDrained returns the draining state of address once it's drained, wait elapsed or the client went away.
+func (l *LoadBalancer) waitDrained(req *http.Request, address string, wait time.Duration) (DrainResponse, error) {
+    deadline := time.After(wait)
+    ticker := time.NewTicker(drainPoll)
+    defer ticker.Stop()
+
+    for {
+        state, err := l.drainResponse(address)
+        if err != nil || state.Drained || !state.Draining || wait <= 0 {
+            return state, err
+        }
+
+        select {
+        case <-deadline:
+            return state, nil
+        case <-req.Context().Done():
+            return state, nil
+        case <-ticker.C:
+        }
+    }
+}
+
+// writeUnknownServer responds that address isn't registered.
+func (l *LoadBalancer) writeUnknownServer(w http.ResponseWriter, address string) {
+    responsePayload := response.NewFailResponse(
+        struct {
+            Title string `json:"title"`
+        }{Title: fmt.Sprintf("%s not registered.", address)})
+    response.WriteJsonResponse(w, http.StatusNotFound, responsePayload)
+}
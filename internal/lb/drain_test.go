@@ -0,0 +1,118 @@
+package lb
+
+import (
+    "LoadBalancer/pkg/model"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestLoadBalancer_SetDraining(t *testing.T) {
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.AliveServers = model.BEServers{
+        "Address A": model.NewBEServer("Address A", 1),
+        "Address B": model.NewBEServer("Address B", 1),
+    }
+    l.AlgoDriver().Renew(l.AliveServers)
+    registered := l.AliveServers["Address A"]
+
+    if err = l.SetDraining("Address A", true); err != nil {
+        t.Fatal(err)
+    }
+    // The server is replaced, requests in flight may still read the registered one.
+    if !l.AliveServers["Address A"].Draining || registered.Draining {
+        t.Errorf("error setting draining: expected a draining copy of %s, got %#v.\n", "Address A", registered)
+    }
+    for i := 0; i < 4; i++ {
+        if chosen, _ := l.AlgoDriver().ChooseServer(new(http.Request)); chosen.Address != "Address B" {
+            t.Errorf("error choosing server: expected %s, got %s.\n", "Address B", chosen.Address)
+        }
+    }
+
+    if err = l.SetDraining("Address Z", true); !errors.Is(err, ErrUnknownServer) {
+        t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrUnknownServer, err)
+    }
+}
+
+func TestLoadBalancer_Drain(t *testing.T) {
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.AliveServers = model.BEServers{"Address A": model.NewBEServer("Address A", 1)}
+
+    rec := httptest.NewRecorder()
+    l.Drain(rec, httptest.NewRequest(http.MethodPost, "/admin/drain", strings.NewReader(`{"address": "Address A", "draining": true}`)))
+    if rec.Code != http.StatusOK || !l.AliveServers["Address A"].Draining {
+        t.Fatalf("error draining server: expected status %d, got %d.\n", http.StatusOK, rec.Code)
+    }
+
+    // A request is still in flight, it completes while the client waits.
+    l.connections("Address A").Add(1)
+    go func() {
+        time.Sleep(3 * drainPoll)
+        l.connections("Address A").Add(-1)
+    }()
+
+    rec = httptest.NewRecorder()
+    l.Drain(rec, httptest.NewRequest(http.MethodGet, "/admin/drain?address=Address+A&wait=5s", nil))
+    expected := `"connections":0,"drained":true`
+    if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), expected) {
+        t.Errorf("error waiting for drain: expected %s, got %d %s.\n", expected, rec.Code, rec.Body.String())
+    }
+
+    testCases := []struct {
+        method       string
+        target       string
+        body         string
+        expectedCode int
+    }{
+        {method: http.MethodGet, target: "/admin/drain?address=Address+Z", expectedCode: http.StatusNotFound},
+        {method: http.MethodGet, target: "/admin/drain?address=Address+A&wait=soon", expectedCode: http.StatusBadRequest},
+        {method: http.MethodPost, target: "/admin/drain", body: `{"address": "Address Z", "draining": true}`, expectedCode: http.StatusNotFound},
+        {method: http.MethodPost, target: "/admin/drain", body: `{"server": "Address A"}`, expectedCode: http.StatusBadRequest},
+        {method: http.MethodDelete, target: "/admin/drain", expectedCode: http.StatusMethodNotAllowed},
+    }
+    for _, tc := range testCases {
+        rec = httptest.NewRecorder()
+        l.Drain(rec, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+        if rec.Code != tc.expectedCode {
+            t.Errorf("%s %s: expected status %d, got %d.\n", tc.method, tc.target, tc.expectedCode, rec.Code)
+        }
+    }
+}
+
+func TestLoadBalancer_RegisterDraining(t *testing.T) {
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+    defer backend.Close()
+
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.SlowStart = time.Minute
+    since := time.Now().Add(-30 * time.Second)
+    l.AliveServers[backend.URL] = &model.BEServer{
+        Address:   backend.URL,
+        Weight:    1,
+        Draining:  true,
+        SlowStart: model.SlowStart{Since: since, Window: time.Minute},
+    }
+
+    // The server registers again while it drains, it keeps draining and its slow-start goes on.
+    rec := httptest.NewRecorder()
+    l.Register(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"address": "`+backend.URL+`", "weight": 3}`)))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("error registering server: expected status %d, got %d.\n", http.StatusOK, rec.Code)
+    }
+    srv, ok := l.AliveServers[backend.URL]
+    if !ok || !srv.Draining || srv.Weight != 3 || !srv.SlowStart.Since.Equal(since) {
+        t.Errorf("error registering server: expected weight %d, draining and slow-starting since %s, got %#v.\n", 3, since, srv)
+    }
+}
@@ -0,0 +1,218 @@
+package lb
+
+import (
+    "LoadBalancer/internal/lb/response"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+)
+
+var ErrUnknownLease = errors.New("error unknown or expired lease")
+
+// leaseCheck is how often expired leases are looked for.
+const leaseCheck = time.Second
+
+// lease keeps a registration alive as long as the server renews it within TTL.
+type lease struct {
+    ID      string
+    Address string
+    TTL     time.Duration
+    Expires time.Time
+}
+
+// grantLease gives the registration of address a lease of ttl, replacing its previous lease.
+// Callers must hold the lock.
+func (l *LoadBalancer) grantLease(address string, ttl time.Duration) (*lease, error) {
+    id := make([]byte, 16)
+    if _, err := rand.Read(id); err != nil {
+        return nil, err
+    }
+
+    l.revokeLease(address)
+    ls := &lease{
+        ID:      hex.EncodeToString(id),
+        Address: address,
+        TTL:     ttl,
+        Expires: time.Now().Add(ttl),
+    }
+    l.leases[ls.ID] = ls
+    return ls, nil
+}
+
+// revokeLease drops the lease of address, if any. Callers must hold the lock.
+func (l *LoadBalancer) revokeLease(address string) {
+    for id, ls := range l.leases {
+        if ls.Address == address {
+            delete(l.leases, id)
+        }
+    }
+}
+
+// RenewLease extends the lease id by its TTL.
+func (l *LoadBalancer) RenewLease(id string) (time.Duration, error) {
+    l.Lock()
+    defer l.Unlock()
+
+    ls, ok := l.leases[id]
+    if !ok {
+        return 0, ErrUnknownLease
+    }
+    ls.Expires = time.Now().Add(ls.TTL)
+    return ls.TTL, nil
+}
+
+// RemoveServer removes a registered server and renews the algorithm right away. Requests in flight go on.
+func (l *LoadBalancer) RemoveServer(address string) error {
+    defer l.save()
+    l.Lock()
+    defer l.Unlock()
+
+    pool := l.deregister(address)
+    if pool == nil {
+        return ErrUnknownServer
+    }
+    l.renew(pool)
+    l.persist()
+    return nil
+}
+
+// deregister removes address, its lease and its tracker, it returns the pool address was registered in, nil when it
+// wasn't. The requests in flight hold on to the tracker until they complete. Callers must hold the lock.
+func (l *LoadBalancer) deregister(address string) *Pool {
+    l.revokeLease(address)
+    pool, _ := l.serverPool(address)
+    if pool == nil {
+        return nil
+    }
+    delete(pool.AliveServers, address)
+    delete(pool.DownServers, address)
+    l.inflight.Delete(address)
+    return pool
+}
+
+// expireLeases deregisters the servers whose lease wasn't renewed in time.
+func (l *LoadBalancer) expireLeases() {
+    defer l.save()
+    l.Lock()
+    defer l.Unlock()
+
+    now := time.Now()
+    expired := make(map[*Pool]bool)
+    for _, ls := range l.leases {
+        if now.After(ls.Expires) {
+            log.Printf("Lease of %s expired, server deregistered.\n", ls.Address)
+            if pool := l.deregister(ls.Address); pool != nil {
+                expired[pool] = true
+            }
+        }
+    }
+    for pool := range expired {
+        l.renew(pool)
+    }
+    if len(expired) > 0 {
+        l.persist()
+    }
+}
+
+// LeaseRequest is used for renewing a lease.
+type LeaseRequest struct {
+    Lease string `json:"lease"`
+}
+
+// Heartbeat is a handler that is used by endpoint '/heartbeat'. It renews the lease of a registration.
+func (l *LoadBalancer) Heartbeat(w http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodPost {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s, got %s.", http.MethodPost, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+        return
+    }
+
+    var p LeaseRequest
+    decoder := json.NewDecoder(req.Body)
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&p); err != nil {
+        response.WriteJsonResponse(w, http.StatusBadRequest, response.NewErrorResponse(err))
+        return
+    }
+
+    ttl, err := l.RenewLease(p.Lease)
+    if err != nil {
+        // The server has to register again.
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Lease %s unknown or expired, register again.", p.Lease)})
+        response.WriteJsonResponse(w, http.StatusNotFound, responsePayload)
+        return
+    }
+    responsePayload := response.NewSuccessResponse(
+        struct {
+            Lease string `json:"lease"`
+            TTL   int    `json:"ttl"`
+        }{
+            Lease: p.Lease,
+            TTL:   int(ttl / time.Second),
+        })
+    response.WriteJsonResponse(w, http.StatusOK, responsePayload)
+}
+
+// DeregisterRequest is used for deregistering a backend server, by lease or by address.
+type DeregisterRequest struct {
+    Lease   string `json:"lease,omitempty"`
+    Address string `json:"address,omitempty"`
+}
+
+// Deregister is a handler that is used by endpoint '/deregister'.
+func (l *LoadBalancer) Deregister(w http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodPost {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s, got %s.", http.MethodPost, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+        return
+    }
+
+    var p DeregisterRequest
+    decoder := json.NewDecoder(req.Body)
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&p); err != nil {
+        response.WriteJsonResponse(w, http.StatusBadRequest, response.NewErrorResponse(err))
+        return
+    }
+
+    address := p.Address
+    if p.Lease != "" {
+        l.RLock()
+        ls, ok := l.leases[p.Lease]
+        l.RUnlock()
+        if !ok {
+            responsePayload := response.NewFailResponse(
+                struct {
+                    Title string `json:"title"`
+                }{Title: fmt.Sprintf("Lease %s unknown or expired.", p.Lease)})
+            response.WriteJsonResponse(w, http.StatusNotFound, responsePayload)
+            return
+        }
+        address = ls.Address
+    }
+
+    if err := l.RemoveServer(address); err != nil {
+        l.writeUnknownServer(w, address)
+        return
+    }
+    log.Printf("Server %s deregistered.\n", address)
+    responsePayload := response.NewSuccessResponse(
+        struct {
+            Server string `json:"server"`
+        }{Server: address})
+    response.WriteJsonResponse(w, http.StatusOK, responsePayload)
+}
@@ -0,0 +1,112 @@
+package lb
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+// registerWithLease registers address with a lease of ttl seconds and returns the lease ID.
+func registerWithLease(t *testing.T, l *LoadBalancer, address string, ttl int) string {
+    t.Helper()
+
+    rec := httptest.NewRecorder()
+    body := fmt.Sprintf(`{"address": "%s", "weight": 1, "ttl": %d}`, address, ttl)
+    l.Register(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("error registering server: expected status %d, got %d.\n", http.StatusOK, rec.Code)
+    }
+
+    var resp struct {
+        Data struct {
+            Lease string `json:"lease"`
+            TTL   int    `json:"ttl"`
+        } `json:"data"`
+    }
+    if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+        t.Fatal(err)
+    }
+    if resp.Data.Lease == "" || resp.Data.TTL != ttl {
+        t.Fatalf("error registering server: expected a lease of %d seconds, got %#v.\n", ttl, resp.Data)
+    }
+    return resp.Data.Lease
+}
+
+func TestLoadBalancer_Heartbeat(t *testing.T) {
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+    defer backend.Close()
+
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    id := registerWithLease(t, l, backend.URL, 30)
+
+    // A heartbeat pushes the expiry back by the TTL.
+    l.leases[id].Expires = time.Now()
+    rec := httptest.NewRecorder()
+    l.Heartbeat(rec, httptest.NewRequest(http.MethodPost, "/heartbeat", strings.NewReader(`{"lease": "`+id+`"}`)))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("error renewing lease: expected status %d, got %d.\n", http.StatusOK, rec.Code)
+    }
+    l.expireLeases()
+    if _, ok := l.AliveServers[backend.URL]; !ok {
+        t.Fatalf("error renewing lease: expected %s to stay registered.\n", backend.URL)
+    }
+
+    // Missed heartbeats expire the registration.
+    l.leases[id].Expires = time.Now().Add(-time.Second)
+    l.expireLeases()
+    if _, ok := l.AliveServers[backend.URL]; ok {
+        t.Errorf("error expiring lease: expected %s to be deregistered.\n", backend.URL)
+    }
+    rec = httptest.NewRecorder()
+    l.Heartbeat(rec, httptest.NewRequest(http.MethodPost, "/heartbeat", strings.NewReader(`{"lease": "`+id+`"}`)))
+    if rec.Code != http.StatusNotFound {
+        t.Errorf("error renewing expired lease: expected status %d, got %d.\n", http.StatusNotFound, rec.Code)
+    }
+}
+
+func TestLoadBalancer_Deregister(t *testing.T) {
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+    defer backend.Close()
+
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    id := registerWithLease(t, l, backend.URL, 30)
+    l.renew(l.Pool)
+
+    rec := httptest.NewRecorder()
+    l.Deregister(rec, httptest.NewRequest(http.MethodPost, "/deregister", strings.NewReader(`{"lease": "`+id+`"}`)))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("error deregistering server: expected status %d, got %d.\n", http.StatusOK, rec.Code)
+    }
+    if _, ok := l.AliveServers[backend.URL]; ok || len(l.leases) != 0 {
+        t.Errorf("error deregistering server: expected %s and its lease to be removed.\n", backend.URL)
+    }
+    if _, err = l.AlgoDriver().ChooseServer(new(http.Request)); err == nil {
+        t.Errorf("error deregistering server: expected the algorithm to have no server left.\n")
+    }
+
+    testCases := []struct {
+        body         string
+        expectedCode int
+    }{
+        {body: `{"lease": "` + id + `"}`, expectedCode: http.StatusNotFound},
+        {body: `{"address": "` + backend.URL + `"}`, expectedCode: http.StatusNotFound},
+        {body: `{"server": "` + backend.URL + `"}`, expectedCode: http.StatusBadRequest},
+    }
+    for _, tc := range testCases {
+        rec = httptest.NewRecorder()
+        l.Deregister(rec, httptest.NewRequest(http.MethodPost, "/deregister", strings.NewReader(tc.body)))
+        if rec.Code != tc.expectedCode {
+            t.Errorf("%s: expected status %d, got %d.\n", tc.body, tc.expectedCode, rec.Code)
+        }
+    }
+}
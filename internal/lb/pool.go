@@ -0,0 +1,200 @@
+package lb
+
+import (
+    "LoadBalancer/internal/lb/response"
+    "LoadBalancer/internal/lbalgo"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+    "sync/atomic"
+    "time"
+)
+
+var ErrUnknownPool = errors.New("error unknown pool")
+
+// Pool is a named group of backend servers load balanced by its own algorithm, e.g. the servers of one service.
+// A server belongs to one pool only.
+type Pool struct {
+    Name          string
+    AliveServers  model.BEServers
+    DownServers   model.BEServers
+    HealthPath    string                     // Probed with a GET request on every server, healthy on status 200.
+    HealthTimeout time.Duration              // Time a probe may take, 0 for no limit.
+    Cookie        *lbalgo.CookieConfig       // Sticky cookie, nil when clients aren't pinned with a cookie.
+    algoDriver    atomic.Pointer[algoDriver] // Swapped at runtime by SwapAlgo.
+}
+
+// newPool creates an empty pool load balanced by algo, the algorithm algoBrief with params.
+func newPool(name string, algo lbalgo.LBAlgo, algoBrief string, params balancer.Params) *Pool {
+    p := &Pool{
+        Name:         name,
+        AliveServers: make(map[string]*model.BEServer),
+        DownServers:  make(map[string]*model.BEServer),
+    }
+    p.algoDriver.Store(&algoDriver{LBAlgo: algo, brief: strings.ToUpper(algoBrief), params: params})
+    return p
+}
+
+// AlgoDriver returns the algorithm in use.
+func (p *Pool) AlgoDriver() lbalgo.LBAlgo {
+    return p.algoDriver.Load().LBAlgo
+}
+
+// AlgoBrief returns the brief of the algorithm in use.
+func (p *Pool) AlgoBrief() string {
+    return p.algoDriver.Load().brief
+}
+
+// AlgoParams returns the parameters of the algorithm in use.
+func (p *Pool) AlgoParams() balancer.Params {
+    return p.algoDriver.Load().params
+}
+
+// AddPool adds an empty pool load balanced by algoBrief, with the health check settings of the default pool. Servers
+// join it by registering with its name.
+func (l *LoadBalancer) AddPool(name string, algoBrief string, params balancer.Params) error {
+    l.Lock()
+    defer l.Unlock()
+
+    if _, ok := l.pools[name]; ok {
+        return fmt.Errorf("pool %s already exists", name)
+    }
+    algo, err := newAlgo(algoBrief, params, l.Locality, nil, l.Seed)
+    if err != nil {
+        return err
+    }
+    pool := newPool(name, algo, algoBrief, params)
+    pool.HealthPath = l.HealthPath
+    pool.HealthTimeout = l.HealthTimeout
+    l.pools[name] = pool
+    l.publish()
+    return nil
+}
+
+// poolNamed returns the pool name, the default pool when name is empty. Callers must hold the lock.
+func (l *LoadBalancer) poolNamed(name string) (*Pool, bool) {
+    if name == "" {
+        return l.Pool, true
+    }
+    pool, ok := l.pools[name]
+    return pool, ok
+}
+
+// LookupPool returns the pool name, the default pool when name is empty.
+func (l *LoadBalancer) LookupPool(name string) (*Pool, error) {
+    l.RLock()
+    defer l.RUnlock()
+
+    pool, ok := l.poolNamed(name)
+    if !ok {
+        return nil, ErrUnknownPool
+    }
+    return pool, nil
+}
+
+// serverPool returns the registered server of address and its pool, nil when it isn't registered.
+// Callers must hold the lock.
+func (l *LoadBalancer) serverPool(address string) (*Pool, *model.BEServer) {
+    for _, pool := range l.pools {
+        if srv, ok := pool.AliveServers[address]; ok {
+            return pool, srv
+        }
+        if srv, ok := pool.DownServers[address]; ok {
+            return pool, srv
+        }
+    }
+    return nil, nil
+}
+
+// copyServer replaces the registered server of address in pool with a copy and returns it, to be changed instead of the
+// registered one that requests in flight may still read. Callers must hold the lock.
+func (pool *Pool) copyServer(address string) *model.BEServer {
+    servers := pool.AliveServers
+    prev, ok := servers[address]
+    if !ok {
+        servers = pool.DownServers
+        prev = servers[address]
+    }
+    srv := *prev
+    servers[address] = &srv
+    return &srv
+}
+
+// sortedPools returns the pools sorted by name. Callers must hold the lock.
+func (l *LoadBalancer) sortedPools() []*Pool {
+    pools := make([]*Pool, 0, len(l.pools))
+    for _, pool := range l.pools {
+        pools = append(pools, pool)
+    }
+    sort.Slice(pools, func(i, j int) bool {
+        return pools[i].Name < pools[j].Name
+    })
+    return pools
+}
+
+// renew renews the algorithm of pool with the servers that should take traffic. Callers must hold the lock.
+func (l *LoadBalancer) renew(pool *Pool) {
+    pool.AlgoDriver().Renew(l.activeServers(pool))
+    l.watchDrains(pool)
+}
+
+// PoolInfo describes a pool of backend servers.
+type PoolInfo struct {
+    Name    string          `json:"name"`
+    Default bool            `json:"default"` // Takes the requests that no route sends elsewhere.
+    Algo    string          `json:"algo"`
+    Params  balancer.Params `json:"params,omitempty"`
+    Hosts   []string        `json:"hosts,omitempty"` // Hosts of the virtual hosts of the pool.
+    Alive   int             `json:"alive"`
+    Down    int             `json:"down"`
+}
+
+// PoolList returns the pools sorted by name.
+func (l *LoadBalancer) PoolList() []PoolInfo {
+    l.RLock()
+    defer l.RUnlock()
+
+    pools := make([]PoolInfo, 0, len(l.pools))
+    for _, pool := range l.sortedPools() {
+        info := PoolInfo{
+            Name:    pool.Name,
+            Default: pool == l.Pool,
+            Algo:    pool.AlgoBrief(),
+            Params:  pool.AlgoParams(),
+            Alive:   len(pool.AliveServers),
+            Down:    len(pool.DownServers),
+        }
+        if l.router != nil {
+            info.Hosts = l.router.hosts(pool.Name)
+        }
+        pools = append(pools, info)
+    }
+    return pools
+}
+
+// Pools is a handler that is used by endpoint '/admin/pools'.
+// It lists the pools, their algorithm and how many of their servers are alive.
+func (l *LoadBalancer) Pools(w http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodGet {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s, got %s.", http.MethodGet, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+        return
+    }
+    response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(l.PoolList()))
+}
+
+// writeUnknownPool responds that the pool name doesn't exist.
+func (l *LoadBalancer) writeUnknownPool(w http.ResponseWriter, name string) {
+    responsePayload := response.NewFailResponse(
+        struct {
+            Title string `json:"title"`
+        }{Title: fmt.Sprintf("Pool %s unknown.", name)})
+    response.WriteJsonResponse(w, http.StatusNotFound, responsePayload)
+}
@@ -0,0 +1,256 @@
+package lb
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/internal/lbalgo"
+    "LoadBalancer/internal/registry"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// newPoolBackend starts a backend server that is healthy on path only.
+func newPoolBackend(t *testing.T, path string) *httptest.Server {
+    t.Helper()
+
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        if req.URL.Path != path {
+            w.WriteHeader(http.StatusNotFound)
+        }
+    }))
+    t.Cleanup(backend.Close)
+    return backend
+}
+
+func TestNewFromConfig_Pools(t *testing.T) {
+    api := newPoolBackend(t, "/ready")
+    static := newPoolBackend(t, "/health")
+
+    cfg, err := config.Parse([]byte(`
+pools:
+  - name: api
+    algorithm: LC
+    backends:
+      - address: ` + api.URL + `
+    health_check:
+      path: /ready
+    cookie:
+      mode: insert
+  - name: static
+    algorithm: WRR
+    backends:
+      - address: ` + static.URL + `
+`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    l, err := NewFromConfig(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if l.Name != "api" {
+        t.Errorf("error configuring default pool: expected %s, got %s.\n", "api", l.Name)
+    }
+    staticPool, err := l.LookupPool("static")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := l.AlgoDriver().(*lbalgo.CookieSticky); !ok {
+        t.Errorf("error configuring sticky cookie of pool api: expected a *lbalgo.CookieSticky, got %T.\n", l.AlgoDriver())
+    }
+    if staticPool.AlgoBrief() != "WRR" || staticPool.Cookie != nil {
+        t.Errorf("error configuring pool static: expected WRR without cookie, got %s %v.\n", staticPool.AlgoBrief(), staticPool.Cookie)
+    }
+    if _, err = l.LookupPool("billing"); err != ErrUnknownPool {
+        t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrUnknownPool, err)
+    }
+
+    // Every pool is probed on its own path.
+    l.scanServers()
+    if _, ok := l.AliveServers[api.URL]; !ok {
+        t.Errorf("error probing pool api: expected %s to be alive.\n", api.URL)
+    }
+    if _, ok := staticPool.AliveServers[static.URL]; !ok {
+        t.Errorf("error probing pool static: expected %s to be alive.\n", static.URL)
+    }
+
+    pools := make(map[string]string)
+    for _, info := range l.ServerList() {
+        pools[info.Address] = info.Pool
+    }
+    if len(pools) != 2 || pools[api.URL] != "api" || pools[static.URL] != "static" {
+        t.Errorf("error listing servers: expected %s in api and %s in static, got %v.\n", api.URL, static.URL, pools)
+    }
+}
+
+func TestLoadBalancer_RegisterPool(t *testing.T) {
+    backend := newPoolBackend(t, "/health")
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err = l.AddPool("api", "LC", nil); err != nil {
+        t.Fatal(err)
+    }
+    if err = l.AddPool("api", "RR", nil); err == nil {
+        t.Errorf("error adding pool: expected an error for a duplicate pool, got nil.\n")
+    }
+
+    register := func(pool string) int {
+        rec := httptest.NewRecorder()
+        body := fmt.Sprintf(`{"address": "%s", "weight": 1, "pool": "%s"}`, backend.URL, pool)
+        l.Register(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+        return rec.Code
+    }
+    if code := register("billing"); code != http.StatusNotFound {
+        t.Errorf("error registering in unknown pool: expected status %d, got %d.\n", http.StatusNotFound, code)
+    }
+    if code := register("api"); code != http.StatusOK {
+        t.Fatalf("error registering in pool api: expected status %d, got %d.\n", http.StatusOK, code)
+    }
+    api, _ := l.LookupPool("api")
+    if _, ok := api.AliveServers[backend.URL]; !ok || len(l.AliveServers) != 0 {
+        t.Errorf("error registering in pool api: expected %s alive in pool api only.\n", backend.URL)
+    }
+
+    // A server belongs to one pool.
+    if code := register(""); code != http.StatusConflict {
+        t.Errorf("error registering in a second pool: expected status %d, got %d.\n", http.StatusConflict, code)
+    }
+
+    // Admin endpoints find the server in its pool.
+    if err = l.SetDraining(backend.URL, true); err != nil || !api.AliveServers[backend.URL].Draining {
+        t.Errorf("error draining server of pool api: %v.\n", err)
+    }
+    if err = l.RemoveServer(backend.URL); err != nil || len(api.AliveServers) != 0 {
+        t.Errorf("error removing server of pool api: %v.\n", err)
+    }
+}
+
+func TestLoadBalancer_SwapPoolAlgo(t *testing.T) {
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err = l.AddPool("api", "RR", nil); err != nil {
+        t.Fatal(err)
+    }
+
+    if err = l.SwapPoolAlgo("api", "LC", nil); err != nil {
+        t.Fatal(err)
+    }
+    api, _ := l.LookupPool("api")
+    if api.AlgoBrief() != "LC" || l.AlgoBrief() != "RR" {
+        t.Errorf("error swapping algorithm of pool api: expected LC, and RR for the default pool, got %s and %s.\n", api.AlgoBrief(), l.AlgoBrief())
+    }
+    if err = l.SwapPoolAlgo("billing", "LC", nil); err != ErrUnknownPool {
+        t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrUnknownPool, err)
+    }
+
+    rec := httptest.NewRecorder()
+    l.Algo(rec, httptest.NewRequest(http.MethodGet, "/admin/algo?pool=api", nil))
+    if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"pool":"api","algo":"LC"`) {
+        t.Errorf("error describing algorithm of pool api: got %d %s.\n", rec.Code, rec.Body.String())
+    }
+}
+
+func TestLoadBalancer_ReloadPools(t *testing.T) {
+    parse := func(data string) *config.Config {
+        cfg, err := config.Parse([]byte(data))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return cfg
+    }
+    l, err := NewFromConfig(parse(`
+pools:
+  - name: api
+    backends:
+      - address: http://127.0.0.1:1
+  - name: static
+    backends:
+      - address: http://127.0.0.1:2
+`))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    changes, err := l.Reload(parse(`
+pools:
+  - name: static
+    backends:
+      - address: http://127.0.0.1:2
+      - address: http://127.0.0.1:1
+    health_check:
+      path: /ready
+  - name: billing
+    algorithm: LC
+`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := []string{
+        "pool api removed",
+        "pool billing added",
+        "pool static: backend http://127.0.0.1:1 added",
+        "pool static: health check updated",
+        "default pool static",
+    }
+    if strings.Join(changes, "\n") != strings.Join(expected, "\n") {
+        t.Errorf("error reloading pools: expected changes %q, got %q.\n", expected, changes)
+    }
+
+    // The backend moved from the removed pool to the new default one.
+    if l.Name != "static" || len(l.DownServers) != 2 || l.HealthPath != "/ready" {
+        t.Errorf("error reloading default pool: got %s with %d servers probed on %s.\n", l.Name, len(l.DownServers), l.HealthPath)
+    }
+    if _, err = l.LookupPool("api"); err != ErrUnknownPool {
+        t.Errorf("error removing pool api: expected %#v, got %#v.\n", ErrUnknownPool, err)
+    }
+    if billing, err := l.LookupPool("billing"); err != nil || billing.AlgoBrief() != "LC" {
+        t.Errorf("error adding pool billing: %v.\n", err)
+    }
+}
+
+func TestLoadBalancer_RestorePools(t *testing.T) {
+    store := registry.NewFileStore(filepath.Join(t.TempDir(), "registry.json"))
+    err := store.Save([]registry.Registration{
+        {Address: "http://127.0.0.1:1", Pool: "api", Weight: 1},
+        {Address: "http://127.0.0.1:2", Weight: 1},
+        {Address: "http://127.0.0.1:3", Pool: "billing", Weight: 1},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err = l.AddPool("api", "RR", nil); err != nil {
+        t.Fatal(err)
+    }
+    l.Store = store
+    if err = l.Restore(); err != nil {
+        t.Fatal(err)
+    }
+
+    api, _ := l.LookupPool("api")
+    if _, ok := api.DownServers["http://127.0.0.1:1"]; !ok {
+        t.Errorf("error restoring pool api: expected %s down.\n", "http://127.0.0.1:1")
+    }
+    if _, ok := l.DownServers["http://127.0.0.1:2"]; !ok {
+        t.Errorf("error restoring default pool: expected %s down.\n", "http://127.0.0.1:2")
+    }
+    // The pool of the last registration no longer exists.
+    l.RLock()
+    pool, _ := l.serverPool("http://127.0.0.1:3")
+    l.RUnlock()
+    if pool != nil {
+        t.Errorf("error restoring unknown pool: expected %s to be dropped, got pool %s.\n", "http://127.0.0.1:3", pool.Name)
+    }
+}
@@ -0,0 +1,60 @@
+package lb
+
+import (
+    "LoadBalancer/pkg/model"
+    "sort"
+)
+
+// DefaultFailoverThreshold is the default percentage of healthy members a tier needs to carry its traffic alone.
+const DefaultFailoverThreshold = 70
+
+// activeServers returns the alive servers of pool of the priority tiers that should take traffic. Callers must hold the
+// lock.
+func (l *LoadBalancer) activeServers(pool *Pool) model.BEServers {
+    return activeServers(pool.AliveServers, pool.DownServers, l.FailoverThreshold)
+}
+
+// activeServers returns the alive servers of the priority tiers that should take traffic.
+// Servers under maintenance are among the down ones. Tiers are visited from the highest priority down. The alive
+// servers of every visited tier are active, and the visit stops at the first tier whose healthy members make up at
+// least threshold percent of it.
+// Below that, the tier spills over to the next one. Once it recovers, the lower tiers are dropped again.
+func activeServers(alive, down model.BEServers, threshold int) model.BEServers {
+    healthy := make(map[int]int)
+    total := make(map[int]int)
+    for _, srv := range alive {
+        if !srv.Draining {
+            // Draining servers are leaving, they don't count towards the capacity of their tier.
+            healthy[srv.Priority]++
+        }
+        total[srv.Priority]++
+    }
+    for _, srv := range down {
+        if !srv.Maintenance {
+            // Servers under maintenance were taken out on purpose, they don't count against their tier.
+            total[srv.Priority]++
+        }
+    }
+
+    tiers := make([]int, 0, len(total))
+    for tier := range total {
+        tiers = append(tiers, tier)
+    }
+    sort.Ints(tiers)
+
+    lowestTier := 0
+    for _, tier := range tiers {
+        lowestTier = tier
+        if healthy[tier]*100 >= threshold*total[tier] {
+            break
+        }
+    }
+
+    active := make(model.BEServers)
+    for addr, srv := range alive {
+        if srv.Priority <= lowestTier {
+            active[addr] = srv
+        }
+    }
+    return active
+}
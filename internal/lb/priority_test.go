@@ -0,0 +1,109 @@
+package lb
+
+import (
+    "LoadBalancer/pkg/model"
+    "sort"
+    "testing"
+)
+
+func Test_activeServers(t *testing.T) {
+    server := func(priority int) *model.BEServer {
+        return &model.BEServer{Priority: priority}
+    }
+
+    testCases := []struct {
+        name     string
+        alive    model.BEServers
+        down     model.BEServers
+        expected []string
+    }{
+        {
+            name: "Healthy primary",
+            alive: model.BEServers{
+                "Primary A":   server(model.PriorityPrimary),
+                "Primary B":   server(model.PriorityPrimary),
+                "Primary C":   server(model.PriorityPrimary),
+                "Secondary A": server(model.PrioritySecondary),
+            },
+            down: model.BEServers{
+                "Primary D": server(model.PriorityPrimary), // 3 of 4 healthy is above 70%.
+            },
+            expected: []string{"Primary A", "Primary B", "Primary C"},
+        },
+        {
+            name: "Spill over to secondary",
+            alive: model.BEServers{
+                "Primary A":   server(model.PriorityPrimary),
+                "Secondary A": server(model.PrioritySecondary),
+                "DR A":        server(model.PriorityDisasterRecovery),
+            },
+            down: model.BEServers{
+                "Primary B": server(model.PriorityPrimary), // 1 of 2 healthy is below 70%.
+            },
+            expected: []string{"Primary A", "Secondary A"},
+        },
+        {
+            name: "Spill over to disaster recovery",
+            alive: model.BEServers{
+                "DR A": server(model.PriorityDisasterRecovery),
+            },
+            down: model.BEServers{
+                "Primary A":   server(model.PriorityPrimary),
+                "Secondary A": server(model.PrioritySecondary),
+            },
+            expected: []string{"DR A"},
+        },
+        {
+            name: "Every tier unhealthy",
+            alive: model.BEServers{
+                "Primary A":   server(model.PriorityPrimary),
+                "Secondary A": server(model.PrioritySecondary),
+            },
+            down: model.BEServers{
+                "Primary B":   server(model.PriorityPrimary),
+                "Secondary B": server(model.PrioritySecondary),
+            },
+            expected: []string{"Primary A", "Secondary A"},
+        },
+        {
+            name: "Draining primary",
+            alive: model.BEServers{
+                "Primary A":   server(model.PriorityPrimary),
+                "Primary B":   {Priority: model.PriorityPrimary, Draining: true}, // 1 of 2 accepting is below 70%.
+                "Secondary A": server(model.PrioritySecondary),
+            },
+            down:     model.BEServers{},
+            expected: []string{"Primary A", "Primary B", "Secondary A"},
+        },
+        {
+            name: "No primary tier",
+            alive: model.BEServers{
+                "Secondary A": server(model.PrioritySecondary),
+                "DR A":        server(model.PriorityDisasterRecovery),
+            },
+            down:     model.BEServers{},
+            expected: []string{"Secondary A"},
+        },
+    }
+
+    for _, tc := range testCases {
+        active := activeServers(tc.alive, tc.down, DefaultFailoverThreshold)
+        addresses := make([]string, 0)
+        for addr := range active {
+            addresses = append(addresses, addr)
+        }
+        sort.Strings(addresses)
+        sort.Strings(tc.expected)
+
+        if len(addresses) != len(tc.expected) {
+            t.Errorf("%s: expected %#v, got %#v.\n", tc.name, tc.expected, addresses)
+            continue
+        }
+        for n := range addresses {
+            if addresses[n] != tc.expected[n] {
+                t.Errorf("%s: expected %#v, got %#v.\n", tc.name, tc.expected, addresses)
+                break
+            }
+        }
+    }
+}
@@ -0,0 +1,273 @@
+package lb
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/internal/lbalgo"
+    "LoadBalancer/pkg/model"
+    "errors"
+    "fmt"
+    "reflect"
+)
+
+var ErrNoConfig = errors.New("error load balancer not created from a configuration")
+
+// poolReload is what a reload changes in a pool.
+type poolReload struct {
+    config                  config.Pool
+    prev                    config.Pool // Zero when the pool is new.
+    pool                    *Pool       // Nil until a new pool is created.
+    created                 bool
+    cookie                  *lbalgo.CookieConfig
+    next                    lbalgo.LBAlgo // Nil when the algorithm in use is kept.
+    added, removed, updated []config.Backend
+}
+
+// Reload applies cfg to the running load balancer in one go and returns the changes it made.
+// Pools are added or removed. Within every pool, static backends are added, removed or updated, the health check
+// settings are replaced, and the algorithm is rebuilt when its settings or the backends it weighs changed, keeping the
+// sticky clients. The failover, slow-start, route and virtual host settings are replaced.
+// Requests in flight finish with the servers and the algorithm that took them.
+// Listeners, timeouts, the registry and discovery only take effect at the next start.
+// An invalid cfg is rejected and the running configuration kept.
+func (l *LoadBalancer) Reload(cfg *config.Config) ([]string, error) {
+    if err := cfg.Validate(); err != nil {
+        return nil, err
+    }
+
+    defer l.save()
+    l.Lock()
+    defer l.Unlock()
+
+    prev := l.config
+    if prev == nil {
+        return nil, ErrNoConfig
+    }
+    var changes []string
+    // The changes of a pool are prefixed with its name when there are several.
+    several := len(prev.Pools) > 1 || len(cfg.Pools) > 1
+    note := func(pool string, format string, args ...any) {
+        change := fmt.Sprintf(format, args...)
+        if several {
+            change = fmt.Sprintf("pool %s: %s", pool, change)
+        }
+        changes = append(changes, change)
+    }
+
+    // 1. Build the new algorithms and routes first, nothing is changed if one fails.
+    locality := l.Locality
+    if prev.Locality != cfg.Locality {
+        locality = zoneConfig(cfg.Locality)
+    }
+    rebuildAll := prev.Locality != cfg.Locality || prev.Seed != cfg.Seed
+    prevPools := make(map[string]config.Pool, len(prev.Pools))
+    for _, pool := range prev.Pools {
+        prevPools[pool.Name] = pool
+    }
+    plans := make([]*poolReload, 0, len(cfg.Pools))
+    for _, pool := range cfg.Pools {
+        prevPool, ok := prevPools[pool.Name]
+        plan := &poolReload{config: pool, prev: prevPool, pool: l.pools[pool.Name]}
+        plan.added, plan.removed, plan.updated = diffBackends(prevPool.Backends, pool.Backends)
+
+        cookieChanged := !ok || prev.CookieOf(prevPool) != cfg.CookieOf(pool)
+        if cookieChanged {
+            var err error
+            if plan.cookie, err = cookieConfig(cfg.CookieOf(pool)); err != nil {
+                return nil, err
+            }
+        } else {
+            plan.cookie = plan.pool.Cookie
+        }
+
+        rebuild := rebuildAll || cookieChanged || prevPool.Algorithm != pool.Algorithm ||
+            !reflect.DeepEqual(prevPool.Params, pool.Params) || len(plan.updated) > 0
+        if rebuild {
+            var err error
+            if plan.next, err = newAlgo(pool.Algorithm, pool.Params, locality, plan.cookie, cfg.Seed); err != nil {
+                return nil, err
+            }
+            if ok {
+                note(pool.Name, "algorithm %s rebuilt", pool.Algorithm)
+            }
+        }
+        plans = append(plans, plan)
+    }
+
+    routesChanged := !reflect.DeepEqual(prev.Routes, cfg.Routes)
+    vhostsChanged := !reflect.DeepEqual(prev.VirtualHosts, cfg.VirtualHosts)
+    var nextRouter *router
+    if (routesChanged || vhostsChanged) && (len(cfg.Routes) > 0 || len(cfg.VirtualHosts) > 0) {
+        var err error
+        if nextRouter, err = newRouter(cfg.Routes, cfg.VirtualHosts); err != nil {
+            return nil, err
+        }
+        nextRouter.inherit(l.router)
+    }
+
+    // 2. Pools and backends, the removed ones first: a backend may move to another pool.
+    inNext := make(map[string]bool, len(cfg.Pools))
+    for _, pool := range cfg.Pools {
+        inNext[pool.Name] = true
+    }
+    for _, p := range prev.Pools {
+        if pool, ok := l.pools[p.Name]; ok && !inNext[p.Name] {
+            // Its servers go with it, whoever registered them.
+            for _, servers := range []model.BEServers{pool.AliveServers, pool.DownServers} {
+                for addr := range servers {
+                    l.deregister(addr)
+                }
+            }
+            delete(l.pools, p.Name)
+            changes = append(changes, fmt.Sprintf("pool %s removed", p.Name))
+        }
+    }
+    for _, plan := range plans {
+        if plan.pool == nil {
+            plan.pool = newPool(plan.config.Name, plan.next, plan.config.Algorithm, plan.config.Params)
+            plan.created = true
+            l.pools[plan.config.Name] = plan.pool
+            changes = append(changes, fmt.Sprintf("pool %s added", plan.config.Name))
+        }
+        for _, backend := range plan.removed {
+            l.deregister(backend.Address)
+            note(plan.config.Name, "backend %s removed", backend.Address)
+        }
+    }
+    probe := false
+    for _, plan := range plans {
+        for _, backend := range plan.added {
+            l.addServer(plan.pool, backendServer(backend))
+            note(plan.config.Name, "backend %s added", backend.Address)
+            probe = true
+        }
+        for _, backend := range plan.updated {
+            l.updateServer(plan.pool, backendServer(backend))
+            note(plan.config.Name, "backend %s updated", backend.Address)
+        }
+    }
+
+    // 3. Settings read at every scan or registration.
+    if prev.HealthCheck != cfg.HealthCheck {
+        l.ScanPeriod = cfg.HealthCheck.Interval
+        changes = append(changes, "health check updated")
+        probe = true
+    }
+    for _, plan := range plans {
+        health := cfg.HealthCheckOf(plan.config)
+        if plan.pool.HealthPath == health.Path && plan.pool.HealthTimeout == health.Timeout {
+            continue
+        }
+        plan.pool.HealthPath = health.Path
+        plan.pool.HealthTimeout = health.Timeout
+        probe = true
+        if !plan.created && plan.prev.HealthCheck != plan.config.HealthCheck {
+            // A change of the global health check is reported once above.
+            note(plan.config.Name, "health check updated")
+        }
+    }
+    if prev.Failover != cfg.Failover {
+        l.FailoverThreshold = cfg.Failover
+        changes = append(changes, fmt.Sprintf("failover threshold %d%%", cfg.Failover))
+    }
+    if prev.SlowStart != cfg.SlowStart {
+        l.SlowStart = cfg.SlowStart.Window
+        l.SlowStartMode = cfg.SlowStart.Mode
+        changes = append(changes, "slow-start updated")
+    }
+    if routesChanged || vhostsChanged {
+        l.router = nextRouter
+    }
+    if routesChanged {
+        changes = append(changes, "routes updated")
+    }
+    if vhostsChanged {
+        changes = append(changes, "virtual hosts updated")
+    }
+    if !reflect.DeepEqual(prev.Listeners, cfg.Listeners) || !reflect.DeepEqual(prev.AdminListeners, cfg.AdminListeners) {
+        changes = append(changes, "listeners changed, restart to apply")
+    }
+    if prev.Timeouts != cfg.Timeouts {
+        changes = append(changes, "timeouts changed, restart to apply")
+    }
+    if prev.Registry != cfg.Registry {
+        changes = append(changes, "registry changed, restart to apply")
+    }
+    if !reflect.DeepEqual(prev.Discovery, cfg.Discovery) {
+        changes = append(changes, "discovery changed, restart to apply")
+    }
+    l.Locality = locality
+    l.Seed = cfg.Seed
+
+    // 4. Swap the new algorithms in, or renew the ones in use with the servers taking traffic now.
+    for _, plan := range plans {
+        plan.pool.Cookie = plan.cookie
+        if plan.next != nil && !plan.created {
+            l.swapAlgo(plan.pool, plan.next, plan.config.Algorithm, plan.config.Params)
+        } else {
+            l.renew(plan.pool)
+        }
+    }
+    if def := cfg.Pools[0].Name; def != prev.Pools[0].Name {
+        changes = append(changes, fmt.Sprintf("default pool %s", def))
+    }
+    l.Pool = l.pools[cfg.Pools[0].Name]
+    l.publish()
+    l.config = cfg
+    // Backends may have become static or stopped being so.
+    l.persist()
+
+    if probe {
+        l.requestScan()
+    }
+    return changes, nil
+}
+
+// diffBackends returns the backends of next that aren't in prev, the ones of prev that aren't in next, and the ones of
+// next that are in prev with other attributes.
+func diffBackends(prev, next []config.Backend) (added, removed, updated []config.Backend) {
+    prevByAddr := make(map[string]config.Backend, len(prev))
+    for _, backend := range prev {
+        prevByAddr[backend.Address] = backend
+    }
+    nextByAddr := make(map[string]bool, len(next))
+    for _, backend := range next {
+        nextByAddr[backend.Address] = true
+        if p, ok := prevByAddr[backend.Address]; !ok {
+            added = append(added, backend)
+        } else if p != backend {
+            updated = append(updated, backend)
+        }
+    }
+    for _, backend := range prev {
+        if !nextByAddr[backend.Address] {
+            removed = append(removed, backend)
+        }
+    }
+    return added, removed, updated
+}
+
+// updateServer replaces the registered server of srv.Address in pool with srv, keeping the state of the registered one.
+// The server isn't changed in place, requests in flight may still read it. Callers must hold the lock.
+func (l *LoadBalancer) updateServer(pool *Pool, srv *model.BEServer) {
+    servers := pool.AliveServers
+    prev, ok := servers[srv.Address]
+    if !ok {
+        servers = pool.DownServers
+        if prev, ok = servers[srv.Address]; !ok {
+            pool.DownServers[srv.Address] = srv
+            return
+        }
+    }
+    srv.SlowStart = prev.SlowStart
+    srv.Draining = prev.Draining
+    srv.Maintenance = prev.Maintenance
+    servers[srv.Address] = srv
+}
+
+// requestScan probes the servers and restarts the scan period without waiting for the next tick.
+func (l *LoadBalancer) requestScan() {
+    select {
+    case l.rescan <- struct{}{}:
+    default:
+    }
+}
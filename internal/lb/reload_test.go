@@ -0,0 +1,108 @@
+package lb
+
+import (
+    "LoadBalancer/internal/config"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestLoadBalancer_Reload(t *testing.T) {
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+    defer backend.Close()
+
+    parse := func(data string) *config.Config {
+        cfg, err := config.Parse([]byte(strings.ReplaceAll(data, "BACKEND", backend.URL)))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return cfg
+    }
+    l, err := NewFromConfig(parse(`
+pools:
+  - algorithm: RR
+    backends:
+      - address: BACKEND
+      - address: http://127.0.0.1:1
+`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.scanServers()
+    l.AliveServers[backend.URL].Draining = true
+
+    changes, err := l.Reload(parse(`
+pools:
+  - algorithm: WRR
+    backends:
+      - address: BACKEND
+        weight: 3
+      - address: http://127.0.0.1:2
+health_check:
+  interval: 1s
+listeners:
+  - address: ":9000"
+`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := []string{
+        "algorithm WRR rebuilt",
+        "backend http://127.0.0.1:1 removed",
+        "backend http://127.0.0.1:2 added",
+        "backend " + backend.URL + " updated",
+        "health check updated",
+        "listeners changed, restart to apply",
+    }
+    if strings.Join(changes, "\n") != strings.Join(expected, "\n") {
+        t.Errorf("error reloading configuration: expected changes %q, got %q.\n", expected, changes)
+    }
+
+    // The updated server keeps its state.
+    srv, ok := l.AliveServers[backend.URL]
+    if !ok || srv.Weight != 3 || !srv.Draining {
+        t.Errorf("error updating backend: expected %s alive, draining, with weight 3, got %#v.\n", backend.URL, srv)
+    }
+    if _, ok = l.DownServers["http://127.0.0.1:1"]; ok {
+        t.Errorf("error removing backend: expected %s to be deregistered.\n", "http://127.0.0.1:1")
+    }
+    if _, ok = l.DownServers["http://127.0.0.1:2"]; !ok {
+        t.Errorf("error adding backend: expected %s to be registered down.\n", "http://127.0.0.1:2")
+    }
+    if l.AlgoBrief() != "WRR" || l.ScanPeriod != time.Second || l.Listeners[0] != ":8000" {
+        t.Errorf("error reloading settings: got %s %s %v.\n", l.AlgoBrief(), l.ScanPeriod, l.Listeners)
+    }
+    select {
+    case <-l.rescan:
+    default:
+        t.Errorf("error reloading configuration: expected a rescan to be requested.\n")
+    }
+
+    // An invalid configuration changes nothing.
+    invalid := parse("pools:\n  - algorithm: LC\n")
+    invalid.Failover = 200
+    if _, err = l.Reload(invalid); err == nil {
+        t.Errorf("error reloading invalid configuration: expected an error, got nil.\n")
+    }
+    if l.AlgoBrief() != "WRR" || len(l.DownServers) != 1 {
+        t.Errorf("error rejecting invalid configuration: expected the running one to be kept, got %s.\n", l.AlgoBrief())
+    }
+
+    // The same configuration changes nothing.
+    if changes, err = l.Reload(l.config); err != nil || len(changes) != 0 {
+        t.Errorf("error reloading same configuration: expected no change, got %q %v.\n", changes, err)
+    }
+}
+
+func TestLoadBalancer_ReloadWithoutConfig(t *testing.T) {
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err = l.Reload(config.Default()); !errors.Is(err, ErrNoConfig) {
+        t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrNoConfig, err)
+    }
+}
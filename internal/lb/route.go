@@ -0,0 +1,431 @@
+package lb
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/internal/lb/response"
+    "encoding/json"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "reflect"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// Conditions of a route a request may fail, see config.RouteMatch.
+const (
+    mismatchPathPrefix = "path_prefix"
+    mismatchPathRegex  = "path_regex"
+    mismatchMethods    = "methods"
+    mismatchHeaders    = "headers"
+)
+
+// router picks the pool of a request by the routes and the virtual hosts of the configuration.
+type router struct {
+    rules     []*rule // Highest priority first.
+    vhosts    []config.VirtualHost
+    exact     map[string]string // Host: name of its pool.
+    wildcards []wildcard        // Longest suffix first.
+    any       string            // Pool of the virtual host of *, empty when there's none.
+}
+
+// wildcard is a host such as *.example.com.
+type wildcard struct {
+    suffix string // Domain with a leading dot, e.g. .example.com.
+    pool   string
+}
+
+// rule is a route with its regular expressions compiled.
+type rule struct {
+    config.Route
+    pathRegex *regexp.Regexp // Nil when the path isn't matched by a regex.
+    rewrite   *regexp.Regexp // Nil when the path isn't rewritten by a regex.
+    split     *split         // Nil when the route sends its requests to one pool.
+}
+
+// newRouter creates the router of routes and vhosts, which must be valid.
+func newRouter(routes []config.Route, vhosts []config.VirtualHost) (*router, error) {
+    r := &router{vhosts: vhosts, exact: make(map[string]string)}
+    for _, route := range routes {
+        rl := &rule{Route: route}
+        var err error
+        if route.Match.PathRegex != "" {
+            if rl.pathRegex, err = regexp.Compile(route.Match.PathRegex); err != nil {
+                return nil, fmt.Errorf("route %s: %w", route.Name, err)
+            }
+        }
+        if route.Rewrite.Regex != "" {
+            if rl.rewrite, err = regexp.Compile(route.Rewrite.Regex); err != nil {
+                return nil, fmt.Errorf("route %s: %w", route.Name, err)
+            }
+        }
+        if len(route.Split) > 0 {
+            rl.split = newSplit(route)
+        }
+        r.rules = append(r.rules, rl)
+    }
+    sort.SliceStable(r.rules, func(i, j int) bool {
+        return r.rules[i].Priority > r.rules[j].Priority
+    })
+
+    for _, vhost := range vhosts {
+        for _, host := range vhost.Hosts {
+            host = strings.ToLower(host)
+            switch {
+            case host == "*":
+                r.any = vhost.Pool
+            case strings.HasPrefix(host, "*."):
+                r.wildcards = append(r.wildcards, wildcard{suffix: host[1:], pool: vhost.Pool})
+            default:
+                r.exact[host] = vhost.Pool
+            }
+        }
+    }
+    sort.SliceStable(r.wildcards, func(i, j int) bool {
+        return len(r.wildcards[i].suffix) > len(r.wildcards[j].suffix)
+    })
+    return r, nil
+}
+
+// inherit keeps the splits of the routes of prev that are the same in r, with their runtime weights and metrics.
+func (r *router) inherit(prev *router) {
+    if prev == nil {
+        return
+    }
+    splits := make(map[string]*rule, len(prev.rules))
+    for _, rl := range prev.rules {
+        if rl.split != nil {
+            splits[rl.Name] = rl
+        }
+    }
+    for _, rl := range r.rules {
+        if old, ok := splits[rl.Name]; ok && rl.split != nil && reflect.DeepEqual(old.Route, rl.Route) {
+            rl.split = old.split
+        }
+    }
+}
+
+// routes returns the routes of the router, by priority.
+func (r *router) routes() []config.Route {
+    routes := make([]config.Route, 0, len(r.rules))
+    for _, rl := range r.rules {
+        routes = append(routes, rl.Route)
+    }
+    return routes
+}
+
+// decision is where a router sends a request.
+type decision struct {
+    rule   *rule  // Route that matched, nil when none did.
+    leg    *leg   // Leg of the split of the route, nil when the route doesn't split traffic.
+    forced bool   // Whether a header or cookie of the request forced the leg.
+    host   string // Host of the virtual host that matched when no route did, empty when none did either.
+    pool   string // Empty for the default pool.
+}
+
+// decide routes req: to the pool of the first route it matches, or of a leg of its split, else to the pool of the virtual host of its host.
+// Every route req doesn't match is reported to skip when it isn't nil.
+func (r *router) decide(req *http.Request, skip func(rl *rule, mismatch string)) decision {
+    for _, rl := range r.rules {
+        mismatch := rl.mismatch(req)
+        if mismatch == "" && rl.split != nil {
+            leg, forced := rl.split.pick(req)
+            return decision{rule: rl, leg: leg, forced: forced, pool: leg.Pool}
+        }
+        if mismatch == "" {
+            return decision{rule: rl, pool: rl.Pool}
+        }
+        if skip != nil {
+            skip(rl, mismatch)
+        }
+    }
+    host, pool := r.match(requestHost(req))
+    return decision{host: host, pool: pool}
+}
+
+// match returns the virtual host of host and its pool, empty when no virtual host matches it.
+// An exact name is preferred to a wildcard, a longer wildcard to a shorter one, and any wildcard to *.
+func (r *router) match(host string) (string, string) {
+    if pool, ok := r.exact[host]; ok {
+        return host, pool
+    }
+    for _, w := range r.wildcards {
+        // *.example.com matches the subdomains of example.com, not example.com itself.
+        if strings.HasSuffix(host, w.suffix) && len(host) > len(w.suffix) {
+            return "*" + w.suffix, w.pool
+        }
+    }
+    if r.any != "" {
+        return "*", r.any
+    }
+    return "", ""
+}
+
+// hosts returns the hosts whose requests go to pool, sorted.
+func (r *router) hosts(pool string) []string {
+    var hosts []string
+    for host, p := range r.exact {
+        if p == pool {
+            hosts = append(hosts, host)
+        }
+    }
+    for _, w := range r.wildcards {
+        if w.pool == pool {
+            hosts = append(hosts, "*"+w.suffix)
+        }
+    }
+    if r.any == pool {
+        hosts = append(hosts, "*")
+    }
+    sort.Strings(hosts)
+    return hosts
+}
+
+// mismatch returns the first condition of the route that req fails, empty when req matches the route.
+func (rl *rule) mismatch(req *http.Request) string {
+    match := rl.Match
+    if !strings.HasPrefix(req.URL.Path, match.PathPrefix) {
+        return mismatchPathPrefix
+    }
+    if rl.pathRegex != nil && !rl.pathRegex.MatchString(req.URL.Path) {
+        return mismatchPathRegex
+    }
+    if len(match.Methods) > 0 {
+        found := false
+        for _, method := range match.Methods {
+            if strings.EqualFold(method, req.Method) {
+                found = true
+                break
+            }
+        }
+        if !found {
+            return mismatchMethods
+        }
+    }
+    for name, value := range match.Headers {
+        if req.Header.Get(name) != value {
+            return mismatchHeaders
+        }
+    }
+    return ""
+}
+
+// rewritePath returns the path that path is sent to the backend server as.
+func (rl *rule) rewritePath(path string) string {
+    switch {
+    case rl.Rewrite.StripPrefix:
+        path = strings.TrimPrefix(path, rl.Match.PathPrefix)
+        if !strings.HasPrefix(path, "/") {
+            path = "/" + path
+        }
+    case rl.rewrite != nil:
+        path = rl.rewrite.ReplaceAllString(path, rl.Rewrite.Replacement)
+    }
+    return path
+}
+
+// requestHost returns the host req is sent to, in lower case, without its port nor a trailing dot.
+func requestHost(req *http.Request) string {
+    host := req.Host
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// SetVirtualHosts sends the requests for the hosts of vhosts to their pool, when no route takes them, and the other
+// ones to the default pool. The virtual hosts must be valid, see config.VirtualHost.
+func (l *LoadBalancer) SetVirtualHosts(vhosts []config.VirtualHost) error {
+    l.Lock()
+    defer l.Unlock()
+
+    for _, vhost := range vhosts {
+        if _, ok := l.pools[vhost.Pool]; !ok {
+            return ErrUnknownPool
+        }
+    }
+    var routes []config.Route
+    if l.router != nil {
+        routes = l.router.routes()
+    }
+    r, err := newRouter(routes, vhosts)
+    if err != nil {
+        return err
+    }
+    r.inherit(l.router)
+    l.router = r
+    l.publish()
+    return nil
+}
+
+// SetRoutes sends the requests that match routes to their pool, before the virtual hosts are looked up.
+// The routes must be valid, see config.Route.
+func (l *LoadBalancer) SetRoutes(routes []config.Route) error {
+    l.Lock()
+    defer l.Unlock()
+
+    for _, route := range routes {
+        if _, ok := l.pools[route.Pool]; !ok && len(route.Split) == 0 {
+            return ErrUnknownPool
+        }
+        for _, leg := range route.Split {
+            if _, ok := l.pools[leg.Pool]; !ok {
+                return ErrUnknownPool
+            }
+        }
+    }
+    var vhosts []config.VirtualHost
+    if l.router != nil {
+        vhosts = l.router.vhosts
+    }
+    r, err := newRouter(routes, vhosts)
+    if err != nil {
+        return err
+    }
+    r.inherit(l.router)
+    l.router = r
+    l.publish()
+    return nil
+}
+
+// routing is what route reads without the lock: the router and the pools it sends requests to. It's replaced, never
+// changed, whenever one of them changes.
+type routing struct {
+    router *router
+    pools  map[string]*Pool
+    pool   *Pool // Default pool.
+}
+
+// publish publishes the router and the pools to route. Callers must hold the lock, and publish after every change of
+// the router, the pools or the default pool.
+func (l *LoadBalancer) publish() {
+    pools := make(map[string]*Pool, len(l.pools))
+    for name, pool := range l.pools {
+        pools[name] = pool
+    }
+    l.routing.Store(&routing{router: l.router, pools: pools, pool: l.Pool})
+}
+
+// destination is where Forward sends a request.
+type destination struct {
+    pool   *Pool
+    path   string // Path the request is sent with.
+    leg    *leg   // Leg of the split of the route of the request, nil when the route doesn't split traffic.
+    forced bool   // Whether a header or cookie of the request forced the leg.
+}
+
+// route returns where req is sent: to the pool of the first route req matches, or of a leg of its split, with the path
+// rewritten by the route, else to the pool of the virtual host of its Host header, else to the default pool.
+// It doesn't take the lock, the routing published last is used.
+func (l *LoadBalancer) route(req *http.Request) destination {
+    rt := l.routing.Load()
+    dest := destination{pool: rt.pool, path: req.URL.Path}
+    if rt.router == nil {
+        return dest
+    }
+    d := rt.router.decide(req, nil)
+    if pool, ok := rt.pools[d.pool]; ok {
+        dest.pool = pool
+    }
+    if d.rule != nil {
+        dest.path = d.rule.rewritePath(req.URL.Path)
+    }
+    dest.leg, dest.forced = d.leg, d.forced
+    return dest
+}
+
+// RouteTrial is a route that was tried for a request.
+type RouteTrial struct {
+    Route    string `json:"route"`
+    Mismatch string `json:"mismatch"` // Condition of the route the request failed, e.g. path_prefix.
+}
+
+// RouteExplanation tells where a request is sent and why.
+type RouteExplanation struct {
+    Pool    string       `json:"pool"`
+    Path    string       `json:"path"`             // Path the request is sent to the backend server with.
+    Route   string       `json:"route,omitempty"`  // Route the request matched, empty when it matched none.
+    Forced  bool         `json:"forced,omitempty"` // Whether a header or cookie forced the leg of the split of the route.
+    Host    string       `json:"host,omitempty"`   // Virtual host the request matched when it matched no route.
+    Skipped []RouteTrial `json:"skipped"`          // Routes the request didn't match, by priority.
+}
+
+// ExplainRoute tells where req is sent and why, as Forward would route it.
+func (l *LoadBalancer) ExplainRoute(req *http.Request) RouteExplanation {
+    rt := l.routing.Load()
+    explanation := RouteExplanation{Pool: rt.pool.Name, Path: req.URL.Path, Skipped: []RouteTrial{}}
+    if rt.router == nil {
+        return explanation
+    }
+    d := rt.router.decide(req, func(rl *rule, mismatch string) {
+        explanation.Skipped = append(explanation.Skipped, RouteTrial{Route: rl.Name, Mismatch: mismatch})
+    })
+    if pool, ok := rt.pools[d.pool]; ok {
+        explanation.Pool = pool.Name
+    }
+    explanation.Host = d.host
+    explanation.Forced = d.forced
+    if d.rule != nil {
+        explanation.Route = d.rule.Name
+        explanation.Path = d.rule.rewritePath(req.URL.Path)
+    }
+    return explanation
+}
+
+// RouteRequest is a sample request whose route is explained by endpoint '/admin/routes'.
+type RouteRequest struct {
+    Method  string            `json:"method"` // GET when empty.
+    Host    string            `json:"host"`
+    Path    string            `json:"path"` // May have a query, e.g. /billing/v1/invoices?page=2.
+    Headers map[string]string `json:"headers"`
+}
+
+// Routes is a handler that is used by endpoint '/admin/routes'.
+// On GET it lists the routes by priority. On POST it explains which route the sample request of the body matches, the
+// routes it skipped and why, the pool it goes to and the path it's sent with.
+func (l *LoadBalancer) Routes(w http.ResponseWriter, req *http.Request) {
+    switch req.Method {
+    case http.MethodGet:
+        routes := []config.Route{}
+        l.RLock()
+        if l.router != nil {
+            routes = l.router.routes()
+        }
+        l.RUnlock()
+        response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(routes))
+    case http.MethodPost:
+        var p RouteRequest
+        decoder := json.NewDecoder(req.Body)
+        decoder.DisallowUnknownFields()
+        if err := decoder.Decode(&p); err != nil {
+            response.WriteJsonResponse(w, http.StatusBadRequest, response.NewErrorResponse(err))
+            return
+        }
+        if p.Method == "" {
+            p.Method = http.MethodGet
+        }
+        u, err := url.ParseRequestURI(p.Path)
+        if err != nil {
+            responsePayload := response.NewFailResponse(
+                struct {
+                    Title string `json:"title"`
+                }{Title: fmt.Sprintf("Invalid path %q, expected an absolute path.", p.Path)})
+            response.WriteJsonResponse(w, http.StatusBadRequest, responsePayload)
+            return
+        }
+
+        sample := &http.Request{Method: p.Method, Host: p.Host, URL: u, Header: make(http.Header)}
+        for name, value := range p.Headers {
+            sample.Header.Set(name, value)
+        }
+        response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(l.ExplainRoute(sample)))
+    default:
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s or %s, got %s.", http.MethodGet, http.MethodPost, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+    }
+}
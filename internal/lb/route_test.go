@@ -0,0 +1,314 @@
+package lb
+
+import (
+    "LoadBalancer/internal/config"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestRouter_Match(t *testing.T) {
+    r, err := newRouter(nil, []config.VirtualHost{
+        {Hosts: []string{"*.example.com", "*"}, Pool: "web"},
+        {Hosts: []string{"api.example.com", "*.EU.example.com"}, Pool: "api"},
+        {Hosts: []string{"*.static.eu.example.com"}, Pool: "static"},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    testCases := []struct {
+        host     string
+        expected string
+    }{
+        {host: "api.example.com", expected: "api"},
+        {host: "www.example.com", expected: "web"},
+        {host: "a.b.example.com", expected: "web"},
+        {host: "shop.eu.example.com", expected: "api"},
+        {host: "img.static.eu.example.com", expected: "static"},
+        {host: "eu.example.com", expected: "web"},
+        {host: "example.com", expected: "web"},
+        {host: "example.org", expected: "web"},
+    }
+    for _, tc := range testCases {
+        if _, pool := r.match(tc.host); pool != tc.expected {
+            t.Errorf("error matching host %s: expected %s, got %s.\n", tc.host, tc.expected, pool)
+        }
+    }
+
+    if hosts := r.hosts("api"); strings.Join(hosts, ",") != "*.eu.example.com,api.example.com" {
+        t.Errorf("error listing hosts of pool api: got %v.\n", hosts)
+    }
+}
+
+func TestRouter_Decide(t *testing.T) {
+    r, err := newRouter([]config.Route{
+        {Name: "catch-all", Priority: -1, Pool: "web"},
+        {Name: "billing", Match: config.RouteMatch{PathPrefix: "/billing/"}, Pool: "billing"},
+        {Name: "users", Match: config.RouteMatch{PathRegex: "^/users/[0-9]+$", Methods: []string{"get", "HEAD"}}, Pool: "api"},
+        {Name: "beta", Priority: 10, Match: config.RouteMatch{PathPrefix: "/billing/", Headers: map[string]string{"X-Beta": "1"}}, Pool: "beta"},
+    }, []config.VirtualHost{{Hosts: []string{"*"}, Pool: "static"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    testCases := []struct {
+        name     string
+        method   string
+        target   string
+        header   string
+        expected string
+        skipped  string
+    }{
+        {name: "Prefix", method: http.MethodPost, target: "/billing/v1", expected: "billing", skipped: "beta:headers"},
+        {name: "Header", method: http.MethodGet, target: "/billing/v1", header: "1", expected: "beta"},
+        {name: "Regex", method: http.MethodHead, target: "/users/42", expected: "users", skipped: "beta:path_prefix,billing:path_prefix"},
+        {name: "Method", method: http.MethodDelete, target: "/users/42", expected: "catch-all", skipped: "beta:path_prefix,billing:path_prefix,users:methods"},
+        {name: "Regex mismatch", method: http.MethodGet, target: "/users/me", expected: "catch-all", skipped: "beta:path_prefix,billing:path_prefix,users:path_regex"},
+    }
+    for _, tc := range testCases {
+        t.Run(tc.name, func(t *testing.T) {
+            req := httptest.NewRequest(tc.method, tc.target, nil)
+            if tc.header != "" {
+                req.Header.Set("X-Beta", tc.header)
+            }
+            var skipped []string
+            d := r.decide(req, func(rl *rule, mismatch string) {
+                skipped = append(skipped, rl.Name+":"+mismatch)
+            })
+            if d.rule == nil || d.rule.Name != tc.expected {
+                t.Fatalf("error deciding route: expected %s, got %#v.\n", tc.expected, d)
+            }
+            if strings.Join(skipped, ",") != tc.skipped {
+                t.Errorf("error skipping routes: expected %s, got %s.\n", tc.skipped, strings.Join(skipped, ","))
+            }
+        })
+    }
+
+    // Without a matching route, the virtual hosts are looked up.
+    r, _ = newRouter(r.routes()[:3], r.vhosts)
+    if d := r.decide(httptest.NewRequest(http.MethodGet, "/", nil), nil); d.rule != nil || d.host != "*" || d.pool != "static" {
+        t.Errorf("error deciding virtual host: expected %s, got %#v.\n", "static", d)
+    }
+}
+
+func TestRule_RewritePath(t *testing.T) {
+    testCases := []struct {
+        route    config.Route
+        path     string
+        expected string
+    }{
+        {route: config.Route{}, path: "/billing/v1", expected: "/billing/v1"},
+        {route: config.Route{Match: config.RouteMatch{PathPrefix: "/billing"}, Rewrite: config.Rewrite{StripPrefix: true}}, path: "/billing/v1/x", expected: "/v1/x"},
+        {route: config.Route{Match: config.RouteMatch{PathPrefix: "/billing/"}, Rewrite: config.Rewrite{StripPrefix: true}}, path: "/billing/v1/x", expected: "/v1/x"},
+        {route: config.Route{Match: config.RouteMatch{PathPrefix: "/billing"}, Rewrite: config.Rewrite{StripPrefix: true}}, path: "/billing", expected: "/"},
+        {route: config.Route{Rewrite: config.Rewrite{Regex: "^/users/([0-9]+)$", Replacement: "/v2/accounts/$1"}}, path: "/users/42", expected: "/v2/accounts/42"},
+    }
+    for _, tc := range testCases {
+        r, err := newRouter([]config.Route{tc.route}, nil)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if path := r.rules[0].rewritePath(tc.path); path != tc.expected {
+            t.Errorf("error rewriting path %s: expected %s, got %s.\n", tc.path, tc.expected, path)
+        }
+    }
+}
+
+func TestRequestHost(t *testing.T) {
+    testCases := map[string]string{
+        "API.Example.com":       "api.example.com",
+        "api.example.com:8000":  "api.example.com",
+        "api.example.com.":      "api.example.com",
+        "[::1]:8000":            "::1",
+        "127.0.0.1":             "127.0.0.1",
+        "api.example.com.:8000": "api.example.com",
+    }
+    for host, expected := range testCases {
+        req := httptest.NewRequest(http.MethodGet, "/", nil)
+        req.Host = host
+        if got := requestHost(req); got != expected {
+            t.Errorf("error parsing host %s: expected %s, got %s.\n", host, expected, got)
+        }
+    }
+}
+
+// newNamedBackend starts a backend server that answers every request with name, and with the path and query of the
+// request but for the health check.
+func newNamedBackend(t *testing.T, name string) *httptest.Server {
+    t.Helper()
+
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        if req.URL.Path == "/health" {
+            _, _ = io.WriteString(w, name)
+            return
+        }
+        _, _ = io.WriteString(w, name+" "+req.URL.RequestURI())
+    }))
+    t.Cleanup(backend.Close)
+    return backend
+}
+
+// forward sends req through l and returns the answer of the backend server.
+func forward(l *LoadBalancer, req *http.Request) string {
+    rec := httptest.NewRecorder()
+    l.Forward(rec, req)
+    body := rec.Body.String()
+    if start, end := strings.Index(body, "[ '"), strings.LastIndex(body, "' ]"); start >= 0 && end > start {
+        return body[start+3 : end]
+    }
+    return body
+}
+
+func TestLoadBalancer_VirtualHosts(t *testing.T) {
+    web := newNamedBackend(t, "web")
+    api := newNamedBackend(t, "api")
+
+    data := `
+pools:
+  - name: web
+    backends:
+      - address: ` + web.URL + `
+  - name: api
+    backends:
+      - address: ` + api.URL + `
+`
+    cfg, err := config.Parse([]byte(data + "virtual_hosts:\n  - hosts: [api.example.com]\n    pool: api\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    l, err := NewFromConfig(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.scanServers()
+
+    forwardHost := func(host string) string {
+        req := httptest.NewRequest(http.MethodGet, "/", nil)
+        req.Host = host
+        return strings.TrimSuffix(forward(l, req), " /")
+    }
+    if body := forwardHost("API.example.com:8000"); body != "api" {
+        t.Errorf("error routing virtual host: expected %s, got %s.\n", "api", body)
+    }
+    if body := forwardHost("www.example.com"); body != "web" {
+        t.Errorf("error routing unknown host: expected the default pool %s, got %s.\n", "web", body)
+    }
+
+    // The virtual hosts are reloaded with the configuration.
+    cfg, err = config.Parse([]byte(data + "virtual_hosts:\n  - hosts: [\"*\"]\n    pool: api\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    changes, err := l.Reload(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if strings.Join(changes, "\n") != "virtual hosts updated" {
+        t.Errorf("error reloading virtual hosts: expected changes %q, got %q.\n", "virtual hosts updated", changes)
+    }
+    if body := forwardHost("www.example.com"); body != "api" {
+        t.Errorf("error routing reloaded virtual host: expected %s, got %s.\n", "api", body)
+    }
+
+    if err = l.SetVirtualHosts([]config.VirtualHost{{Hosts: []string{"*"}, Pool: "billing"}}); err != ErrUnknownPool {
+        t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrUnknownPool, err)
+    }
+    if pools := l.PoolList(); pools[0].Name != "api" || strings.Join(pools[0].Hosts, ",") != "*" {
+        t.Errorf("error listing hosts of pool api: got %#v.\n", pools[0])
+    }
+}
+
+func TestLoadBalancer_Routes(t *testing.T) {
+    web := newNamedBackend(t, "web")
+    billing := newNamedBackend(t, "billing")
+
+    cfg, err := config.Parse([]byte(`
+pools:
+  - name: web
+    backends:
+      - address: ` + web.URL + `
+  - name: billing
+    backends:
+      - address: ` + billing.URL + `
+routes:
+  - name: billing
+    match:
+      path_prefix: /billing/
+      methods: [GET]
+    pool: billing
+    rewrite:
+      strip_prefix: true
+`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    l, err := NewFromConfig(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.scanServers()
+
+    // The path is rewritten before the request is copied, the query is kept.
+    if body := forward(l, httptest.NewRequest(http.MethodGet, "/billing/v1/invoices?page=2", nil)); body != "billing /v1/invoices?page=2" {
+        t.Errorf("error forwarding routed request: expected %s, got %s.\n", "billing /v1/invoices?page=2", body)
+    }
+    if body := forward(l, httptest.NewRequest(http.MethodPost, "/billing/v1/invoices", nil)); body != "web /billing/v1/invoices" {
+        t.Errorf("error forwarding unrouted request: expected %s, got %s.\n", "web /billing/v1/invoices", body)
+    }
+
+    rec := httptest.NewRecorder()
+    body := `{"method": "POST", "host": "www.example.com", "path": "/billing/v1/invoices?page=2"}`
+    l.Routes(rec, httptest.NewRequest(http.MethodPost, "/admin/routes", strings.NewReader(body)))
+    expected := `"data":{"pool":"web","path":"/billing/v1/invoices","skipped":[{"route":"billing","mismatch":"methods"}]}`
+    if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), expected) {
+        t.Errorf("error explaining route: expected %s, got %d %s.\n", expected, rec.Code, rec.Body.String())
+    }
+
+    rec = httptest.NewRecorder()
+    l.Routes(rec, httptest.NewRequest(http.MethodPost, "/admin/routes", strings.NewReader(`{"path": "billing"}`)))
+    if rec.Code != http.StatusBadRequest {
+        t.Errorf("error explaining route of invalid path: expected status %d, got %d.\n", http.StatusBadRequest, rec.Code)
+    }
+
+    rec = httptest.NewRecorder()
+    l.Routes(rec, httptest.NewRequest(http.MethodGet, "/admin/routes", nil))
+    expected = `{"name":"billing","match":{"path_prefix":"/billing/","methods":["GET"]},"pool":"billing","rewrite":{"strip_prefix":true}}`
+    if !strings.Contains(rec.Body.String(), expected) {
+        t.Errorf("error listing routes: expected %s, got %s.\n", expected, rec.Body.String())
+    }
+
+    if err = l.SetRoutes([]config.Route{{Name: "x", Pool: "billing"}}); err != nil {
+        t.Fatal(err)
+    }
+    if body := forward(l, httptest.NewRequest(http.MethodGet, "/x", nil)); body != "billing /x" {
+        t.Errorf("error forwarding request of new route: expected %s, got %s.\n", "billing /x", body)
+    }
+}
+
+func TestLoadBalancer_AdminListeners(t *testing.T) {
+    backend := newNamedBackend(t, "backend")
+    l, err := New(0, 0, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.HandleFunc("/", l.Forward)
+    l.AdminListeners = []string{"127.0.0.1:9000"}
+    admin := l.handleAdmin()
+    l.Register(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"address": "`+backend.URL+`"}`)))
+    l.scanServers()
+
+    // Clients reach the backend on /admin/, only the admin handler serves the admin API.
+    rec := httptest.NewRecorder()
+    l.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/algo", nil))
+    if !strings.Contains(rec.Body.String(), "backend /admin/algo") {
+        t.Errorf("error forwarding admin path: expected %s, got %s.\n", "backend /admin/algo", rec.Body.String())
+    }
+    rec = httptest.NewRecorder()
+    admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/algo", nil))
+    if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"algo":"RR"`) {
+        t.Errorf("error serving admin API: expected the algorithm, got %d %s.\n", rec.Code, rec.Body.String())
+    }
+}
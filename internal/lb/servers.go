@@ -0,0 +1,142 @@
+package lb
+
+import (
+    "LoadBalancer/internal/lb/response"
+    "LoadBalancer/pkg/model"
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "sort"
+)
+
+// States of a backend server in the server listing.
+const (
+    StateAlive       = "alive"
+    StateDown        = "down"
+    StateMaintenance = "maintenance"
+)
+
+// SetMaintenance puts a registered server under maintenance or clears it, and renews the algorithm right away.
+// A server under maintenance takes no traffic and isn't probed, it stays registered among the down servers.
+// Once the maintenance is cleared, the next scan probes it and brings it back when it's healthy.
+func (l *LoadBalancer) SetMaintenance(address string, maintenance bool) error {
+    defer l.save()
+    l.Lock()
+    defer l.Unlock()
+
+    pool, _ := l.serverPool(address)
+    if pool == nil {
+        return ErrUnknownServer
+    }
+    srv := pool.copyServer(address)
+    // Clearing the maintenance of an alive server keeps it alive.
+    if _, ok := pool.AliveServers[address]; ok && maintenance {
+        delete(pool.AliveServers, address)
+        pool.DownServers[address] = srv
+    }
+    srv.Maintenance = maintenance
+    l.renew(pool)
+    l.persist()
+    return nil
+}
+
+// ServerInfo describes a registered backend server.
+type ServerInfo struct {
+    Address     string `json:"address"`
+    Pool        string `json:"pool"`
+    Weight      int    `json:"weight"`
+    Priority    int    `json:"priority"`
+    Region      string `json:"region,omitempty"`
+    Zone        string `json:"zone,omitempty"`
+    State       string `json:"state"` // StateAlive, StateDown or StateMaintenance.
+    Draining    bool   `json:"draining"`
+    Connections int64  `json:"connections"`
+}
+
+// serverInfo describes srv of pool in state.
+func (l *LoadBalancer) serverInfo(pool *Pool, srv *model.BEServer, state string) ServerInfo {
+    return ServerInfo{
+        Address:     srv.Address,
+        Pool:        pool.Name,
+        Weight:      srv.Weight,
+        Priority:    srv.Priority,
+        Region:      srv.Region,
+        Zone:        srv.Zone,
+        State:       state,
+        Draining:    srv.Draining,
+        Connections: l.connections(srv.Address).Load(),
+    }
+}
+
+// ServerList returns the registered servers of all pools sorted by address.
+func (l *LoadBalancer) ServerList() []ServerInfo {
+    l.RLock()
+    defer l.RUnlock()
+
+    var servers []ServerInfo
+    for _, pool := range l.pools {
+        for _, srv := range pool.AliveServers {
+            servers = append(servers, l.serverInfo(pool, srv, StateAlive))
+        }
+        for _, srv := range pool.DownServers {
+            state := StateDown
+            if srv.Maintenance {
+                state = StateMaintenance
+            }
+            servers = append(servers, l.serverInfo(pool, srv, state))
+        }
+    }
+
+    sort.Slice(servers, func(i, j int) bool {
+        return servers[i].Address < servers[j].Address
+    })
+    return servers
+}
+
+// Servers is a handler that is used by endpoint '/admin/servers'.
+// It lists the registered servers and their state.
+func (l *LoadBalancer) Servers(w http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodGet {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s, got %s.", http.MethodGet, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+        return
+    }
+    response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(l.ServerList()))
+}
+
+// MaintenanceRequest is used for putting a backend server under maintenance.
+type MaintenanceRequest struct {
+    Address     string `json:"address"`
+    Maintenance bool   `json:"maintenance"`
+}
+
+// Maintenance is a handler that is used by endpoint '/admin/maintenance'.
+func (l *LoadBalancer) Maintenance(w http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodPost {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s, got %s.", http.MethodPost, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+        return
+    }
+
+    var p MaintenanceRequest
+    decoder := json.NewDecoder(req.Body)
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&p); err != nil {
+        response.WriteJsonResponse(w, http.StatusBadRequest, response.NewErrorResponse(err))
+        return
+    }
+
+    if err := l.SetMaintenance(p.Address, p.Maintenance); err != nil {
+        l.writeUnknownServer(w, p.Address)
+        return
+    }
+    log.Printf("Server %s maintenance: %t.\n", p.Address, p.Maintenance)
+    response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(p))
+}
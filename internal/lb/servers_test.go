@@ -0,0 +1,179 @@
+package lb
+
+import (
+    "LoadBalancer/pkg/model"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+)
+
+func TestLoadBalancer_SetMaintenance(t *testing.T) {
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.AliveServers = model.BEServers{
+        "Address A": model.NewBEServer("Address A", 1),
+        "Address B": model.NewBEServer("Address B", 1),
+    }
+    l.AlgoDriver().Renew(l.AliveServers)
+
+    if err = l.SetMaintenance("Address A", true); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := l.DownServers["Address A"]; !ok {
+        t.Fatalf("error setting maintenance: expected %s among the down servers.\n", "Address A")
+    }
+    for i := 0; i < 4; i++ {
+        if chosen, _ := l.AlgoDriver().ChooseServer(new(http.Request)); chosen.Address != "Address B" {
+            t.Errorf("error choosing server: expected %s, got %s.\n", "Address B", chosen.Address)
+        }
+    }
+
+    // Clearing the maintenance of an alive server keeps it alive, with its slow-start.
+    since := l.AliveServers["Address B"].SlowStart.Since
+    if err = l.SetMaintenance("Address B", false); err != nil {
+        t.Fatal(err)
+    }
+    if srv, ok := l.AliveServers["Address B"]; !ok || srv.SlowStart.Since != since {
+        t.Errorf("error clearing maintenance: expected %s to stay alive.\n", "Address B")
+    }
+    if chosen, _ := l.AlgoDriver().ChooseServer(new(http.Request)); chosen.Address != "Address B" {
+        t.Errorf("error choosing server: expected %s, got %s.\n", "Address B", chosen.Address)
+    }
+
+    // The scan doesn't probe the server, it stays under maintenance.
+    l.scanServers()
+    if srv, ok := l.DownServers["Address A"]; !ok || !srv.Maintenance {
+        t.Errorf("error scanning servers: expected %s to stay under maintenance.\n", "Address A")
+    }
+
+    if err = l.SetMaintenance("Address Z", true); !errors.Is(err, ErrUnknownServer) {
+        t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrUnknownServer, err)
+    }
+}
+
+func TestLoadBalancer_Servers(t *testing.T) {
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.AliveServers = model.BEServers{"Address A": model.NewBEServer("Address A", 1)}
+    l.DownServers = model.BEServers{
+        "Address B": model.NewBEServer("Address B", 1),
+        "Address C": &model.BEServer{Address: "Address C", Maintenance: true},
+    }
+
+    rec := httptest.NewRecorder()
+    l.Maintenance(rec, httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(`{"address": "Address A", "maintenance": true}`)))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("error setting maintenance: expected status %d, got %d.\n", http.StatusOK, rec.Code)
+    }
+    rec = httptest.NewRecorder()
+    l.Maintenance(rec, httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(`{"address": "Address C", "maintenance": false}`)))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("error clearing maintenance: expected status %d, got %d.\n", http.StatusOK, rec.Code)
+    }
+
+    rec = httptest.NewRecorder()
+    l.Servers(rec, httptest.NewRequest(http.MethodGet, "/admin/servers", nil))
+    var body struct {
+        Data []ServerInfo `json:"data"`
+    }
+    if err = json.NewDecoder(rec.Body).Decode(&body); err != nil {
+        t.Fatal(err)
+    }
+
+    expected := map[string]string{"Address A": StateMaintenance, "Address B": StateDown, "Address C": StateDown}
+    if len(body.Data) != len(expected) {
+        t.Fatalf("error listing servers: expected %d servers, got %#v.\n", len(expected), body.Data)
+    }
+    for _, info := range body.Data {
+        if info.State != expected[info.Address] {
+            t.Errorf("error listing servers: expected %s %s, got %s.\n", info.Address, expected[info.Address], info.State)
+        }
+    }
+}
+
+func TestLoadBalancer_RegisterUnderMaintenance(t *testing.T) {
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+    defer backend.Close()
+
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.DownServers[backend.URL] = &model.BEServer{Address: backend.URL, Maintenance: true}
+
+    // The server registers again while it's patched, it stays under maintenance.
+    rec := httptest.NewRecorder()
+    l.Register(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"address": "`+backend.URL+`", "weight": 3}`)))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("error registering server: expected status %d, got %d.\n", http.StatusOK, rec.Code)
+    }
+    srv, ok := l.DownServers[backend.URL]
+    if !ok || !srv.Maintenance || srv.Weight != 3 {
+        t.Errorf("error registering server: expected weight %d under maintenance, got %#v.\n", 3, srv)
+    }
+    if _, ok = l.AliveServers[backend.URL]; ok {
+        t.Errorf("error registering server: expected server under maintenance to take no traffic.\n")
+    }
+}
+
+func TestLoadBalancer_ScanServers(t *testing.T) {
+    probed := make(chan struct{})
+    release := make(chan struct{})
+    var hang sync.Once
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        // Only the first probe hangs.
+        hang.Do(func() {
+            close(probed)
+            <-release
+        })
+    }))
+    defer backend.Close()
+
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.DownServers = model.BEServers{backend.URL: model.NewBEServer(backend.URL, 1)}
+
+    scanned := make(chan struct{})
+    go func() {
+        l.scanServers()
+        close(scanned)
+    }()
+    <-probed
+
+    // The backend hangs, the lock is free meanwhile and requests are still routed.
+    if !l.TryLock() {
+        t.Fatalf("error scanning servers: expected the lock to be released while probing.\n")
+    }
+    l.Unlock()
+    if dest := l.route(httptest.NewRequest(http.MethodGet, "/", nil)); dest.pool != l.Pool {
+        t.Errorf("error routing request: expected pool %s, got %s.\n", l.Pool.Name, dest.pool.Name)
+    }
+
+    // The server put under maintenance while it was probed stays down.
+    if err = l.SetMaintenance(backend.URL, true); err != nil {
+        t.Fatal(err)
+    }
+    close(release)
+    <-scanned
+    if srv, ok := l.DownServers[backend.URL]; !ok || !srv.Maintenance {
+        t.Errorf("error scanning servers: expected %s to stay under maintenance.\n", backend.URL)
+    }
+
+    if err = l.SetMaintenance(backend.URL, false); err != nil {
+        t.Fatal(err)
+    }
+    l.scanServers()
+    if _, ok := l.AliveServers[backend.URL]; !ok {
+        t.Errorf("error scanning servers: expected %s among the alive servers.\n", backend.URL)
+    }
+}
@@ -0,0 +1,302 @@
+package lb
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/internal/lb/response"
+    "LoadBalancer/pkg/balancer"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash/fnv"
+    "log"
+    "net"
+    "net/http"
+    "strings"
+    "sync/atomic"
+    "time"
+)
+
+var (
+    ErrUnknownRoute   = errors.New("error unknown route")
+    ErrNoSplit        = errors.New("error route doesn't split traffic")
+    ErrInvalidWeights = errors.New("error invalid weights")
+)
+
+// split divides the requests of a route between the pools of its legs.
+type split struct {
+    legs    []*leg
+    hashOn  string
+    weights atomic.Pointer[[]int] // Percentage of every leg, in the order of legs. Swapped at runtime by SetSplit.
+}
+
+// leg is a pool of a split, along with the metrics of the requests it took.
+type leg struct {
+    config.SplitLeg
+    requests atomic.Uint64
+    forced   atomic.Uint64 // Requests sent to the leg by a header or cookie, whatever the weights.
+    errors   atomic.Uint64 // Requests that failed or got a 5xx status.
+    latency  atomic.Int64  // Total time of the requests.
+}
+
+// newSplit creates the split of route, which must be valid.
+func newSplit(route config.Route) *split {
+    s := &split{hashOn: route.HashOn}
+    weights := make([]int, 0, len(route.Split))
+    for _, l := range route.Split {
+        s.legs = append(s.legs, &leg{SplitLeg: l})
+        weights = append(weights, l.Weight)
+    }
+    s.weights.Store(&weights)
+    return s
+}
+
+// pick returns the leg of req, and whether a header or cookie of req forced it.
+// Requests with the same key fall on the same leg as long as the weights are the same. When the weight of a leg grows,
+// its clients stay on it and it takes some of the clients of the legs after it.
+func (s *split) pick(req *http.Request) (*leg, bool) {
+    for _, l := range s.legs {
+        if l.forces(req) {
+            return l, true
+        }
+    }
+
+    h := fnv.New32a()
+    _, _ = h.Write([]byte(s.key(req)))
+    bucket := int(h.Sum32() % 100)
+    for i, weight := range *s.weights.Load() {
+        if bucket < weight {
+            return s.legs[i], false
+        }
+        bucket -= weight
+    }
+    // The weights sum up to 100.
+    return s.legs[len(s.legs)-1], false
+}
+
+// key returns the key of the client of req: the header or cookie the split hashes on, the client IP when it hashes on
+// the IP or when req lacks the header or cookie.
+func (s *split) key(req *http.Request) string {
+    switch {
+    case strings.HasPrefix(s.hashOn, config.HashOnHeader):
+        if value := req.Header.Get(strings.TrimPrefix(s.hashOn, config.HashOnHeader)); value != "" {
+            return value
+        }
+    case strings.HasPrefix(s.hashOn, config.HashOnCookie):
+        if cookie, err := req.Cookie(strings.TrimPrefix(s.hashOn, config.HashOnCookie)); err == nil && cookie.Value != "" {
+            return cookie.Value
+        }
+    }
+    if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+        return host
+    }
+    return req.RemoteAddr
+}
+
+// forces reports whether req carries one of the headers or cookies that force the leg.
+func (l *leg) forces(req *http.Request) bool {
+    for name, value := range l.Force.Headers {
+        if req.Header.Get(name) == value {
+            return true
+        }
+    }
+    for name, value := range l.Force.Cookies {
+        if cookie, err := req.Cookie(name); err == nil && cookie.Value == value {
+            return true
+        }
+    }
+    return false
+}
+
+// record adds the result of a request to the metrics of the leg.
+func (l *leg) record(result balancer.Result, forced bool) {
+    l.requests.Add(1)
+    if forced {
+        l.forced.Add(1)
+    }
+    if result.Err != nil || result.Status >= http.StatusInternalServerError {
+        l.errors.Add(1)
+    }
+    l.latency.Add(int64(result.Latency))
+}
+
+// LegInfo describes a leg of a split and the requests it took.
+type LegInfo struct {
+    Pool     string  `json:"pool"`
+    Weight   int     `json:"weight"` // Percentage of the requests.
+    Requests uint64  `json:"requests"`
+    Forced   uint64  `json:"forced"` // Requests sent to the leg by a header or cookie, whatever the weight.
+    Errors   uint64  `json:"errors"` // Requests that failed or got a 5xx status.
+    Latency  float64 `json:"latency_ms"`
+}
+
+// SplitInfo describes how a route splits its requests.
+type SplitInfo struct {
+    Route  string    `json:"route"`
+    HashOn string    `json:"hash_on"`
+    Legs   []LegInfo `json:"legs"`
+}
+
+// info describes the split of the route name.
+func (s *split) info(name string) SplitInfo {
+    info := SplitInfo{Route: name, HashOn: s.hashOn, Legs: make([]LegInfo, 0, len(s.legs))}
+    if info.HashOn == "" {
+        info.HashOn = config.HashOnIP
+    }
+    weights := *s.weights.Load()
+    for i, l := range s.legs {
+        legInfo := LegInfo{
+            Pool:     l.Pool,
+            Weight:   weights[i],
+            Requests: l.requests.Load(),
+            Forced:   l.forced.Load(),
+            Errors:   l.errors.Load(),
+        }
+        if legInfo.Requests > 0 {
+            legInfo.Latency = float64(l.latency.Load()) / float64(legInfo.Requests) / float64(time.Millisecond)
+        }
+        info.Legs = append(info.Legs, legInfo)
+    }
+    return info
+}
+
+// splitNamed returns the split of the route name. Callers must hold the lock, for reading at least.
+func (l *LoadBalancer) splitNamed(name string) (*split, error) {
+    if l.router != nil {
+        for _, rl := range l.router.rules {
+            if rl.Name != name {
+                continue
+            }
+            if rl.split == nil {
+                return nil, ErrNoSplit
+            }
+            return rl.split, nil
+        }
+    }
+    return nil, ErrUnknownRoute
+}
+
+// SplitList returns the splits of the routes, by priority.
+func (l *LoadBalancer) SplitList() []SplitInfo {
+    l.RLock()
+    defer l.RUnlock()
+
+    splits := []SplitInfo{}
+    if l.router != nil {
+        for _, rl := range l.router.rules {
+            if rl.split != nil {
+                splits = append(splits, rl.split.info(rl.Name))
+            }
+        }
+    }
+    return splits
+}
+
+// SetSplit sets the percentage of the requests of the route name that every pool of its split takes, by pool name.
+// Every pool of the split must be given, and the percentages must sum up to 100. The weights hold until a reload
+// changes the route.
+func (l *LoadBalancer) SetSplit(name string, weights map[string]int) error {
+    l.RLock()
+    defer l.RUnlock()
+
+    s, err := l.splitNamed(name)
+    if err != nil {
+        return err
+    }
+    if len(weights) != len(s.legs) {
+        return ErrInvalidWeights
+    }
+    next := make([]int, 0, len(s.legs))
+    total := 0
+    for _, leg := range s.legs {
+        weight, ok := weights[leg.Pool]
+        if !ok || weight < 0 || weight > 100 {
+            return ErrInvalidWeights
+        }
+        next = append(next, weight)
+        total += weight
+    }
+    if total != 100 {
+        return ErrInvalidWeights
+    }
+    s.weights.Store(&next)
+    return nil
+}
+
+// SplitRequest is the body of a POST request on endpoint '/admin/split'.
+type SplitRequest struct {
+    Route   string         `json:"route"`
+    Weights map[string]int `json:"weights"` // Pool: percentage of the requests.
+}
+
+// Split is a handler that is used by endpoint '/admin/split'.
+// On GET it describes the split of the route given by query parameter route, or the splits of all routes without it,
+// along with the requests, errors and mean latency of every leg. On POST it sets the weights of a split at runtime.
+func (l *LoadBalancer) Split(w http.ResponseWriter, req *http.Request) {
+    switch req.Method {
+    case http.MethodGet:
+        name := req.URL.Query().Get("route")
+        if name == "" {
+            response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(l.SplitList()))
+            return
+        }
+        l.RLock()
+        s, err := l.splitNamed(name)
+        var info SplitInfo
+        if err == nil {
+            info = s.info(name)
+        }
+        l.RUnlock()
+        if err != nil {
+            writeSplitError(w, name, err)
+            return
+        }
+        response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(info))
+    case http.MethodPost:
+        var p SplitRequest
+        decoder := json.NewDecoder(req.Body)
+        decoder.DisallowUnknownFields()
+        if err := decoder.Decode(&p); err != nil {
+            response.WriteJsonResponse(w, http.StatusBadRequest, response.NewErrorResponse(err))
+            return
+        }
+
+        if err := l.SetSplit(p.Route, p.Weights); err != nil {
+            writeSplitError(w, p.Route, err)
+            return
+        }
+        log.Printf("Weights of route %s set to %v.\n", p.Route, p.Weights)
+
+        l.RLock()
+        var info SplitInfo
+        if s, err := l.splitNamed(p.Route); err == nil {
+            info = s.info(p.Route)
+        }
+        l.RUnlock()
+        response.WriteJsonResponse(w, http.StatusOK, response.NewSuccessResponse(info))
+    default:
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Wrong method. Expected %s or %s, got %s.", http.MethodGet, http.MethodPost, req.Method)})
+        response.WriteJsonResponse(w, http.StatusMethodNotAllowed, responsePayload)
+    }
+}
+
+// writeSplitError responds with err, returned for the split of the route name.
+func writeSplitError(w http.ResponseWriter, name string, err error) {
+    status := http.StatusBadRequest
+    title := fmt.Sprintf("Invalid weights for route %s, expected a percentage per pool of the split summing up to 100.", name)
+    switch {
+    case errors.Is(err, ErrUnknownRoute):
+        status = http.StatusNotFound
+        title = fmt.Sprintf("Route %s unknown.", name)
+    case errors.Is(err, ErrNoSplit):
+        status = http.StatusNotFound
+        title = fmt.Sprintf("Route %s doesn't split traffic.", name)
+    }
+    responsePayload := response.NewFailResponse(
+        struct {
+            Title string `json:"title"`
+        }{Title: title})
+    response.WriteJsonResponse(w, status, responsePayload)
+}
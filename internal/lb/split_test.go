@@ -0,0 +1,187 @@
+package lb
+
+import (
+    "LoadBalancer/internal/config"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestSplit_Pick(t *testing.T) {
+    s := newSplit(config.Route{Split: []config.SplitLeg{
+        {Pool: "stable", Weight: 90},
+        {Pool: "canary", Weight: 10, Force: config.Force{Headers: map[string]string{"X-Canary": "always"}}},
+    }})
+
+    client := func(i int) *http.Request {
+        req := httptest.NewRequest(http.MethodGet, "/", nil)
+        req.RemoteAddr = fmt.Sprintf("10.0.%d.%d:%d", i/256, i%256, 1024+i)
+        return req
+    }
+    canary := make(map[int]bool)
+    for i := 0; i < 1000; i++ {
+        leg, forced := s.pick(client(i))
+        if forced {
+            t.Fatalf("error picking leg: expected no leg to be forced.\n")
+        }
+        if leg.Pool == "canary" {
+            canary[i] = true
+        }
+    }
+    if len(canary) < 50 || len(canary) > 150 {
+        t.Errorf("error splitting requests: expected about %d clients on the canary, got %d.\n", 100, len(canary))
+    }
+
+    // A client stays on its leg, whatever its port, and the clients of the canary stay on it when it grows.
+    s.weights.Store(&[]int{80, 20})
+    grown := 0
+    for i := 0; i < 1000; i++ {
+        req := client(i)
+        req.RemoteAddr = strings.Replace(req.RemoteAddr, fmt.Sprintf(":%d", 1024+i), ":80", 1)
+        leg, _ := s.pick(req)
+        if canary[i] && leg.Pool != "canary" {
+            t.Fatalf("error keeping client %s on the canary: got %s.\n", req.RemoteAddr, leg.Pool)
+        }
+        if leg.Pool == "canary" {
+            grown++
+        }
+    }
+    if grown <= len(canary) {
+        t.Errorf("error growing the canary: expected more than %d clients, got %d.\n", len(canary), grown)
+    }
+
+    req := client(0)
+    req.Header.Set("X-Canary", "always")
+    if leg, forced := s.pick(req); leg.Pool != "canary" || !forced {
+        t.Errorf("error forcing leg: expected %s, got %s forced %t.\n", "canary", leg.Pool, forced)
+    }
+}
+
+func TestSplit_Key(t *testing.T) {
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    req.RemoteAddr = "10.0.0.1:1234"
+    req.Header.Set("X-User", "alice")
+    req.AddCookie(&http.Cookie{Name: "session", Value: "s3"})
+
+    testCases := map[string]string{
+        "":               "10.0.0.1",
+        "ip":             "10.0.0.1",
+        "header:X-User":  "alice",
+        "cookie:session": "s3",
+        // Clients without the header or cookie are keyed by IP.
+        "header:X-Tenant": "10.0.0.1",
+        "cookie:user":     "10.0.0.1",
+    }
+    for hashOn, expected := range testCases {
+        s := &split{hashOn: hashOn}
+        if key := s.key(req); key != expected {
+            t.Errorf("error keying client on %q: expected %s, got %s.\n", hashOn, expected, key)
+        }
+    }
+}
+
+func TestLoadBalancer_Split(t *testing.T) {
+    stable := newNamedBackend(t, "stable")
+    canary := newNamedBackend(t, "canary")
+
+    data := `
+pools:
+  - name: stable
+    backends:
+      - address: ` + stable.URL + `
+  - name: canary
+    backends:
+      - address: ` + canary.URL + `
+routes:
+  - name: checkout
+    match:
+      path_prefix: /checkout
+    split:
+      - pool: stable
+        weight: 100
+      - pool: canary
+        weight: 0
+        force:
+          headers:
+            X-Canary: always
+`
+    cfg, err := config.Parse([]byte(data))
+    if err != nil {
+        t.Fatal(err)
+    }
+    l, err := NewFromConfig(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.scanServers()
+
+    req := httptest.NewRequest(http.MethodGet, "/checkout", nil)
+    if body := forward(l, req); body != "stable /checkout" {
+        t.Errorf("error splitting request: expected %s, got %s.\n", "stable /checkout", body)
+    }
+    req.Header.Set("X-Canary", "always")
+    if body := forward(l, req); body != "canary /checkout" {
+        t.Errorf("error forcing request: expected %s, got %s.\n", "canary /checkout", body)
+    }
+
+    post := func(body string) *httptest.ResponseRecorder {
+        rec := httptest.NewRecorder()
+        l.Split(rec, httptest.NewRequest(http.MethodPost, "/admin/split", strings.NewReader(body)))
+        return rec
+    }
+    if rec := post(`{"route": "checkout", "weights": {"stable": 0, "canary": 100}}`); rec.Code != http.StatusOK {
+        t.Fatalf("error setting weights: expected status %d, got %d %s.\n", http.StatusOK, rec.Code, rec.Body.String())
+    }
+    if body := forward(l, httptest.NewRequest(http.MethodGet, "/checkout", nil)); body != "canary /checkout" {
+        t.Errorf("error splitting request with new weights: expected %s, got %s.\n", "canary /checkout", body)
+    }
+
+    errorCases := []struct {
+        body     string
+        expected int
+    }{
+        {body: `{"route": "cart", "weights": {"stable": 50, "canary": 50}}`, expected: http.StatusNotFound},
+        {body: `{"route": "checkout", "weights": {"stable": 50, "canary": 40}}`, expected: http.StatusBadRequest},
+        {body: `{"route": "checkout", "weights": {"stable": 50, "beta": 50}}`, expected: http.StatusBadRequest},
+        {body: `{"route": "checkout", "weights": {"stable": 100}}`, expected: http.StatusBadRequest},
+    }
+    for _, tc := range errorCases {
+        if rec := post(tc.body); rec.Code != tc.expected {
+            t.Errorf("error setting weights %s: expected status %d, got %d.\n", tc.body, tc.expected, rec.Code)
+        }
+    }
+
+    // Every leg reports the requests it took.
+    rec := httptest.NewRecorder()
+    l.Split(rec, httptest.NewRequest(http.MethodGet, "/admin/split?route=checkout", nil))
+    for _, expected := range []string{
+        `"route":"checkout","hash_on":"ip"`,
+        `{"pool":"stable","weight":0,"requests":1,"forced":0,"errors":0,`,
+        `{"pool":"canary","weight":100,"requests":2,"forced":1,"errors":0,`,
+    } {
+        if !strings.Contains(rec.Body.String(), expected) {
+            t.Errorf("error describing split: expected %s, got %s.\n", expected, rec.Body.String())
+        }
+    }
+
+    // A reload keeps the runtime weights of an unchanged route, and resets the ones of a changed route.
+    reload := func(data string) {
+        cfg, err := config.Parse([]byte(data))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if _, err = l.Reload(cfg); err != nil {
+            t.Fatal(err)
+        }
+    }
+    reload(data + "  - name: cart\n    pool: stable\n")
+    if splits := l.SplitList(); len(splits) != 1 || splits[0].Legs[1].Weight != 100 || splits[0].Legs[1].Requests != 2 {
+        t.Errorf("error keeping weights: got %#v.\n", splits)
+    }
+    reload(data + "    hash_on: header:X-User\n")
+    if splits := l.SplitList(); len(splits) != 1 || splits[0].Legs[1].Weight != 0 || splits[0].Legs[1].Requests != 0 {
+        t.Errorf("error resetting weights: got %#v.\n", splits)
+    }
+}
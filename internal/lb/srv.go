@@ -1,47 +1,85 @@
 package lb
 
 import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/internal/discovery"
     "LoadBalancer/internal/lb/response"
     "LoadBalancer/internal/lbalgo"
-    "LoadBalancer/internal/model"
+    "LoadBalancer/internal/registry"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
     "encoding/json"
     "errors"
     "fmt"
     "io"
     "log"
     "net/http"
+    "net/url"
+    "strings"
     "sync"
+    "sync/atomic"
     "time"
 )
 
-// LoadBalancer distributes traffic to AliveServers.
+// LoadBalancer distributes traffic to the AliveServers of its pools.
 type LoadBalancer struct {
     http.ServeMux
     http.Client
     sync.RWMutex
-    Port         int
-    AliveServers model.BEServers
-    DownServers  model.BEServers
-    ScanDone     chan struct{}
-    ScanPeriod   time.Duration
-    AlgoDriver   lbalgo.LBAlgo
+    *Pool                              // Default pool, it takes the requests that no route sends to another pool.
+    pools             map[string]*Pool // Name: pool, the default one included.
+    router            *router          // Picks the pool of a request, nil to send all of them to the default pool.
+    routing           atomic.Pointer[routing]
+    Port              int
+    Listeners         []string // Addresses the load balancer accepts clients on, ":Port" when empty.
+    AdminListeners    []string // Addresses the admin API is served on, the client listeners when empty.
+    ScanDone          chan struct{}
+    ScanPeriod        time.Duration
+    rescan            chan struct{} // Probes the servers right away and restarts the scan period, e.g. after a reload.
+    ReadTimeout       time.Duration // Timeouts of the client connections, 0 for no limit.
+    WriteTimeout      time.Duration
+    IdleTimeout       time.Duration
+    inflight          sync.Map               // Address: *tracker counting the requests in flight.
+    leases            map[string]*lease      // Lease ID: lease of a registration that expires unless renewed.
+    config            *config.Config         // Running configuration, nil when the load balancer wasn't created from one.
+    Store             registry.Store         // Persists the registrations, nil to keep them in memory only.
+    saver             saver                  // Saves the registrations to the Store outside the lock.
+    providers         []discovery.Provider   // Discovery providers started with the load balancer.
+    discovered        map[string]*discovered // Provider name: servers registered by the discovery provider.
+    discoveryDone     chan struct{}          // Stops the discovery providers.
+    Locality          *lbalgo.ZoneConfig     // Zone of the load balancer, nil when it doesn't prefer any zone.
+    Seed              int64                  // Seed of randomized algorithms, 0 for a random seed.
+    FailoverThreshold int                    // Percentage of healthy members below which a priority tier spills over to the next one.
+    SlowStart         time.Duration          // Ramp-up window of servers that become healthy, 0 disables slow-start.
+    SlowStartMode     string                 // model.SlowStartLinear or model.SlowStartExponential.
 }
 
-// New creates an instance of LoadBalancer.
-func New(port int, scanPeriod int, algoBrief string) (*LoadBalancer, error) {
-    algoDriver, err := lbalgo.ChooseAlgo(algoBrief)
+// New creates an instance of LoadBalancer with a default pool load balanced by algoBrief.
+// algoParams are the parameters of the algorithm, nil when it takes none.
+func New(port int, scanPeriod int, algoBrief string, algoParams balancer.Params) (*LoadBalancer, error) {
+    algo, err := lbalgo.ChooseAlgo(algoBrief, algoParams)
     if err != nil {
         return nil, err
     }
 
-    return &LoadBalancer{
-        Port:         port,
-        AliveServers: make(map[string]*model.BEServer),
-        DownServers:  make(map[string]*model.BEServer),
-        ScanDone:     make(chan struct{}),
-        ScanPeriod:   time.Duration(scanPeriod) * time.Second,
-        AlgoDriver:   algoDriver, // no server in the algo driver now.
-    }, nil
+    // no server in the algo driver now.
+    pool := newPool(config.DefaultPool, algo, algoBrief, algoParams)
+    pool.HealthPath = config.DefaultHealthPath
+    l := &LoadBalancer{
+        Pool:              pool,
+        pools:             map[string]*Pool{pool.Name: pool},
+        Port:              port,
+        leases:            make(map[string]*lease),
+        ScanDone:          make(chan struct{}),
+        rescan:            make(chan struct{}, 1),
+        discovered:        make(map[string]*discovered),
+        discoveryDone:     make(chan struct{}),
+        ScanPeriod:        time.Duration(scanPeriod) * time.Second,
+        FailoverThreshold: DefaultFailoverThreshold,
+        SlowStartMode:     model.SlowStartLinear,
+    }
+    l.publish()
+    return l, nil
 }
 
 // Start starts the server.
@@ -49,15 +87,68 @@ func New(port int, scanPeriod int, algoBrief string) (*LoadBalancer, error) {
 func (l *LoadBalancer) Start() {
     l.HandleFunc("/", l.Forward)
     l.HandleFunc("/register", l.Register)
+    l.HandleFunc("/heartbeat", l.Heartbeat)
+    l.HandleFunc("/deregister", l.Deregister)
+    admin := l.handleAdmin()
+
+    listeners := l.Listeners
+    if len(listeners) == 0 {
+        listeners = []string{fmt.Sprintf(":%d", l.Port)}
+    }
+    l.serve(listeners, l)
+    if len(l.AdminListeners) > 0 {
+        l.serve(l.AdminListeners, admin)
+    }
+
+    for _, provider := range l.providers {
+        l.Discover(provider)
+    }
 
     go func() {
-        if err := http.ListenAndServe(fmt.Sprintf(":%d", l.Port), l); !errors.Is(err, http.ErrServerClosed) {
-            log.Fatalf("Load balancer server error: %v", err)
-        }
-        return
+        // Probe the servers added before start, e.g. the static ones, right away instead of at the first tick.
+        l.scanServers()
+        l.ScanPeriodically()
     }()
+}
+
+// handleAdmin registers the endpoints of the admin API and returns the handler serving them.
+// Without admin listeners, they share the mux of the clients: any client can reach them, and they shadow the same paths
+// of the backend servers. With admin listeners, they get a mux of their own and client requests on /admin/ are
+// forwarded like any other.
+func (l *LoadBalancer) handleAdmin() http.Handler {
+    admin := &l.ServeMux
+    if len(l.AdminListeners) > 0 {
+        admin = http.NewServeMux()
+    }
+    admin.HandleFunc("/admin/algo", l.Algo)
+    admin.HandleFunc("/admin/sticky", l.StickyStats)
+    admin.HandleFunc("/admin/drain", l.Drain)
+    admin.HandleFunc("/admin/servers", l.Servers)
+    admin.HandleFunc("/admin/maintenance", l.Maintenance)
+    admin.HandleFunc("/admin/discovery", l.Discovery)
+    admin.HandleFunc("/admin/pools", l.Pools)
+    admin.HandleFunc("/admin/routes", l.Routes)
+    admin.HandleFunc("/admin/split", l.Split)
+    return admin
+}
 
-    go l.ScanPeriodically()
+// serve serves handler on every address of listeners.
+func (l *LoadBalancer) serve(listeners []string, handler http.Handler) {
+    for _, addr := range listeners {
+        server := &http.Server{
+            Addr:         addr,
+            Handler:      handler,
+            ReadTimeout:  l.ReadTimeout,
+            WriteTimeout: l.WriteTimeout,
+            IdleTimeout:  l.IdleTimeout,
+        }
+        go func() {
+            if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+                log.Fatalf("Load balancer server error: %v", err)
+            }
+            return
+        }()
+    }
 }
 
 // Close shuts down all goroutines and closes the Done channel.
@@ -66,12 +157,19 @@ func (l *LoadBalancer) Close() {
     // This shuts down ScanPeriodically().
     l.ScanDone <- struct{}{}
     close(l.ScanDone)
+    // This shuts down the discovery providers.
+    close(l.discoveryDone)
 }
 
 // RegisterRequest is used for registering backend servers.
 type RegisterRequest struct {
-    Address string `json:"address"`
-    Weight  int    `json:"weight"`
+    Address  string `json:"address"`
+    Pool     string `json:"pool,omitempty"` // Pool the server joins, the default pool when empty.
+    Weight   int    `json:"weight"`
+    Priority int    `json:"priority"` // 0 primary, 1 secondary, 2 disaster recovery.
+    Region   string `json:"region"`
+    Zone     string `json:"zone"`
+    TTL      int    `json:"ttl"` // Lease in seconds, the registration expires unless renewed by heartbeats. 0 for none.
 }
 
 // Register is a handler that is used by endpoint '/register'.
@@ -94,22 +192,82 @@ func (l *LoadBalancer) Register(w http.ResponseWriter, req *http.Request) {
         response.WriteJsonResponse(w, http.StatusInternalServerError, response.NewErrorResponse(err))
         return
     }
-    // Ping the address.
-    serverAlive := l.healthCheck(p.Address)
+    if p.Priority < model.PriorityPrimary || p.Priority > model.PriorityDisasterRecovery {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Invalid priority %d. Expected %d to %d.", p.Priority, model.PriorityPrimary, model.PriorityDisasterRecovery)})
+        response.WriteJsonResponse(w, http.StatusBadRequest, responsePayload)
+        return
+    }
+    if p.TTL < 0 {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("Invalid ttl %d. Expected 0 or more seconds.", p.TTL)})
+        response.WriteJsonResponse(w, http.StatusBadRequest, responsePayload)
+        return
+    }
+
+    // Ping the address without the lock, with the health check settings of the pool that a reload may change meanwhile.
     l.RLock()
-    defer l.RUnlock()
+    pool, ok := l.poolNamed(p.Pool)
+    var check probe
+    if ok {
+        check = pool.probe(p.Address, false)
+    }
+    l.RUnlock()
+    serverAlive := ok && check.run()
+    defer l.save()
+    l.Lock()
+    defer l.Unlock()
+
+    // The pool may also have been removed by a reload meanwhile.
+    if pool, ok = l.poolNamed(p.Pool); !ok {
+        l.writeUnknownPool(w, p.Pool)
+        return
+    }
+    if other, _ := l.serverPool(p.Address); other != nil && other != pool {
+        responsePayload := response.NewFailResponse(
+            struct {
+                Title string `json:"title"`
+            }{Title: fmt.Sprintf("%s registered in pool %s, deregister it first.", p.Address, other.Name)})
+        response.WriteJsonResponse(w, http.StatusConflict, responsePayload)
+        return
+    }
+
+    srv := model.NewBEServer(p.Address, p.Weight)
+    srv.Priority = p.Priority
+    srv.Region = p.Region
+    srv.Zone = p.Zone
+    // Registering again keeps the state of the server, as a reload does: a draining server keeps draining, and an alive
+    // one goes on with its slow-start.
+    prev, wasAlive := pool.AliveServers[p.Address]
+    if !wasAlive {
+        prev = pool.DownServers[p.Address]
+    }
+    if prev != nil {
+        srv.Draining = prev.Draining
+        srv.SlowStart = prev.SlowStart
+    }
+    // A server under maintenance stays so when it registers again, until the maintenance is cleared.
+    if prev != nil && prev.Maintenance {
+        srv.Maintenance = true
+        pool.DownServers[p.Address] = srv
+        l.writeRegistered(w, pool, p)
+        l.persist()
+        return
+    }
+
     // Only register server when backend server is alive.
     if serverAlive {
-        l.AliveServers[p.Address] = model.NewBEServer(p.Address, p.Weight)
-        responsePayload := response.NewSuccessResponse(
-            struct {
-                Server string `json:"server"`
-                Weight int    `json:"weight"`
-            }{
-                Server: p.Address,
-                Weight: p.Weight,
-            })
-        response.WriteJsonResponse(w, http.StatusOK, responsePayload)
+        if !wasAlive {
+            l.startSlow(srv)
+        }
+        pool.AliveServers[p.Address] = srv
+        delete(pool.DownServers, p.Address)
+        l.writeRegistered(w, pool, p)
+        l.persist()
         return
     } else {
         // Return service not alive, registration failed.
@@ -122,35 +280,124 @@ func (l *LoadBalancer) Register(w http.ResponseWriter, req *http.Request) {
     }
 }
 
-// Forward is a handler that distributes traffic to all AliveServers.
+// writeRegistered responds that the server of p is registered in pool, with a lease when p asks for one.
+// Callers must hold the lock.
+func (l *LoadBalancer) writeRegistered(w http.ResponseWriter, pool *Pool, p RegisterRequest) {
+    var leaseID string
+    if p.TTL > 0 {
+        ls, err := l.grantLease(p.Address, time.Duration(p.TTL)*time.Second)
+        if err != nil {
+            response.WriteJsonResponse(w, http.StatusInternalServerError, response.NewErrorResponse(err))
+            return
+        }
+        leaseID = ls.ID
+    } else {
+        // Registering again without a lease makes the registration permanent.
+        l.revokeLease(p.Address)
+    }
+
+    responsePayload := response.NewSuccessResponse(
+        struct {
+            Server   string `json:"server"`
+            Pool     string `json:"pool"`
+            Weight   int    `json:"weight"`
+            Priority int    `json:"priority"`
+            Region   string `json:"region,omitempty"`
+            Zone     string `json:"zone,omitempty"`
+            Lease    string `json:"lease,omitempty"`
+            TTL      int    `json:"ttl,omitempty"`
+        }{
+            Server:   p.Address,
+            Pool:     pool.Name,
+            Weight:   p.Weight,
+            Priority: p.Priority,
+            Region:   p.Region,
+            Zone:     p.Zone,
+            Lease:    leaseID,
+            TTL:      p.TTL,
+        })
+    response.WriteJsonResponse(w, http.StatusOK, responsePayload)
+}
+
+// Forward is a handler that distributes traffic to the AliveServers of the pool the request is routed to.
 func (l *LoadBalancer) Forward(w http.ResponseWriter, req *http.Request) {
-    // 1. Forward the request to an address from the Server lists.
-    addr, err := l.AlgoDriver.ChooseServer(req)
+    // 1. Forward the request to an address from the Server lists of its pool.
+    // Keep the algorithm that chose the server, even if it's swapped while the request is in flight.
+    dest := l.route(req)
+    algo := dest.pool.AlgoDriver()
+    selection, err := algo.ChooseServer(req)
     if err != nil {
         log.Println(err)
+        if dest.leg != nil {
+            dest.leg.record(balancer.Result{Err: err}, dest.forced)
+        }
         response.WriteJsonResponse(w, http.StatusServiceUnavailable, response.NewErrorResponse(err))
         return
     }
 
-    newReq, err := copyRequest(req, addr)
+    // Report the result of the request to the algorithm, whatever happens below.
+    addr := selection.Address
+    var result balancer.Result
+    start := time.Now()
+    t := l.tracker(addr)
+    t.requests.Add(1)
+    defer func() {
+        result.Latency = time.Since(start)
+        selection.Done(result)
+        if dest.leg != nil {
+            dest.leg.record(result, dest.forced)
+        }
+        if t.requests.Add(-1) == 0 {
+            l.reportDrained(addr, t)
+        }
+    }()
 
+    newReq, err := copyRequest(req, addr, dest.path)
     if err != nil {
         log.Println(err)
+        result.Err = err
+        response.WriteJsonResponse(w, http.StatusInternalServerError, response.NewErrorResponse(err))
+        return
     }
 
     // Response from backend service.
     resp, err := l.Do(newReq)
     if err != nil {
         log.Println(err)
+        result.Err = err
+        response.WriteJsonResponse(w, http.StatusBadGateway, response.NewErrorResponse(err))
+        return
+    }
+    // The server switched protocols, e.g. to WebSocket, the connection is proxied until either side closes it.
+    if resp.StatusCode == http.StatusSwitchingProtocols && isUpgrade(req) {
+        result.Status = resp.StatusCode
+        if result.Err = tunnel(w, resp); result.Err != nil {
+            log.Println(result.Err)
+        }
+        return
     }
     defer func() {
         err = resp.Body.Close()
         if err != nil {
-            log.Fatal(err)
+            log.Println(err)
         }
     }()
 
     bodyBytes, err := io.ReadAll(resp.Body)
+    result.Status = resp.StatusCode
+    result.Bytes = int64(len(bodyBytes))
+    if err != nil {
+        log.Println(err)
+        result.Err = err
+    }
+
+    // Cookies go before the body: the ones of the application, then the sticky cookie.
+    for _, cookie := range resp.Header.Values("Set-Cookie") {
+        w.Header().Add("Set-Cookie", cookie)
+    }
+    if sticky, ok := algo.(*lbalgo.CookieSticky); ok {
+        sticky.Stick(w, req, resp, addr)
+    }
     // Write response back to client.
     _, err = fmt.Fprint(w, fmt.Sprintf("From backend server: %s, data: [ '%s' ].\n", addr, string(bodyBytes)))
     if err != nil {
@@ -159,9 +406,28 @@ func (l *LoadBalancer) Forward(w http.ResponseWriter, req *http.Request) {
 
 }
 
-func copyRequest(req *http.Request, target string) (*http.Request, error) {
+// reportDrained logs that address is drained when it's draining, and deregisters it when a discovery provider dropped
+// it meanwhile. t is the tracker of address, which tells both without the lock.
+func (l *LoadBalancer) reportDrained(address string, t *tracker) {
+    if t.draining.Load() {
+        log.Printf("Server %s drained.\n", address)
+    }
+    if t.vanished.Load() {
+        l.removeVanished(address)
+    }
+}
+
+// copyRequest copies req to send it to the server target, with path and the query of req.
+func copyRequest(req *http.Request, target string, path string) (*http.Request, error) {
     // The general form represented is: [scheme:][//[userinfo@]host][/]path[?query][#fragment]
-    r, err := http.NewRequest(req.Method, target, req.Body)
+    u, err := url.Parse(target)
+    if err != nil {
+        return nil, err
+    }
+    u.Path = strings.TrimSuffix(u.Path, "/") + path
+    u.RawPath = ""
+    u.RawQuery = req.URL.RawQuery
+    r, err := http.NewRequest(req.Method, u.String(), req.Body)
     if err != nil {
         return nil, err
     }
@@ -174,14 +440,32 @@ func copyRequest(req *http.Request, target string) (*http.Request, error) {
     return r, nil
 }
 
-// healthCheck sends a request to the targetServer.
+// probe is a health check of a server, with the settings its pool had when the check was planned so that it runs
+// without the lock.
+type probe struct {
+    pool    *Pool
+    address string
+    alive   bool // Whether the server was alive when the check was planned.
+    path    string
+    timeout time.Duration
+}
+
+// probe plans a health check of the server at address. Callers must hold the lock, for reading at least.
+func (pool *Pool) probe(address string, alive bool) probe {
+    return probe{pool: pool, address: address, alive: alive, path: pool.HealthPath, timeout: pool.HealthTimeout}
+}
+
+// run sends a request to the health path of the server. It must run without the lock, since the server may take up to
+// the timeout to answer, or forever without one.
 // Returns a boolean representing the server health status.
-func (l *LoadBalancer) healthCheck(targetServer string) bool {
-    healthCheckEndpoint := fmt.Sprintf("%s/health", targetServer)
-    resp, err := http.Get(healthCheckEndpoint)
+func (p probe) run() bool {
+    healthCheckEndpoint := p.address + p.path
+    client := http.Client{Timeout: p.timeout}
+    resp, err := client.Get(healthCheckEndpoint)
     if err != nil {
         return false
     }
+    _ = resp.Body.Close()
 
     if resp.StatusCode != http.StatusOK {
         return false
@@ -192,41 +476,98 @@ func (l *LoadBalancer) healthCheck(targetServer string) bool {
 
 // ScanPeriodically triggers the scan periodically in a different goroutine.
 func (l *LoadBalancer) ScanPeriodically() {
-    scanTicker := time.NewTicker(l.ScanPeriod)
+    scanTicker := time.NewTicker(l.scanPeriod())
     defer scanTicker.Stop()
+    leaseTicker := time.NewTicker(leaseCheck)
+    defer leaseTicker.Stop()
     for {
         select {
         case <-l.ScanDone:
             return
         case <-scanTicker.C:
             l.scanServers()
+        case <-l.rescan:
+            scanTicker.Reset(l.scanPeriod())
+            l.scanServers()
+        case <-leaseTicker.C:
+            l.expireLeases()
         }
     }
 }
 
-// scanServers checks all registered servers.
+// scanPeriod returns the ScanPeriod, which a reload can change.
+func (l *LoadBalancer) scanPeriod() time.Duration {
+    l.RLock()
+    defer l.RUnlock()
+    return l.ScanPeriod
+}
+
+// scanServers checks all registered servers, pool by pool.
 // This method enables the load balancer to manage servers that come back online after passing health checks and to remove servers that failed.
+// The servers are checked without the lock, which is only taken to move them between AliveServers and DownServers.
 func (l *LoadBalancer) scanServers() {
     l.RLock()
-    // Check all servers in AliveServers.
-    for addr := range l.AliveServers {
-        healthy := l.healthCheck(addr)
-        if !healthy {
-            l.DownServers[addr] = l.AliveServers[addr]
-            delete(l.AliveServers, addr)
+    var probes []probe
+    for _, pool := range l.pools {
+        probes = append(probes, pool.probes()...)
+    }
+    l.RUnlock()
+
+    healthy := make([]bool, len(probes))
+    for i, p := range probes {
+        healthy[i] = p.run()
+    }
+
+    l.Lock()
+    defer l.Unlock()
+    for i, p := range probes {
+        l.settle(p, healthy[i])
+    }
+    // Update the algo drivers with the alive servers of the tiers that should take traffic.
+    for _, pool := range l.pools {
+        l.renew(pool)
+    }
+}
+
+// probes plans the health checks of the servers of pool: all alive ones, and the down ones not under maintenance.
+// Callers must hold the lock, for reading at least.
+func (pool *Pool) probes() []probe {
+    probes := make([]probe, 0, len(pool.AliveServers)+len(pool.DownServers))
+    for addr := range pool.AliveServers {
+        probes = append(probes, pool.probe(addr, true))
+    }
+    for addr, srv := range pool.DownServers {
+        if !srv.Maintenance {
+            probes = append(probes, pool.probe(addr, false))
         }
     }
+    return probes
+}
 
-    // Check all servers in DownServers.
-    for addr := range l.DownServers {
-        healthy := l.healthCheck(addr)
-        if healthy {
-            l.AliveServers[addr] = l.DownServers[addr]
-            delete(l.DownServers, addr)
+// settle moves the server of p to AliveServers or DownServers after its health check. A server that was deregistered,
+// moved or put under maintenance while it was checked is left as it is. Callers must hold the lock.
+func (l *LoadBalancer) settle(p probe, healthy bool) {
+    pool := p.pool
+    if p.alive {
+        if srv, ok := pool.AliveServers[p.address]; ok && !healthy {
+            pool.DownServers[p.address] = srv
+            delete(pool.AliveServers, p.address)
         }
+        return
+    }
+    if srv, ok := pool.DownServers[p.address]; ok && healthy && !srv.Maintenance {
+        srv = pool.copyServer(p.address)
+        l.startSlow(srv)
+        pool.AliveServers[p.address] = srv
+        delete(pool.DownServers, p.address)
     }
-    // Update the algo driver with current servers that are alive.
-    l.AlgoDriver.Renew(l.AliveServers)
+}
 
-    l.RUnlock()
+// startSlow starts the slow-start ramp-up of a server that just became healthy.
+func (l *LoadBalancer) startSlow(srv *model.BEServer) {
+    srv.SlowStart = model.SlowStart{
+        Since:  time.Now(),
+        Window: l.SlowStart,
+        Mode:   l.SlowStartMode,
+    }
 }
@@ -0,0 +1,153 @@
+package lb
+
+import (
+    "LoadBalancer/internal/registry"
+    "LoadBalancer/pkg/model"
+    "log"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Restore registers the servers saved in the Store, down: none takes traffic before a scan finds it healthy.
+// Servers keep their maintenance and draining state, and their lease with a full TTL to send the next heartbeat.
+// Static backends come from the configuration, only their state is restored, and only while they're still in it.
+// Registrations of pools that no longer exist are dropped.
+func (l *LoadBalancer) Restore() error {
+    if l.Store == nil {
+        return nil
+    }
+    registrations, err := l.Store.Load()
+    if err != nil {
+        return err
+    }
+
+    l.Lock()
+    defer l.Unlock()
+
+    for _, reg := range registrations {
+        if reg.Static {
+            if pool, _ := l.serverPool(reg.Address); pool != nil && l.isStatic(reg.Address) {
+                srv := pool.copyServer(reg.Address)
+                srv.Draining = reg.Draining
+                srv.Maintenance = reg.Maintenance
+            }
+            continue
+        }
+        pool, ok := l.poolNamed(reg.Pool)
+        if !ok {
+            log.Printf("Registration of %s dropped, pool %s unknown.\n", reg.Address, reg.Pool)
+            continue
+        }
+
+        srv := model.NewBEServer(reg.Address, reg.Weight)
+        srv.Priority = reg.Priority
+        srv.Region = reg.Region
+        srv.Zone = reg.Zone
+        srv.Draining = reg.Draining
+        srv.Maintenance = reg.Maintenance
+        l.deregister(reg.Address)
+        pool.DownServers[reg.Address] = srv
+
+        if reg.Lease != "" {
+            ttl := time.Duration(reg.TTL) * time.Second
+            l.leases[reg.Lease] = &lease{
+                ID:      reg.Lease,
+                Address: reg.Address,
+                TTL:     ttl,
+                Expires: time.Now().Add(ttl),
+            }
+        }
+    }
+    log.Printf("%d registrations restored.\n", len(registrations))
+    return nil
+}
+
+// saver holds the last snapshot of the registrations until it's saved, and serializes the writes to the Store.
+type saver struct {
+    sync.Mutex
+    unsaved atomic.Pointer[[]registry.Registration]
+}
+
+// persist takes a snapshot of the registrations for the Store, if any. Callers must hold the lock, and call save once
+// they released it, so that the traffic doesn't wait for the disk.
+func (l *LoadBalancer) persist() {
+    if l.Store == nil {
+        return
+    }
+    registrations := l.registrations()
+    l.saver.unsaved.Store(&registrations)
+}
+
+// save saves the last snapshot of the registrations to the Store, unless another call saved it already. Snapshots are
+// taken in order under the lock and saved one at a time, so an older one never overwrites a newer one. save returns
+// once the snapshot of the caller, or a newer one, is saved.
+func (l *LoadBalancer) save() {
+    l.saver.Lock()
+    defer l.saver.Unlock()
+
+    registrations := l.saver.unsaved.Swap(nil)
+    if registrations == nil || l.Store == nil {
+        return
+    }
+    if err := l.Store.Save(*registrations); err != nil {
+        log.Printf("Saving registrations failed: %v\n", err)
+    }
+}
+
+// isStatic reports whether address is a static backend of the configuration. Callers must hold the lock.
+func (l *LoadBalancer) isStatic(address string) bool {
+    if l.config == nil {
+        return false
+    }
+    for _, pool := range l.config.Pools {
+        for _, backend := range pool.Backends {
+            if backend.Address == address {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// registrations returns the registered servers sorted by address. Callers must hold the lock.
+func (l *LoadBalancer) registrations() []registry.Registration {
+    leases := make(map[string]*lease, len(l.leases))
+    for _, ls := range l.leases {
+        leases[ls.Address] = ls
+    }
+
+    var registrations []registry.Registration
+    for _, pool := range l.pools {
+        for _, servers := range []model.BEServers{pool.AliveServers, pool.DownServers} {
+            for addr, srv := range servers {
+                // Discovered servers come from their provider at the next start.
+                if l.isDiscovered(addr) {
+                    continue
+                }
+                reg := registry.Registration{
+                    Address:     addr,
+                    Pool:        pool.Name,
+                    Weight:      srv.Weight,
+                    Priority:    srv.Priority,
+                    Region:      srv.Region,
+                    Zone:        srv.Zone,
+                    Draining:    srv.Draining,
+                    Maintenance: srv.Maintenance,
+                    Static:      l.isStatic(addr),
+                }
+                if ls, ok := leases[addr]; ok {
+                    reg.Lease = ls.ID
+                    reg.TTL = int(ls.TTL / time.Second)
+                }
+                registrations = append(registrations, reg)
+            }
+        }
+    }
+
+    sort.Slice(registrations, func(i, j int) bool {
+        return registrations[i].Address < registrations[j].Address
+    })
+    return registrations
+}
@@ -0,0 +1,146 @@
+package lb
+
+import (
+    "LoadBalancer/internal/config"
+    "LoadBalancer/internal/registry"
+    "LoadBalancer/pkg/model"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "testing"
+)
+
+func TestLoadBalancer_Restore(t *testing.T) {
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+    defer backend.Close()
+    store := registry.NewFileStore(filepath.Join(t.TempDir(), "registry.json"))
+
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.Store = store
+    id := registerWithLease(t, l, backend.URL, 30)
+    if err = l.SetDraining(backend.URL, true); err != nil {
+        t.Fatal(err)
+    }
+
+    // The registration survives a restart.
+    restarted, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    restarted.Store = store
+    if err = restarted.Restore(); err != nil {
+        t.Fatal(err)
+    }
+    srv, ok := restarted.DownServers[backend.URL]
+    if !ok || !srv.Draining || srv.Weight != 1 {
+        t.Fatalf("error restoring registration: expected %s down and draining, got %#v.\n", backend.URL, srv)
+    }
+    if ls, ok := restarted.leases[id]; !ok || ls.Address != backend.URL {
+        t.Errorf("error restoring lease: expected lease %s of %s, got %#v.\n", id, backend.URL, ls)
+    }
+
+    // It takes traffic again once probed.
+    restarted.scanServers()
+    if _, ok = restarted.AliveServers[backend.URL]; !ok {
+        t.Errorf("error probing restored server: expected %s to be alive.\n", backend.URL)
+    }
+
+    // Deregistering removes it from the store.
+    if err = restarted.RemoveServer(backend.URL); err != nil {
+        t.Fatal(err)
+    }
+    if registrations, _ := store.Load(); len(registrations) != 0 {
+        t.Errorf("error deregistering server: expected no registration saved, got %#v.\n", registrations)
+    }
+}
+
+func TestLoadBalancer_RestoreStatic(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "registry.json")
+    cfg, err := config.Parse([]byte("pools:\n  - backends:\n      - address: http://127.0.0.1:1\nregistry:\n  file: " + path + "\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    l, err := NewFromConfig(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err = l.SetMaintenance("http://127.0.0.1:1", true); err != nil {
+        t.Fatal(err)
+    }
+
+    // A static backend keeps its maintenance across a restart.
+    restarted, err := NewFromConfig(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if srv, ok := restarted.DownServers["http://127.0.0.1:1"]; !ok || !srv.Maintenance {
+        t.Errorf("error restoring static backend: expected it under maintenance, got %#v.\n", srv)
+    }
+
+    // A static backend removed from the configuration isn't restored.
+    cfg.Pools[0].Backends = nil
+    if restarted, err = NewFromConfig(cfg); err != nil {
+        t.Fatal(err)
+    }
+    if len(restarted.DownServers) != 0 {
+        t.Errorf("error restoring removed static backend: expected no server, got %d.\n", len(restarted.DownServers))
+    }
+}
+
+// slowStore is a Store whose saves wait to be released.
+type slowStore struct {
+    saving  chan struct{} // Receives a value when a save starts.
+    release chan struct{}
+    saved   [][]registry.Registration
+}
+
+func (s *slowStore) Load() ([]registry.Registration, error) {
+    return nil, nil
+}
+
+func (s *slowStore) Save(registrations []registry.Registration) error {
+    s.saving <- struct{}{}
+    <-s.release
+    s.saved = append(s.saved, registrations)
+    return nil
+}
+
+func TestLoadBalancer_Save(t *testing.T) {
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    store := &slowStore{saving: make(chan struct{}), release: make(chan struct{})}
+    l.Store = store
+    l.AliveServers = model.BEServers{"Address A": model.NewBEServer("Address A", 1)}
+
+    done := make(chan struct{})
+    go func() {
+        _ = l.SetDraining("Address A", true)
+        close(done)
+    }()
+    <-store.saving
+
+    // The store is slow, the lock is free meanwhile, and the next change is saved after the first one.
+    if !l.TryLock() {
+        t.Fatalf("error saving registrations: expected the lock to be released while saving.\n")
+    }
+    l.Unlock()
+    maintained := make(chan struct{})
+    go func() {
+        _ = l.SetMaintenance("Address A", true)
+        close(maintained)
+    }()
+    close(store.release)
+    <-store.saving
+    <-done
+    <-maintained
+
+    if len(store.saved) != 2 || !store.saved[0][0].Draining || store.saved[0][0].Maintenance || !store.saved[1][0].Maintenance {
+        t.Errorf("error saving registrations: expected the draining server, then under maintenance, got %#v.\n", store.saved)
+    }
+}
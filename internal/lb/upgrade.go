@@ -0,0 +1,79 @@
+package lb
+
+import (
+    "LoadBalancer/internal/lb/response"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// ErrUpgrade is returned when a protocol upgrade can't be proxied.
+var ErrUpgrade = errors.New("error protocol upgrade unsupported")
+
+// isUpgrade tells whether req asks for a protocol upgrade, e.g. WebSocket.
+func isUpgrade(req *http.Request) bool {
+    if req.Header.Get("Upgrade") == "" {
+        return false
+    }
+    for _, value := range req.Header.Values("Connection") {
+        for _, token := range strings.Split(value, ",") {
+            if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// tunnel hands the client connection of w over to the protocol the server switched to in resp: the bytes are copied
+// both ways until either side closes its connection, then the body of resp is closed. Until then the request stays in
+// flight, so a draining server isn't drained while it has upgraded connections.
+// Errors before the connection is taken over are written back to the client, the later ones are only returned.
+func tunnel(w http.ResponseWriter, resp *http.Response) error {
+    backend, ok := resp.Body.(io.ReadWriteCloser)
+    if !ok {
+        _ = resp.Body.Close()
+        response.WriteJsonResponse(w, http.StatusBadGateway, response.NewErrorResponse(ErrUpgrade))
+        return ErrUpgrade
+    }
+    defer backend.Close()
+    hijacker, ok := w.(http.Hijacker)
+    if !ok {
+        response.WriteJsonResponse(w, http.StatusNotImplemented, response.NewErrorResponse(ErrUpgrade))
+        return ErrUpgrade
+    }
+    conn, client, err := hijacker.Hijack()
+    if err != nil {
+        response.WriteJsonResponse(w, http.StatusInternalServerError, response.NewErrorResponse(err))
+        return err
+    }
+    defer conn.Close()
+    // The timeouts of the client connections don't apply to upgraded ones, which last as long as both sides want.
+    _ = conn.SetDeadline(time.Time{})
+
+    _, _ = fmt.Fprintf(client, "HTTP/1.1 %s\r\n", resp.Status)
+    _ = resp.Header.Write(client)
+    _, _ = client.WriteString("\r\n")
+    if err = client.Flush(); err != nil {
+        return err
+    }
+
+    // The first side to close ends the tunnel, closing the other one ends the second copy.
+    copied := make(chan error, 2)
+    go func() {
+        _, err := io.Copy(backend, client)
+        copied <- err
+    }()
+    go func() {
+        _, err := io.Copy(conn, backend)
+        copied <- err
+    }()
+    err = <-copied
+    _ = conn.Close()
+    _ = backend.Close()
+    <-copied
+    return err
+}
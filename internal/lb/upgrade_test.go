@@ -0,0 +1,111 @@
+package lb
+
+import (
+    "LoadBalancer/pkg/model"
+    "bufio"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestIsUpgrade(t *testing.T) {
+    testCases := []struct {
+        name       string
+        connection string
+        upgrade    string
+        expected   bool
+    }{
+        {name: "WebSocket", connection: "Upgrade", upgrade: "websocket", expected: true},
+        {name: "Among other tokens", connection: "keep-alive, upgrade", upgrade: "websocket", expected: true},
+        {name: "No upgrade token", connection: "keep-alive", upgrade: "websocket", expected: false},
+        {name: "No protocol", connection: "Upgrade", expected: false},
+    }
+
+    for _, tc := range testCases {
+        req := httptest.NewRequest(http.MethodGet, "/chat", nil)
+        req.Header.Set("Connection", tc.connection)
+        if tc.upgrade != "" {
+            req.Header.Set("Upgrade", tc.upgrade)
+        }
+        if got := isUpgrade(req); got != tc.expected {
+            t.Errorf("%s: expected %t, got %t.\n", tc.name, tc.expected, got)
+        }
+    }
+}
+
+func TestLoadBalancer_Upgrade(t *testing.T) {
+    // The backend switches to a protocol echoing lines back.
+    backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        if req.Header.Get("Upgrade") != "echo" {
+            w.WriteHeader(http.StatusBadRequest)
+            return
+        }
+        conn, rw, err := w.(http.Hijacker).Hijack()
+        if err != nil {
+            return
+        }
+        defer conn.Close()
+        _, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+        _ = rw.Flush()
+        for {
+            line, err := rw.ReadString('\n')
+            if err != nil {
+                return
+            }
+            _, _ = rw.WriteString(line)
+            _ = rw.Flush()
+        }
+    }))
+    defer backend.Close()
+
+    l, err := New(0, 10, "RR", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.AliveServers = model.BEServers{backend.URL: model.NewBEServer(backend.URL, 1)}
+    l.AlgoDriver().Renew(l.AliveServers)
+    front := httptest.NewServer(http.HandlerFunc(l.Forward))
+    defer front.Close()
+
+    conn, err := net.Dial("tcp", front.Listener.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+    req, _ := http.NewRequest(http.MethodGet, front.URL+"/chat", nil)
+    req.Header.Set("Connection", "Upgrade")
+    req.Header.Set("Upgrade", "echo")
+    if err = req.Write(conn); err != nil {
+        t.Fatal(err)
+    }
+    reader := bufio.NewReader(conn)
+    resp, err := http.ReadResponse(reader, req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != "echo" {
+        t.Fatalf("error upgrading: expected status %d to %s, got %d %v.\n", http.StatusSwitchingProtocols, "echo", resp.StatusCode, resp.Header)
+    }
+
+    // The connection is proxied both ways, and it's in flight until it's closed.
+    if _, err = conn.Write([]byte("ping\n")); err != nil {
+        t.Fatal(err)
+    }
+    if line, err := reader.ReadString('\n'); err != nil || line != "ping\n" {
+        t.Errorf("error proxying upgraded connection: expected %q, got %q, %v.\n", "ping\n", line, err)
+    }
+    if n := l.connections(backend.URL).Load(); n != 1 {
+        t.Errorf("error counting connections: expected %d, got %d.\n", 1, n)
+    }
+
+    _ = conn.Close()
+    deadline := time.Now().Add(time.Second)
+    for l.connections(backend.URL).Load() != 0 && time.Now().Before(deadline) {
+        time.Sleep(10 * time.Millisecond)
+    }
+    if n := l.connections(backend.URL).Load(); n != 0 {
+        t.Errorf("error counting connections: expected %d once closed, got %d.\n", 0, n)
+    }
+}
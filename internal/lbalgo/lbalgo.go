@@ -1,50 +1,92 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
-    "errors"
+    "LoadBalancer/pkg/balancer"
     "net/http"
-    "strings"
 )
 
 const (
     LeastConnection    = "LC"
+    WeightedLeastConn  = "WLC"
     RoundRobin         = "RR"
     StickyRoundRobin   = "SRR"
     WeightedRoundRobin = "WRR"
+    SmoothWeightedRR   = "SWRR"
     SourceIPHashing    = "SIH"
     PowerOfTwoChoices  = "PTC"
+    PeakEWMA           = "PEWMA"
+    Random             = "R"
+    WeightedRandom     = "WR"
 )
 
 var (
-    ErrNoServer    = errors.New("error no available server")
-    ErrUnknownAlgo = errors.New("error unknown algorithm")
+    ErrNoServer    = balancer.ErrNoServer
+    ErrUnknownAlgo = balancer.ErrUnknownAlgo
 )
 
-type LBAlgo interface {
-    ChooseServer(req *http.Request) (string, error)
-    Renew(servers model.BEServers)
+// The interfaces are defined in the public package balancer, so that algorithms can be implemented out of tree.
+type (
+    LBAlgo    = balancer.LBAlgo
+    Selection = balancer.Selection
+    Result    = balancer.Result
+    Sticky    = balancer.Sticky
+    Seeder    = balancer.Seeder
+    Acquirer  = balancer.Acquirer
+    Clients   = balancer.Clients
+)
+
+// Register the built-in algorithms.
+func init() {
+    balancer.Register(LeastConnection, withoutParams(func() LBAlgo { return NewLC(nil) }))
+    balancer.Register(WeightedLeastConn, withoutParams(func() LBAlgo { return NewWLC(nil) }))
+    balancer.Register(RoundRobin, withoutParams(func() LBAlgo { return NewRR(nil) }))
+    balancer.Register(StickyRoundRobin, func(params balancer.Params) (LBAlgo, error) {
+        if err := params.Check("size", "ttl"); err != nil {
+            return nil, err
+        }
+        size, err := params.Int("size", DefaultStickySize)
+        if err != nil {
+            return nil, err
+        }
+        ttl, err := params.Duration("ttl", DefaultStickyTTL)
+        if err != nil {
+            return nil, err
+        }
+        return NewBoundedSRR(nil, size, ttl), nil
+    })
+    balancer.Register(WeightedRoundRobin, withoutParams(func() LBAlgo { return NewWRR(nil) }))
+    balancer.Register(SmoothWeightedRR, withoutParams(func() LBAlgo { return NewSWRR(nil) }))
+    balancer.Register(SourceIPHashing, withoutParams(func() LBAlgo { return NewSIH(nil) }))
+    balancer.Register(PowerOfTwoChoices, withoutParams(func() LBAlgo { return NewPTC(nil) }))
+    balancer.Register(Random, withoutParams(func() LBAlgo { return NewR(nil) }))
+    balancer.Register(WeightedRandom, withoutParams(func() LBAlgo { return NewWR(nil) }))
+    balancer.Register(PeakEWMA, func(params balancer.Params) (LBAlgo, error) {
+        if err := params.Check("decay"); err != nil {
+            return nil, err
+        }
+        decay, err := params.Duration("decay", DefaultDecay)
+        if err != nil {
+            return nil, err
+        }
+        return NewPEWMA(nil, decay), nil
+    })
 }
 
-func ChooseAlgo(algoBrief string) (LBAlgo, error) {
-    switch strings.ToUpper(algoBrief) {
-    case LeastConnection:
-        return NewLC(nil), nil
-    case RoundRobin:
-        return NewRR(nil), nil
-    case StickyRoundRobin:
-        return NewSRR(nil), nil
-    case WeightedRoundRobin:
-        return NewWRR(nil), nil
-    case SourceIPHashing:
-        return NewSIH(nil), nil
-    case PowerOfTwoChoices:
-        return NewPTC(nil), nil
-    default:
-        return nil, ErrUnknownAlgo
+// withoutParams turns the constructor of an algorithm that takes no parameters into a balancer.Factory.
+func withoutParams(newAlgo func() LBAlgo) balancer.Factory {
+    return func(params balancer.Params) (LBAlgo, error) {
+        if err := params.Check(); err != nil {
+            return nil, err
+        }
+        return newAlgo(), nil
     }
 }
 
+// ChooseAlgo creates the algorithm registered under algoBrief with the given parameters.
+func ChooseAlgo(algoBrief string, params balancer.Params) (LBAlgo, error) {
+    return balancer.New(algoBrief, params)
+}
+
 // getClientIP gets the IP of the client. If the client is hided behind proxies or load balancers,
 // we get the IP from retrieving the value from X-Forwarded-For header.
 // This method is only a demo and shouldn't be used in any production code. Header can be changed after a request is sent.
@@ -0,0 +1,70 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "fmt"
+    "net/http"
+    "testing"
+)
+
+func TestLBAlgo_Draining(t *testing.T) {
+    for _, brief := range balancer.Algorithms() {
+        algo, err := ChooseAlgo(brief, nil)
+        if err != nil {
+            t.Fatalf("%s: error creating algorithm: got %#v.\n", brief, err)
+        }
+        algo.Renew(model.BEServers{
+            "Address A": &model.BEServer{Address: "Address A", Weight: 5, Draining: true},
+            "Address B": &model.BEServer{Address: "Address B", Weight: 1},
+        })
+
+        for i := 0; i < 20; i++ {
+            req := &http.Request{RemoteAddr: fmt.Sprintf("10.0.0.%d", i)}
+            chosen, err := algo.ChooseServer(req)
+            if err != nil {
+                t.Fatalf("%s: error choosing server: got %#v.\n", brief, err)
+            }
+            if chosen.Address != "Address B" {
+                t.Fatalf("%s: error choosing server: expected %s, got draining %s.\n", brief, "Address B", chosen.Address)
+            }
+            chosen.Done(Result{})
+        }
+    }
+}
+
+func TestLBAlgo_Acquire(t *testing.T) {
+    algos := make(map[string]LBAlgo)
+    for _, brief := range []string{LeastConnection, WeightedLeastConn, PowerOfTwoChoices, PeakEWMA} {
+        algo, err := ChooseAlgo(brief, nil)
+        if err != nil {
+            t.Fatalf("%s: error creating algorithm: got %#v.\n", brief, err)
+        }
+        algos[brief] = algo
+    }
+    // Wrappers acquire from the algorithm they wrap.
+    algos["ZoneAware"] = NewZoneAware(ZoneConfig{}, func() LBAlgo { return NewLC(nil) }, nil)
+
+    for brief, algo := range algos {
+        algo.Renew(model.BEServers{
+            "Address A": &model.BEServer{Address: "Address A", Weight: 1},
+            "Address B": &model.BEServer{Address: "Address B", Weight: 1},
+        })
+
+        // The requests acquired on A count, so that the next one goes to B.
+        held := []Selection{balancer.Acquire(algo, "Address A"), balancer.Acquire(algo, "Address A")}
+        chosen, err := algo.ChooseServer(new(http.Request))
+        if err != nil || chosen.Address != "Address B" {
+            t.Errorf("%s: error choosing server: expected %s, got %s %v.\n", brief, "Address B", chosen.Address, err)
+        }
+        chosen.Done(Result{})
+        for _, selection := range held {
+            selection.Done(Result{})
+        }
+
+        // A server the algorithm doesn't hold counts nothing.
+        if unknown := balancer.Acquire(algo, "Address C"); unknown.Address != "Address C" {
+            t.Errorf("%s: error acquiring server: expected %s, got %s.\n", brief, "Address C", unknown.Address)
+        }
+    }
+}
@@ -1,39 +1,80 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
     "net/http"
     "sync"
+    "time"
 )
 
 // Using Go's sort interface would be easier, but for practice I'm implementing a minimum priority queue.
 
 type LC struct {
     sync.RWMutex
-    servers []*model.BEServer // Using a slice as a binary heap.
+    servers     []*model.BEServer       // Using a slice as a binary heap.
+    connections map[*model.BEServer]int // Connections in flight, counted by LC itself.
 }
 
 func NewLC(backendServers *model.BEServers) *LC {
-    servers := make([]*model.BEServer, 0)
+    lc := &LC{
+        servers:     make([]*model.BEServer, 0),
+        connections: make(map[*model.BEServer]int),
+    }
     if backendServers != nil {
         for _, srv := range *backendServers {
-            servers = append(servers, srv)
+            lc.push(srv)
         }
     }
-    return &LC{servers: servers}
+    return lc
 }
 
-func (l *LC) ChooseServer(_ *http.Request) (string, error) {
-    // Call buildMinHeap().
-    if len(l.servers) != 0 {
-        l.buildMinHeap()
-        return l.servers[0].Address, nil
+// ChooseServer returns the server with the least connections and counts a new connection on it until the request is done.
+func (l *LC) ChooseServer(_ *http.Request) (Selection, error) {
+    l.Lock()
+    defer l.Unlock()
+
+    if len(l.servers) == 0 {
+        return Selection{}, ErrNoServer
+    }
+
+    l.heapify(time.Now())
+    chosen := l.servers[0]
+    l.connections[chosen]++
+    return balancer.NewSelection(chosen.Address, func(_ Result) {
+        l.release(chosen)
+    }), nil
+}
+
+// Acquire counts a connection on the server at address until the request is done.
+func (l *LC) Acquire(address string) Selection {
+    l.Lock()
+    defer l.Unlock()
+
+    for _, srv := range l.servers {
+        if srv.Address == address {
+            l.connections[srv]++
+            return balancer.NewSelection(address, func(_ Result) {
+                l.release(srv)
+            })
+        }
     }
+    return balancer.NewSelection(address, nil)
+}
+
+// release closes a connection counted on srv.
+func (l *LC) release(srv *model.BEServer) {
+    l.Lock()
+    defer l.Unlock()
 
-    return "", ErrNoServer
+    // The server may have been removed while the request was in flight.
+    if n, ok := l.connections[srv]; ok && n > 0 {
+        l.connections[srv] = n - 1
+    }
 }
 
 func (l *LC) Renew(backendServers model.BEServers) {
+    backendServers = backendServers.Accepting()
     // 1. Check down servers.
     for _, srv := range l.servers {
         if _, ok := backendServers[srv.Address]; !ok {
@@ -70,6 +111,8 @@ func (l *LC) push(server *model.BEServer) {
     l.Lock()
     defer l.Unlock()
     l.servers = append(l.servers, server)
+    // Start from the connections the server reported, if any.
+    l.connections[server] = server.Connections
 }
 
 func (l *LC) remove(serverAddress string) {
@@ -80,6 +123,8 @@ func (l *LC) remove(serverAddress string) {
     for _, srv := range l.servers {
         if serverAddress != srv.Address {
             newServers = append(newServers, srv)
+        } else {
+            delete(l.connections, srv)
         }
     }
 
@@ -94,23 +139,30 @@ func rightChildIdx(idx int) int {
     return idx*2 + 2
 }
 
+// load returns the load of a server at now.
+// A slow-starting server counts as more loaded than its connections, so that it isn't flooded right after it comes back.
+// Without slow-start the order is the same as comparing the connections.
+func (l *LC) load(srv *model.BEServer, now time.Time) float64 {
+    return float64(l.connections[srv]+1) / srv.SlowStart.Factor(now)
+}
+
 // minHeapify starts the minimum heapify process from the given index.
-func (l *LC) minHeapify(idx int) {
+func (l *LC) minHeapify(idx int, now time.Time) {
     lowest := idx
 
     lChildIdx := leftChildIdx(idx)
     rChildIdx := rightChildIdx(idx)
 
-    if lChildIdx < len(l.servers) && l.servers[lChildIdx].Connections < l.servers[lowest].Connections {
+    if lChildIdx < len(l.servers) && l.load(l.servers[lChildIdx], now) < l.load(l.servers[lowest], now) {
         lowest = lChildIdx
     }
-    if rChildIdx < len(l.servers) && l.servers[rChildIdx].Connections < l.servers[lowest].Connections {
+    if rChildIdx < len(l.servers) && l.load(l.servers[rChildIdx], now) < l.load(l.servers[lowest], now) {
         lowest = rChildIdx
     }
 
     if lowest != idx {
         l.servers[lowest], l.servers[idx] = l.servers[idx], l.servers[lowest]
-        l.minHeapify(lowest)
+        l.minHeapify(lowest, now)
     }
 }
 
@@ -119,7 +171,12 @@ func (l *LC) buildMinHeap() {
     l.Lock()
     defer l.Unlock()
 
+    l.heapify(time.Now())
+}
+
+// heapify turns l.server into a minimum heap of the loads at now. Callers must hold the lock.
+func (l *LC) heapify(now time.Time) {
     for i := len(l.servers) / 2; i >= 0; i-- {
-        l.minHeapify(i)
+        l.minHeapify(i, now)
     }
 }
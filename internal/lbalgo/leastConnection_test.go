@@ -1,9 +1,10 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/model"
     "net/http"
     "testing"
+    "time"
 )
 
 func TestLC_ChooseServer(t *testing.T) {
@@ -43,8 +44,8 @@ func TestLC_ChooseServer(t *testing.T) {
             t.Errorf("error choosing server: %v.\n", err)
         }
 
-        if chosen != tc.expectedChosen {
-            t.Errorf("error choosing server: expected %s, got %s.\n", tc.expectedChosen, chosen)
+        if chosen.Address != tc.expectedChosen {
+            t.Errorf("error choosing server: expected %s, got %s.\n", tc.expectedChosen, chosen.Address)
         }
     }
 }
@@ -97,8 +98,8 @@ func TestLC_Renew(t *testing.T) {
             t.Errorf("error choosing server: %v.\n", err)
         }
 
-        if chosen != tc.expectedChosen {
-            t.Errorf("error choosing server: expected %s, got %s.\n", tc.expectedChosen, chosen)
+        if chosen.Address != tc.expectedChosen {
+            t.Errorf("error choosing server: expected %s, got %s.\n", tc.expectedChosen, chosen.Address)
         }
     }
 }
@@ -156,3 +157,47 @@ func isMinHeap(servers []*model.BEServer) bool {
     }
     return true
 }
+
+func TestLC_SlowStart(t *testing.T) {
+    bes := &model.BEServers{
+        "Address A": &model.BEServer{Address: "Address A", Connections: 5},
+        "Address B": &model.BEServer{
+            Address:   "Address B",
+            SlowStart: model.SlowStart{Since: time.Now(), Window: time.Hour}, // Just came back.
+        },
+    }
+
+    lc := NewLC(bes)
+    chosen, err := lc.ChooseServer(new(http.Request))
+    if err != nil {
+        t.Errorf("error choosing server: %v.\n", err)
+    }
+
+    // B counts as (0+1)/0.1 = 10, more than the 5+1 of A.
+    if chosen.Address != "Address A" {
+        t.Errorf("error choosing server: expected %s, got %s.\n", "Address A", chosen.Address)
+    }
+}
+
+func TestLC_Done(t *testing.T) {
+    bes := &model.BEServers{
+        "Address A": &model.BEServer{Address: "Address A", Connections: 1},
+        "Address B": &model.BEServer{Address: "Address B", Connections: 2},
+    }
+    lc := NewLC(bes)
+
+    // A goes up to 2 connections and ties with B until the request is done.
+    first, _ := lc.ChooseServer(new(http.Request))
+    first.Done(Result{})
+    second, _ := lc.ChooseServer(new(http.Request))
+    if first.Address != "Address A" || second.Address != "Address A" {
+        t.Errorf("error choosing server: expected %s twice, got %s and %s.\n", "Address A", first.Address, second.Address)
+    }
+
+    // Requests done after their server was removed are ignored.
+    lc.Renew(model.BEServers{"Address B": (*bes)["Address B"]})
+    second.Done(Result{})
+    if _, ok := lc.connections[(*bes)["Address A"]]; ok {
+        t.Errorf("error done: expected no connections counted on a removed server.\n")
+    }
+}
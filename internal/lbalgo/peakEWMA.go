@@ -0,0 +1,196 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "math"
+    "math/rand"
+    "net/http"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// DefaultDecay is the default time constant of the PEWMA latency average.
+const DefaultDecay = 10 * time.Second
+
+// FailurePenalty is the least latency recorded for a failed request.
+const FailurePenalty = time.Second
+
+// penalty is the cost of a server that has requests in flight but no latency sample yet.
+// It's large enough to make any measured server preferable.
+const penalty = float64(math.MaxInt64 >> 16)
+
+// PEWMA is the struct used for Power of Two Choices with Peak EWMA latency.
+// It compares two distinct servers picked at random by latency × (in-flight requests + 1) and chooses the cheaper one.
+//
+// Unlike PTC, ChooseServer never locks the whole balancer: the server list is an immutable snapshot swapped atomically
+// by Renew, random numbers come from a pool of generators and every server guards only its own state.
+type PEWMA struct {
+    sync.Mutex // Serializes Renew.
+    decay      time.Duration
+    snapshot   atomic.Pointer[pewmaSnapshot]
+    rands      sync.Pool
+    seeds      atomic.Int64
+    seeded     atomic.Pointer[rand.Rand] // Set by Seed, shared by all goroutines so that the choices are reproducible.
+}
+
+type pewmaSnapshot struct {
+    servers []*pewmaServer
+    byAddr  map[string]*pewmaServer
+}
+
+type pewmaServer struct {
+    Addr     string
+    inflight atomic.Int64
+
+    mu    sync.Mutex
+    cost  float64 // Peak EWMA of the latency in nanoseconds.
+    stamp time.Time
+}
+
+// NewPEWMA creates a PEWMA instance. The latency average forgets old samples with the time constant decay.
+func NewPEWMA(backendServers *model.BEServers, decay time.Duration) *PEWMA {
+    if decay <= 0 {
+        decay = DefaultDecay
+    }
+
+    p := &PEWMA{decay: decay}
+    p.seeds.Store(time.Now().UnixNano())
+    p.rands.New = func() any {
+        return rand.New(rand.NewSource(p.seeds.Add(1)))
+    }
+    p.snapshot.Store(&pewmaSnapshot{byAddr: make(map[string]*pewmaServer)})
+
+    if backendServers != nil {
+        p.Renew(*backendServers)
+    }
+    return p
+}
+
+// Seed makes the sequence of chosen servers reproducible.
+// A seeded PEWMA draws from a single generator, which trades the per-goroutine generators for determinism.
+func (p *PEWMA) Seed(seed int64) {
+    p.seeded.Store(newRand(seed))
+}
+
+// ChooseServer picks two distinct servers at random and returns the one with the lower cost.
+// The request counts as in flight on the chosen server until it's done, then its latency is folded into the average.
+func (p *PEWMA) ChooseServer(_ *http.Request) (Selection, error) {
+    servers := p.snapshot.Load().servers
+    n := len(servers)
+    if n == 0 {
+        return Selection{}, ErrNoServer
+    }
+
+    chosen := servers[0]
+    if n > 1 {
+        i, j := p.pick(n)
+        if j >= i {
+            // Skip i so that the two indexes are distinct.
+            j++
+        }
+
+        now := time.Now()
+        chosen = servers[i]
+        if servers[j].score(now, p.decay) < chosen.score(now, p.decay) {
+            chosen = servers[j]
+        }
+    }
+
+    chosen.inflight.Add(1)
+    return balancer.NewSelection(chosen.Addr, func(result Result) {
+        p.done(chosen, result)
+    }), nil
+}
+
+// Acquire counts a request in flight on the server at address, and folds its latency into the average once it's done.
+func (p *PEWMA) Acquire(address string) Selection {
+    srv, ok := p.snapshot.Load().byAddr[address]
+    if !ok {
+        return balancer.NewSelection(address, nil)
+    }
+    srv.inflight.Add(1)
+    return balancer.NewSelection(address, func(result Result) {
+        p.done(srv, result)
+    })
+}
+
+// pick draws the two random indexes used by ChooseServer, i from [0, n) and j from [0, n-1).
+func (p *PEWMA) pick(n int) (int, int) {
+    if r := p.seeded.Load(); r != nil {
+        return r.Intn(n), r.Intn(n - 1)
+    }
+
+    r := p.rands.Get().(*rand.Rand)
+    defer p.rands.Put(r)
+    return r.Intn(n), r.Intn(n - 1)
+}
+
+// done releases the in-flight slot of a request sent to srv and records its latency.
+// A failed request counts as taking at least FailurePenalty, so that a server failing fast isn't mistaken for a fast one.
+func (p *PEWMA) done(srv *pewmaServer, result Result) {
+    srv.inflight.Add(-1)
+
+    latency := result.Latency
+    if result.Failed() {
+        latency = max(latency, FailurePenalty)
+    }
+    srv.observe(time.Now(), float64(latency), p.decay)
+}
+
+// Renew swaps in a new snapshot of the given healthy servers. Servers that stay keep their latency and in-flight state.
+func (p *PEWMA) Renew(currentHealthyServers model.BEServers) {
+    currentHealthyServers = currentHealthyServers.Accepting()
+    p.Lock()
+    defer p.Unlock()
+
+    old := p.snapshot.Load()
+    next := &pewmaSnapshot{
+        servers: make([]*pewmaServer, 0, len(currentHealthyServers)),
+        byAddr:  make(map[string]*pewmaServer, len(currentHealthyServers)),
+    }
+    // Keep the servers in order, so that a seed reproduces the choices.
+    for _, addr := range sortedAddresses(currentHealthyServers) {
+        srv, ok := old.byAddr[addr]
+        if !ok {
+            srv = &pewmaServer{Addr: addr}
+        }
+        next.servers = append(next.servers, srv)
+        next.byAddr[addr] = srv
+    }
+
+    p.snapshot.Store(next)
+}
+
+// observe folds a latency sample into the Peak EWMA. A sample above the average replaces it right away,
+// so the average reacts to latency spikes at once and recovers slowly.
+func (s *pewmaServer) observe(now time.Time, rtt float64, decay time.Duration) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if rtt > s.cost {
+        s.cost = rtt
+    } else {
+        w := math.Exp(-float64(now.Sub(s.stamp)) / float64(decay))
+        s.cost = s.cost*w + rtt*(1-w)
+    }
+    s.stamp = now
+}
+
+// score returns the load of the server: the decayed latency average times the requests in flight plus one.
+func (s *pewmaServer) score(now time.Time, decay time.Duration) float64 {
+    s.mu.Lock()
+    cost := s.cost
+    if cost > 0 {
+        // Let the average of an idle server fade, so that it's tried again.
+        cost *= math.Exp(-float64(now.Sub(s.stamp)) / float64(decay))
+    }
+    s.mu.Unlock()
+
+    inflight := float64(s.inflight.Load())
+    if cost == 0 && inflight != 0 {
+        return penalty + inflight
+    }
+    return cost * (inflight + 1)
+}
@@ -0,0 +1,175 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/model"
+    "errors"
+    "fmt"
+    "net/http"
+    "testing"
+    "time"
+)
+
+func TestPEWMA_ChooseServer(t *testing.T) {
+    emptyReq := new(http.Request)
+    t.Run("No servers", func(t *testing.T) {
+        p := NewPEWMA(new(model.BEServers), DefaultDecay)
+        _, err := p.ChooseServer(emptyReq)
+        if !errors.Is(err, ErrNoServer) {
+            t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrNoServer, err)
+        }
+    })
+
+    t.Run("One server", func(t *testing.T) {
+        p := NewPEWMA(&model.BEServers{"Address A": new(model.BEServer)}, DefaultDecay)
+        addr, err := p.ChooseServer(emptyReq)
+        if err != nil {
+            t.Errorf("error choosing server: got %#v.\n", err)
+        }
+        if addr.Address != "Address A" {
+            t.Errorf("error choosing server: expected %s, got %s.\n", "Address A", addr.Address)
+        }
+    })
+
+    t.Run("Avoid slow server", func(t *testing.T) {
+        bes := &model.BEServers{
+            "Address A": new(model.BEServer),
+            "Address B": new(model.BEServer),
+        }
+        p := NewPEWMA(bes, DefaultDecay)
+
+        // Teach the balancer that B is 100 times slower than A.
+        for _, sample := range []struct {
+            addr    string
+            latency time.Duration
+        }{{"Address A", time.Millisecond}, {"Address B", 100 * time.Millisecond}} {
+            srv := p.snapshot.Load().byAddr[sample.addr]
+            srv.inflight.Add(1)
+            p.done(srv, Result{Latency: sample.latency})
+        }
+
+        for i := 0; i < 10; i++ {
+            addr, _ := p.ChooseServer(emptyReq)
+            if addr.Address != "Address A" {
+                t.Fatalf("error choosing server: expected %s, got %s.\n", "Address A", addr.Address)
+            }
+            // A now has i+1 requests in flight, its cost is still far below B.
+        }
+    })
+
+    t.Run("Distinct choices", func(t *testing.T) {
+        bes := &model.BEServers{
+            "Address A": new(model.BEServer),
+            "Address B": new(model.BEServer),
+        }
+        p := NewPEWMA(bes, DefaultDecay)
+        // With two servers the two picks are always A and B, so the in-flight counts stay balanced.
+        for i := 0; i < 100; i++ {
+            _, _ = p.ChooseServer(emptyReq)
+        }
+
+        a := p.snapshot.Load().byAddr["Address A"].inflight.Load()
+        b := p.snapshot.Load().byAddr["Address B"].inflight.Load()
+        if a != 50 || b != 50 {
+            t.Errorf("error choosing server: expected 50 requests in flight on each server, got %d and %d.\n", a, b)
+        }
+    })
+}
+
+func TestPEWMA_done(t *testing.T) {
+    bes := &model.BEServers{"Address A": new(model.BEServer)}
+    p := NewPEWMA(bes, DefaultDecay)
+    srv := p.snapshot.Load().byAddr["Address A"]
+
+    // A request failing fast counts as slow.
+    selection, _ := p.ChooseServer(new(http.Request))
+    selection.Done(Result{Latency: time.Millisecond, Status: http.StatusBadGateway})
+    if inflight := srv.inflight.Load(); inflight != 0 {
+        t.Errorf("error done: expected %d requests in flight, got %d.\n", 0, inflight)
+    }
+    if srv.cost != float64(FailurePenalty) {
+        t.Errorf("error done: expected cost %v, got %v.\n", float64(FailurePenalty), srv.cost)
+    }
+}
+
+func TestPEWMA_observe(t *testing.T) {
+    srv := new(pewmaServer)
+    now := time.Now()
+
+    srv.observe(now, 100, time.Second)
+    if srv.cost != 100 {
+        t.Errorf("error peak: expected cost %v, got %v.\n", 100.0, srv.cost)
+    }
+
+    // A lower sample one time constant later moves the average by 1 - 1/e.
+    srv.observe(now.Add(time.Second), 0, time.Second)
+    if srv.cost < 36 || srv.cost > 37 {
+        t.Errorf("error decay: expected cost around %v, got %v.\n", 36.8, srv.cost)
+    }
+
+    // A higher sample replaces the average.
+    srv.observe(now.Add(2*time.Second), 500, time.Second)
+    if srv.cost != 500 {
+        t.Errorf("error peak: expected cost %v, got %v.\n", 500.0, srv.cost)
+    }
+}
+
+func TestPEWMA_Renew(t *testing.T) {
+    bes := &model.BEServers{
+        "Address A": new(model.BEServer),
+        "Address B": new(model.BEServer),
+        "Address C": new(model.BEServer),
+    }
+    p := NewPEWMA(bes, DefaultDecay)
+    p.snapshot.Load().byAddr["Address B"].inflight.Store(3)
+
+    p.Renew(model.BEServers{
+        "Address B": new(model.BEServer),
+        "Address D": new(model.BEServer), // Delete server A, C and add server D.
+    })
+
+    addresses := make([]string, 0)
+    for _, srv := range p.snapshot.Load().servers {
+        addresses = append(addresses, srv.Addr)
+    }
+    if !assertSameElement(addresses, []string{"Address B", "Address D"}) {
+        t.Errorf("error renewing server: expected %#v, got %#v.\n", []string{"Address B", "Address D"}, addresses)
+    }
+
+    // State of the servers that stay is kept.
+    if inflight := p.snapshot.Load().byAddr["Address B"].inflight.Load(); inflight != 3 {
+        t.Errorf("error renewing server: expected %d requests in flight, got %d.\n", 3, inflight)
+    }
+}
+
+// benchmarkServers returns n backend servers.
+func benchmarkServers(n int) *model.BEServers {
+    bes := make(model.BEServers)
+    for i := 0; i < n; i++ {
+        addr := fmt.Sprintf("Address %d", i)
+        bes[addr] = &model.BEServer{Address: addr}
+    }
+    return &bes
+}
+
+// Run with -cpu 1,4,16 to compare how the two algorithms scale with cores.
+
+func BenchmarkPEWMA_ChooseServer(b *testing.B) {
+    p := NewPEWMA(benchmarkServers(100), DefaultDecay)
+    b.RunParallel(func(pb *testing.PB) {
+        emptyReq := new(http.Request)
+        for pb.Next() {
+            selection, _ := p.ChooseServer(emptyReq)
+            selection.Done(Result{Latency: time.Millisecond})
+        }
+    })
+}
+
+func BenchmarkPTC_ChooseServer(b *testing.B) {
+    p := NewPTC(benchmarkServers(100))
+    b.RunParallel(func(pb *testing.PB) {
+        emptyReq := new(http.Request)
+        for pb.Next() {
+            _, _ = p.ChooseServer(emptyReq)
+        }
+    })
+}
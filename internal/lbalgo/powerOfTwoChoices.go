@@ -1,9 +1,11 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
     "math/rand"
     "net/http"
+    "sort"
     "sync"
     "time"
 )
@@ -13,7 +15,9 @@ const TWO = 2
 // PTC is the struct used for Power of Two Choices.
 type PTC struct {
     sync.RWMutex
-    servers []*model.BEServer
+    servers     []*model.BEServer
+    connections map[*model.BEServer]int // Connections in flight, counted by PTC itself.
+    rand        *rand.Rand
 }
 
 // NewPTC creates a PTC instance.
@@ -26,24 +30,75 @@ func NewPTC(backendServers *model.BEServers) *PTC {
     }
 
     ptc := &PTC{
-        servers: servers,
+        servers:     servers,
+        connections: make(map[*model.BEServer]int),
+        rand:        newRand(time.Now().UnixNano()),
     }
+    for _, srv := range servers {
+        // Start from the connections the server reported, if any.
+        ptc.connections[srv] = srv.Connections
+    }
+    // Since the order isn't consistent when reading from a map, sort the servers so that a seed reproduces the choices.
+    ptc.sort()
 
     return ptc
 }
 
+// Seed makes the sequence of chosen servers reproducible.
+func (p *PTC) Seed(seed int64) {
+    p.Lock()
+    defer p.Unlock()
+    p.rand = newRand(seed)
+}
+
 // ChooseServer chooses a server based comparison result on the randomly selected two server.
-func (p *PTC) ChooseServer(_ *http.Request) (string, error) {
+// A new connection is counted on the chosen server until the request is done.
+func (p *PTC) ChooseServer(_ *http.Request) (Selection, error) {
+    p.Lock()
+    defer p.Unlock()
+
     selected, err := p.choose(TWO)
     if err != nil {
-        return "", err
+        return Selection{}, err
+    }
+
+    chosen := p.chooseLeastConnection(selected)
+    p.connections[chosen]++
+    return balancer.NewSelection(chosen.Address, func(_ Result) {
+        p.release(chosen)
+    }), nil
+}
+
+// Acquire counts a connection on the server at address until the request is done.
+func (p *PTC) Acquire(address string) Selection {
+    p.Lock()
+    defer p.Unlock()
+
+    for _, srv := range p.servers {
+        if srv.Address == address {
+            p.connections[srv]++
+            return balancer.NewSelection(address, func(_ Result) {
+                p.release(srv)
+            })
+        }
     }
+    return balancer.NewSelection(address, nil)
+}
+
+// release closes a connection counted on srv.
+func (p *PTC) release(srv *model.BEServer) {
+    p.Lock()
+    defer p.Unlock()
 
-    return chooseLeastConnection(selected), nil
+    // The server may have been removed while the request was in flight.
+    if n, ok := p.connections[srv]; ok && n > 0 {
+        p.connections[srv] = n - 1
+    }
 }
 
 // Renew updates the list within PTC with the given healthyServers.
 func (p *PTC) Renew(currentHealthyServers model.BEServers) {
+    currentHealthyServers = currentHealthyServers.Accepting()
     p.Lock()
     defer p.Unlock()
 
@@ -64,7 +119,25 @@ func (p *PTC) Renew(currentHealthyServers model.BEServers) {
         }
     }
 
+    connections := make(map[*model.BEServer]int, len(newServer))
+    for _, srv := range newServer {
+        if n, ok := p.connections[srv]; ok {
+            connections[srv] = n
+        } else {
+            connections[srv] = srv.Connections
+        }
+    }
+
     p.servers = newServer
+    p.connections = connections
+    p.sort()
+}
+
+// sort orders the servers by address.
+func (p *PTC) sort() {
+    sort.Slice(p.servers, func(i, j int) bool {
+        return p.servers[i].Address < p.servers[j].Address
+    })
 }
 
 // exists check if a serverAddress is in the list.
@@ -79,23 +152,21 @@ func (p *PTC) exists(address string) bool {
 }
 
 // chooseLeastConnection selects a server with the least connections.
-func chooseLeastConnection(servers []*model.BEServer) string {
+func (p *PTC) chooseLeastConnection(servers []*model.BEServer) *model.BEServer {
     leastConnectionServer := servers[0]
     for i := 1; i < len(servers); i++ {
-        if servers[i].Connections < leastConnectionServer.Connections {
+        if p.connections[servers[i]] < p.connections[leastConnectionServer] {
             leastConnectionServer = servers[i]
         }
     }
 
-    return leastConnectionServer.Address
+    return leastConnectionServer
 }
 
 // choose selects k servers from PTC randomly.
 // If the length of p.Servers are smaller than k, return all objects that exists.
-// Returns an error if there's no server in p.
+// Returns an error if there's no server in p. Callers must hold the lock.
 func (p *PTC) choose(k int) ([]*model.BEServer, error) {
-    p.Lock()
-    defer p.Unlock()
     if len(p.servers) == 0 {
         return nil, ErrNoServer
     }
@@ -104,11 +175,8 @@ func (p *PTC) choose(k int) ([]*model.BEServer, error) {
         return p.servers, nil
     }
 
-    // Seed the source with now.
-    rand.New(rand.NewSource(time.Now().UnixNano()))
-
     // Shuffle the list.
-    rand.Shuffle(len(p.servers), func(i, j int) {
+    p.rand.Shuffle(len(p.servers), func(i, j int) {
         p.servers[i], p.servers[j] = p.servers[j], p.servers[i]
     })
 
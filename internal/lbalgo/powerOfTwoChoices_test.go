@@ -1,7 +1,7 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/model"
     "errors"
     "net/http"
     "testing"
@@ -30,8 +30,8 @@ func TestPTC_ChooseServer(t *testing.T) {
         if err != nil {
             t.Errorf("error choosing server: got %#v.\n", err)
         }
-        if addr != (*bes)["Address A"].Address {
-            t.Errorf("error choosing server: expected %#v, got %#v.\n", (*bes)["Address A"].Address, addr)
+        if addr.Address != (*bes)["Address A"].Address {
+            t.Errorf("error choosing server: expected %#v, got %#v.\n", (*bes)["Address A"].Address, addr.Address)
         }
     })
 
@@ -159,6 +159,31 @@ func TestPTC_Renew(t *testing.T) {
     }
 }
 
+func TestPTC_Done(t *testing.T) {
+    bes := &model.BEServers{
+        "Address A": &model.BEServer{Address: "Address A", Connections: 1},
+    }
+    ptc := NewPTC(bes)
+    srv := (*bes)["Address A"]
+
+    // Two requests in flight, then both done.
+    first, _ := ptc.ChooseServer(new(http.Request))
+    second, _ := ptc.ChooseServer(new(http.Request))
+    if conns := ptc.connections[srv]; conns != 3 {
+        t.Errorf("error choosing server: expected %d connections, got %d.\n", 3, conns)
+    }
+    first.Done(Result{})
+    second.Done(Result{})
+    if conns := ptc.connections[srv]; conns != 1 {
+        t.Errorf("error done: expected %d connections, got %d.\n", 1, conns)
+    }
+
+    // The connections reported by the server itself aren't touched.
+    if srv.Connections != 1 {
+        t.Errorf("error done: expected server to report %d connections, got %d.\n", 1, srv.Connections)
+    }
+}
+
 // assertSameElement checks whether the two string slice contains the same elements.
 func assertSameElement(setA, setB []string) bool {
     if len(setA) != len(setB) {
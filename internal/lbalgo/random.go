@@ -0,0 +1,98 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "math/rand"
+    "net/http"
+    "sort"
+    "sync"
+    "time"
+)
+
+// R is the struct used for uniform Random load balancing.
+type R struct {
+    sync.RWMutex
+    servers []string
+    rand    *rand.Rand
+}
+
+// NewR creates a R instance seeded with the current time.
+func NewR(backendServers *model.BEServers) *R {
+    r := &R{
+        servers: make([]string, 0),
+        rand:    newRand(time.Now().UnixNano()),
+    }
+
+    if backendServers != nil {
+        r.Renew(*backendServers)
+    }
+    return r
+}
+
+// Seed makes the sequence of chosen servers reproducible.
+func (r *R) Seed(seed int64) {
+    r.Lock()
+    defer r.Unlock()
+    r.rand = newRand(seed)
+}
+
+// ChooseServer returns a server picked uniformly at random.
+func (r *R) ChooseServer(_ *http.Request) (Selection, error) {
+    r.RLock()
+    defer r.RUnlock()
+
+    if len(r.servers) == 0 {
+        return Selection{}, ErrNoServer
+    }
+    return balancer.NewSelection(r.servers[r.rand.Intn(len(r.servers))], nil), nil
+}
+
+// Renew replaces the servers within R with the given healthy servers.
+func (r *R) Renew(currentHealthyServers model.BEServers) {
+    currentHealthyServers = currentHealthyServers.Accepting()
+    r.Lock()
+    defer r.Unlock()
+
+    r.servers = sortedAddresses(currentHealthyServers)
+}
+
+// sortedAddresses returns the addresses of servers in order.
+// Randomized algorithms index into a sorted list, so that the same seed gives the same choices whatever the map order is.
+func sortedAddresses(servers model.BEServers) []string {
+    addresses := make([]string, 0, len(servers))
+    for addr := range servers {
+        addresses = append(addresses, addr)
+    }
+    sort.Strings(addresses)
+    return addresses
+}
+
+// lockedSource is a rand.Source that is safe for concurrent use, like the one behind the top-level functions of math/rand.
+type lockedSource struct {
+    sync.Mutex
+    src rand.Source64
+}
+
+// newRand creates a *rand.Rand seeded with seed that is safe for concurrent use.
+func newRand(seed int64) *rand.Rand {
+    return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+func (s *lockedSource) Int63() int64 {
+    s.Lock()
+    defer s.Unlock()
+    return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+    s.Lock()
+    defer s.Unlock()
+    return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+    s.Lock()
+    defer s.Unlock()
+    s.src.Seed(seed)
+}
@@ -0,0 +1,103 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/model"
+    "errors"
+    "net/http"
+    "testing"
+)
+
+func TestR_ChooseServer(t *testing.T) {
+    emptyReq := new(http.Request)
+    t.Run("No servers", func(t *testing.T) {
+        r := NewR(new(model.BEServers))
+        _, err := r.ChooseServer(emptyReq)
+        if !errors.Is(err, ErrNoServer) {
+            t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrNoServer, err)
+        }
+    })
+
+    t.Run("All servers chosen", func(t *testing.T) {
+        bes := &model.BEServers{
+            "Address A": new(model.BEServer),
+            "Address B": new(model.BEServer),
+            "Address C": new(model.BEServer),
+        }
+        r := NewR(bes)
+        r.Seed(1)
+
+        counts := make(map[string]int)
+        for i := 0; i < 3000; i++ {
+            chosen, err := r.ChooseServer(emptyReq)
+            if err != nil {
+                t.Fatalf("error choosing server: got %#v.\n", err)
+            }
+            counts[chosen.Address]++
+        }
+
+        for addr := range *bes {
+            if counts[addr] < 900 || counts[addr] > 1100 {
+                t.Errorf("error choosing server: expected about 1000 requests on %s, got %d.\n", addr, counts[addr])
+            }
+        }
+    })
+}
+
+func TestR_Renew(t *testing.T) {
+    r := NewR(&model.BEServers{
+        "Address A": new(model.BEServer),
+        "Address B": new(model.BEServer),
+    })
+
+    r.Renew(model.BEServers{
+        "Address C": new(model.BEServer),
+        "Address B": new(model.BEServer), // Delete server A and add server C.
+    })
+
+    expected := []string{"Address B", "Address C"}
+    if !assertEqualSlice(r.servers, expected) {
+        t.Errorf("error renewing server: expected %#v, got %#v.\n", expected, r.servers)
+    }
+}
+
+// Seeded algorithms must repeat the exact same sequence of choices.
+func TestSeeder_Reproducible(t *testing.T) {
+    bes := &model.BEServers{
+        "Address A": &model.BEServer{Address: "Address A", Weight: 3},
+        "Address B": &model.BEServer{Address: "Address B", Weight: 1},
+        "Address C": &model.BEServer{Address: "Address C", Weight: 2},
+        "Address D": &model.BEServer{Address: "Address D", Weight: 4},
+    }
+
+    testCases := []struct {
+        name string
+        algo func() LBAlgo
+    }{
+        {name: "R", algo: func() LBAlgo { return NewR(bes) }},
+        {name: "WR", algo: func() LBAlgo { return NewWR(bes) }},
+        {name: "PTC", algo: func() LBAlgo { return NewPTC(bes) }},
+        {name: "SIH", algo: func() LBAlgo { return NewSIH(bes) }},
+        {name: "PEWMA", algo: func() LBAlgo { return NewPEWMA(bes, DefaultDecay) }},
+    }
+
+    sequence := func(algo LBAlgo) []string {
+        algo.(Seeder).Seed(42)
+        chosen := make([]string, 0)
+        for i := 0; i < 50; i++ {
+            addr, err := algo.ChooseServer(&http.Request{RemoteAddr: "10.0.0.1"})
+            if err != nil {
+                t.Fatalf("error choosing server: got %#v.\n", err)
+            }
+            chosen = append(chosen, addr.Address)
+        }
+        return chosen
+    }
+
+    for _, tc := range testCases {
+        first := sequence(tc.algo())
+        second := sequence(tc.algo())
+        if !assertEqualSlice(first, second) {
+            t.Errorf("error %s seeding: expected %#v, got %#v.\n", tc.name, first, second)
+        }
+    }
+}
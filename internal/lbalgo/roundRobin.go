@@ -1,7 +1,8 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
     "net/http"
     "sort"
     "sync"
@@ -37,6 +38,7 @@ func NewRR(backendServers *model.BEServers) *RR {
 
 // Renew updates the queue within RR.
 func (r *RR) Renew(backendServers model.BEServers) {
+    backendServers = backendServers.Accepting()
     // 1. Check down servers.
     for _, addr := range r.servers {
         if _, ok := backendServers[addr]; !ok {
@@ -54,13 +56,13 @@ func (r *RR) Renew(backendServers model.BEServers) {
 }
 
 // ChooseServer rotates the queue within RR and returns the chosenServer.
-func (r *RR) ChooseServer(_ *http.Request) (string, error) {
+func (r *RR) ChooseServer(_ *http.Request) (Selection, error) {
     chosenServer := r.rotate()
     if chosenServer == "" {
-        return "", ErrNoServer
+        return Selection{}, ErrNoServer
     }
 
-    return chosenServer, nil
+    return balancer.NewSelection(chosenServer, nil), nil
 }
 
 // rotate rotates the queue within RR.
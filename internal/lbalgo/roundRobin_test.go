@@ -1,7 +1,7 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/model"
     "errors"
     "net/http"
     "testing"
@@ -37,8 +37,8 @@ func TestRR_ChooseServer(t *testing.T) {
             t.Errorf("error choosing server: got %#v.\n", err)
         }
 
-        if res != expectedChosen {
-            t.Errorf("error choosing server: expected %s, got %s.\n", expectedChosen, res)
+        if res.Address != expectedChosen {
+            t.Errorf("error choosing server: expected %s, got %s.\n", expectedChosen, res.Address)
         }
 
         if !assertEqualSlice(expectedRR, rr.servers) {
@@ -1,10 +1,12 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
     "hash/fnv"
     "math/rand"
     "net/http"
+    "sort"
     "sync"
     "time"
 )
@@ -20,7 +22,7 @@ type SIH struct {
 func NewSIH(backendServers *model.BEServers) *SIH {
     sih := &SIH{
         bucket: make(map[int]map[string]struct{}),
-        rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+        rand:   newRand(time.Now().UnixNano()),
     }
 
     for i := 0; i < 10; i++ {
@@ -37,8 +39,15 @@ func NewSIH(backendServers *model.BEServers) *SIH {
     return sih
 }
 
+// Seed makes the choice among the servers of a bucket reproducible.
+func (s *SIH) Seed(seed int64) {
+    s.Lock()
+    defer s.Unlock()
+    s.rand = newRand(seed)
+}
+
 // ChooseServer chooses a server based on the clientIP.
-func (s *SIH) ChooseServer(req *http.Request) (string, error) {
+func (s *SIH) ChooseServer(req *http.Request) (Selection, error) {
     clientIP := getClientIP(req)
     hashNum := ihash(clientIP)
     bucketNum := hashNum % len(s.bucket)
@@ -56,7 +65,7 @@ func (s *SIH) ChooseServer(req *http.Request) (string, error) {
         }
 
         if currBucketNum == bucketNum {
-            return "", ErrNoServer
+            return Selection{}, ErrNoServer
         }
         servers = s.bucket[currBucketNum]
     }
@@ -64,17 +73,20 @@ func (s *SIH) ChooseServer(req *http.Request) (string, error) {
     for address := range servers {
         addresses = append(addresses, address)
     }
+    // Since the order isn't consistent when reading from a map, sort the addresses so that a seed reproduces the choices.
+    sort.Strings(addresses)
 
     n := len(addresses)
     // TODO: Should have another logic picking the servers from the bucket. Use rand for now.
     index := s.rand.Intn(n)
     randomAddr := addresses[index]
 
-    return randomAddr, nil
+    return balancer.NewSelection(randomAddr, nil), nil
 }
 
 // Renew updates the bucket within SIH with the given healthyServers.
 func (s *SIH) Renew(currentHealthyServers model.BEServers) {
+    currentHealthyServers = currentHealthyServers.Accepting()
     s.Lock()
     defer s.Unlock()
 
@@ -1,7 +1,7 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/model"
     "net/http"
     "testing"
 )
@@ -31,7 +31,7 @@ func TestSIH_ChooseServer(t *testing.T) {
         }
 
         // Set the first chosen server to test case.
-        tc.expectedChosen = chosen
+        tc.expectedChosen = chosen.Address
 
         // Choose again. The two chosen address should be in the same bucket.
         chosen, err = sih.ChooseServer(tc.clientReq)
@@ -40,7 +40,7 @@ func TestSIH_ChooseServer(t *testing.T) {
         }
 
         expectedBucketNum, _ := sih.exists(tc.expectedChosen)
-        bucketNum, _ := sih.exists(chosen)
+        bucketNum, _ := sih.exists(chosen.Address)
         if expectedBucketNum != bucketNum {
             t.Errorf("error choosing server: expected bucket num %d, got bucket num %d.\n", expectedBucketNum, bucketNum)
         }
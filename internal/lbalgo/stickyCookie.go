@@ -0,0 +1,232 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// Cookie sticky modes.
+const (
+    CookieInsert = "insert" // The balancer sets a cookie of its own naming the server.
+    CookieApp    = "app"    // The balancer follows a session cookie set by the application.
+)
+
+// Defaults of CookieConfig.
+const (
+    DefaultInsertCookie = "LBSERVER"
+    DefaultAppCookie    = "JSESSIONID"
+    DefaultSessionTTL   = 30 * time.Minute
+)
+
+// tokenSize is the number of bytes of the HMAC kept in an insert cookie.
+const tokenSize = 16
+
+// CookieConfig describes how clients are pinned to servers with cookies.
+type CookieConfig struct {
+    Mode string // CookieInsert or CookieApp.
+    Name string // Name of the cookie, DefaultInsertCookie or DefaultAppCookie when empty.
+    // Secret signs the insert cookie. A random one is generated when empty, so cookies don't survive a restart.
+    Secret []byte
+    // TTL is how long an application session is remembered without any request, DefaultSessionTTL when 0.
+    TTL time.Duration
+    // MaxSessions is the number of application sessions remembered, DefaultStickySize when 0.
+    MaxSessions int
+}
+
+// CookieSticky wraps any LBAlgo and pins clients to servers with a cookie instead of their IP, which works behind NAT.
+//
+// In insert mode, the first response carries a cookie holding a token signed with the secret: it names the server
+// without revealing its address, and can't be forged to reach another server.
+// In app mode, the balancer learns the server of a session when the server sets the session cookie.
+//
+// A request whose server is healthy goes to it without asking the wrapped algorithm; the others are load balanced
+// as usual and re-pinned transparently.
+type CookieSticky struct {
+    sync.RWMutex
+    config   CookieConfig
+    algo     LBAlgo
+    tokens   map[string]string // Token of the insert cookie: server.
+    servers  map[string]string // Server: token of the insert cookie.
+    sessions *StickyTable      // Application session: server.
+}
+
+// NewCookieSticky creates a CookieSticky instance around algo.
+func NewCookieSticky(config CookieConfig, algo LBAlgo) *CookieSticky {
+    if config.Name == "" {
+        config.Name = DefaultInsertCookie
+        if config.Mode == CookieApp {
+            config.Name = DefaultAppCookie
+        }
+    }
+    if len(config.Secret) == 0 {
+        config.Secret = make([]byte, sha256.Size)
+        _, _ = rand.Read(config.Secret)
+    }
+    if config.TTL <= 0 {
+        config.TTL = DefaultSessionTTL
+    }
+
+    return &CookieSticky{
+        config:   config,
+        algo:     algo,
+        tokens:   make(map[string]string),
+        servers:  make(map[string]string),
+        sessions: NewStickyTable(config.MaxSessions, config.TTL),
+    }
+}
+
+// token returns the value of the insert cookie naming address.
+func (c *CookieSticky) token(address string) string {
+    mac := hmac.New(sha256.New, c.config.Secret)
+    mac.Write([]byte(address))
+    return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:tokenSize])
+}
+
+// ChooseServer returns the server named by the cookie of req while it's healthy, otherwise lets the wrapped algorithm
+// choose one. A pinned request is acquired from the wrapped algorithm, so that it counts in the load it tracks.
+func (c *CookieSticky) ChooseServer(req *http.Request) (Selection, error) {
+    if addr, ok := c.pinned(req); ok {
+        return balancer.Acquire(c.algo, addr), nil
+    }
+    return c.algo.ChooseServer(req)
+}
+
+// pinned returns the healthy server the cookie of req is bound to.
+func (c *CookieSticky) pinned(req *http.Request) (string, bool) {
+    cookie, err := req.Cookie(c.config.Name)
+    if err != nil {
+        return "", false
+    }
+
+    c.RLock()
+    defer c.RUnlock()
+    if c.config.Mode != CookieApp {
+        addr, ok := c.tokens[cookie.Value]
+        return addr, ok
+    }
+
+    addr, ok := c.sessions.Get(cookie.Value)
+    if !ok {
+        return "", false
+    }
+    if _, ok = c.servers[addr]; !ok {
+        return "", false
+    }
+    return addr, true
+}
+
+// Stick binds the client of req to address once resp came back from it.
+// In insert mode it sets the cookie on w unless the client already holds the right one, so it must be called before
+// the body is written. In app mode it remembers the session the server set or kept using.
+func (c *CookieSticky) Stick(w http.ResponseWriter, req *http.Request, resp *http.Response, address string) {
+    if c.config.Mode != CookieApp {
+        c.RLock()
+        token, ok := c.servers[address]
+        c.RUnlock()
+        if !ok {
+            return
+        }
+        if cookie, err := req.Cookie(c.config.Name); err == nil && cookie.Value == token {
+            return
+        }
+        http.SetCookie(w, &http.Cookie{
+            Name:     c.config.Name,
+            Value:    token,
+            Path:     "/",
+            HttpOnly: true,
+            SameSite: http.SameSiteLaxMode,
+        })
+        return
+    }
+
+    var session string
+    for _, cookie := range resp.Cookies() {
+        if cookie.Name == c.config.Name {
+            session = cookie.Value
+        }
+    }
+    if session == "" {
+        cookie, err := req.Cookie(c.config.Name)
+        if err != nil {
+            return
+        }
+        session = cookie.Value
+    }
+
+    c.sessions.Put(session, address)
+}
+
+// Renew renews the wrapped algorithm and the tokens of the healthy servers, and forgets the sessions idle for longer
+// than the TTL.
+func (c *CookieSticky) Renew(currentHealthyServers model.BEServers) {
+    c.algo.Renew(currentHealthyServers)
+
+    tokens := make(map[string]string, len(currentHealthyServers))
+    servers := make(map[string]string, len(currentHealthyServers))
+    for addr := range currentHealthyServers {
+        token := c.token(addr)
+        tokens[token] = addr
+        servers[addr] = token
+    }
+
+    c.Lock()
+    c.tokens = tokens
+    c.servers = servers
+    c.Unlock()
+
+    c.sessions.Expire()
+}
+
+// Acquire acquires the server at address from the wrapped algorithm.
+func (c *CookieSticky) Acquire(address string) Selection {
+    return balancer.Acquire(c.algo, address)
+}
+
+// Adopt takes over the application sessions of prev, so that they survive a swap of the algorithm. The sessions c
+// already learned are kept.
+func (c *CookieSticky) Adopt(prev *CookieSticky) {
+    known := c.sessions.Bindings()
+    for session, addr := range prev.sessions.Bindings() {
+        if _, ok := known[session]; !ok {
+            c.sessions.Put(session, addr)
+        }
+    }
+}
+
+// Seed seeds the wrapped algorithm when it makes random choices.
+func (c *CookieSticky) Seed(seed int64) {
+    if seeder, ok := c.algo.(Seeder); ok {
+        seeder.Seed(seed)
+    }
+}
+
+// StickyStats returns the metrics of the application sessions and of the sticky table of the wrapped algorithm.
+func (c *CookieSticky) StickyStats() StickyStats {
+    stats := c.sessions.Stats()
+    if reporter, ok := c.algo.(StickyReporter); ok {
+        stats = stats.Add(reporter.StickyStats())
+    }
+    return stats
+}
+
+// Bindings returns the client bindings of the wrapped algorithm.
+func (c *CookieSticky) Bindings() Clients {
+    if sticky, ok := c.algo.(Sticky); ok {
+        return sticky.Bindings()
+    }
+    return Clients{}
+}
+
+// Bind hands the bindings over to the wrapped algorithm.
+func (c *CookieSticky) Bind(clients Clients) {
+    if sticky, ok := c.algo.(Sticky); ok {
+        sticky.Bind(clients)
+    }
+}
@@ -0,0 +1,159 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/model"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestCookieSticky_Insert(t *testing.T) {
+    bes := model.BEServers{
+        "Address A": new(model.BEServer),
+        "Address B": new(model.BEServer),
+        "Address C": new(model.BEServer),
+    }
+    c := NewCookieSticky(CookieConfig{Mode: CookieInsert, Secret: []byte("secret")}, NewRR(nil))
+    c.Renew(bes)
+
+    // The first response pins the client with a cookie that doesn't reveal the server.
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    chosen, err := c.ChooseServer(req)
+    if err != nil {
+        t.Fatalf("error choosing server: got %#v.\n", err)
+    }
+    w := httptest.NewRecorder()
+    c.Stick(w, req, new(http.Response), chosen.Address)
+    cookies := w.Result().Cookies()
+    if len(cookies) != 1 || cookies[0].Name != DefaultInsertCookie {
+        t.Fatalf("error sticking: expected cookie %s, got %#v.\n", DefaultInsertCookie, cookies)
+    }
+    if strings.Contains(cookies[0].Value, "Address") {
+        t.Errorf("error sticking: cookie %s reveals the server.\n", cookies[0].Value)
+    }
+
+    // Later requests go to the same server, and the cookie isn't set again.
+    for i := 0; i < 5; i++ {
+        req = httptest.NewRequest(http.MethodGet, "/", nil)
+        req.AddCookie(cookies[0])
+        again, _ := c.ChooseServer(req)
+        if again.Address != chosen.Address {
+            t.Fatalf("error choosing server: expected %s, got %s.\n", chosen.Address, again.Address)
+        }
+        w = httptest.NewRecorder()
+        c.Stick(w, req, new(http.Response), again.Address)
+        if len(w.Result().Cookies()) != 0 {
+            t.Errorf("error sticking: expected no new cookie, got %#v.\n", w.Result().Cookies())
+        }
+    }
+
+    // A forged cookie is load balanced as usual.
+    req = httptest.NewRequest(http.MethodGet, "/", nil)
+    req.AddCookie(&http.Cookie{Name: DefaultInsertCookie, Value: "Address A"})
+    if _, ok := c.pinned(req); ok {
+        t.Errorf("error choosing server: forged cookie honored.\n")
+    }
+
+    // The server goes down, the client is re-pinned.
+    delete(bes, chosen.Address)
+    c.Renew(bes)
+    req = httptest.NewRequest(http.MethodGet, "/", nil)
+    req.AddCookie(cookies[0])
+    repinned, _ := c.ChooseServer(req)
+    if repinned.Address == chosen.Address {
+        t.Fatalf("error choosing server: expected a server other than %s.\n", chosen.Address)
+    }
+    w = httptest.NewRecorder()
+    c.Stick(w, req, new(http.Response), repinned.Address)
+    if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Value != c.token(repinned.Address) {
+        t.Errorf("error sticking: expected a cookie naming %s, got %#v.\n", repinned.Address, cookies)
+    }
+}
+
+func TestCookieSticky_Acquire(t *testing.T) {
+    bes := model.BEServers{
+        "Address A": &model.BEServer{Address: "Address A"},
+        "Address B": &model.BEServer{Address: "Address B"},
+    }
+    lc := NewLC(nil)
+    c := NewCookieSticky(CookieConfig{Mode: CookieInsert, Secret: []byte("secret")}, lc)
+    c.Renew(bes)
+
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    req.AddCookie(&http.Cookie{Name: DefaultInsertCookie, Value: c.token("Address A")})
+    pinned, _ := c.ChooseServer(req)
+    if pinned.Address != "Address A" {
+        t.Fatalf("error choosing server: expected %s, got %s.\n", "Address A", pinned.Address)
+    }
+
+    // The pinned request counts as a connection of the wrapped algorithm until it's done.
+    lc.RLock()
+    connections := lc.connections[bes["Address A"]]
+    lc.RUnlock()
+    if connections != 1 {
+        t.Errorf("error acquiring server: expected %d connection, got %d.\n", 1, connections)
+    }
+    pinned.Done(Result{})
+    lc.RLock()
+    connections = lc.connections[bes["Address A"]]
+    lc.RUnlock()
+    if connections != 0 {
+        t.Errorf("error releasing server: expected %d connections, got %d.\n", 0, connections)
+    }
+}
+
+func TestCookieSticky_App(t *testing.T) {
+    bes := model.BEServers{
+        "Address A": new(model.BEServer),
+        "Address B": new(model.BEServer),
+    }
+    c := NewCookieSticky(CookieConfig{Mode: CookieApp}, NewRR(nil))
+    c.Renew(bes)
+
+    // The server sets the session cookie, the balancer learns it.
+    req := httptest.NewRequest(http.MethodGet, "/login", nil)
+    chosen, _ := c.ChooseServer(req)
+    resp := &http.Response{Header: http.Header{"Set-Cookie": {"JSESSIONID=abc123; Path=/"}}}
+    w := httptest.NewRecorder()
+    c.Stick(w, req, resp, chosen.Address)
+    if len(w.Result().Cookies()) != 0 {
+        t.Errorf("error sticking: expected no cookie of the balancer in app mode, got %#v.\n", w.Result().Cookies())
+    }
+
+    for i := 0; i < 4; i++ {
+        req = httptest.NewRequest(http.MethodGet, "/", nil)
+        req.AddCookie(&http.Cookie{Name: DefaultAppCookie, Value: "abc123"})
+        again, _ := c.ChooseServer(req)
+        if again.Address != chosen.Address {
+            t.Fatalf("error choosing server: expected %s, got %s.\n", chosen.Address, again.Address)
+        }
+    }
+
+    // Sessions of servers that went down follow the server they are re-pinned to.
+    delete(bes, chosen.Address)
+    c.Renew(bes)
+    req = httptest.NewRequest(http.MethodGet, "/", nil)
+    req.AddCookie(&http.Cookie{Name: DefaultAppCookie, Value: "abc123"})
+    repinned, _ := c.ChooseServer(req)
+    c.Stick(httptest.NewRecorder(), req, new(http.Response), repinned.Address)
+    if addr, ok := c.pinned(req); !ok || addr != repinned.Address {
+        t.Errorf("error sticking: expected session on %s, got %s.\n", repinned.Address, addr)
+    }
+}
+
+func TestCookieSticky_Adopt(t *testing.T) {
+    bes := model.BEServers{"Address A": new(model.BEServer)}
+    from := NewCookieSticky(CookieConfig{Mode: CookieApp}, NewRR(nil))
+    from.Renew(bes)
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    req.AddCookie(&http.Cookie{Name: DefaultAppCookie, Value: "abc123"})
+    from.Stick(httptest.NewRecorder(), req, new(http.Response), "Address A")
+
+    to := NewCookieSticky(CookieConfig{Mode: CookieApp}, NewLC(nil))
+    to.Renew(bes)
+    to.Adopt(from)
+    if addr, ok := to.pinned(req); !ok || addr != "Address A" {
+        t.Errorf("error adopting sessions: expected %s, got %s.\n", "Address A", addr)
+    }
+}
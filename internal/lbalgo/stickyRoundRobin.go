@@ -1,69 +1,120 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
     "net/http"
     "sync"
+    "time"
 )
 
-type Clients map[string]string // client-ip: server-ip
-
 // SRR instance.
+// Clients are bound to servers in a bounded StickyTable, so memory doesn't grow with every client ever seen.
 type SRR struct {
-    AllClients Clients
+    AllClients *StickyTable
     sync.Mutex
-    rr *RR
+    rr      *RR
+    servers map[string]bool // Servers of the last renewal, the draining ones included.
 }
 
+// NewSRR creates a SRR instance with a sticky table of DefaultStickySize clients idle for at most DefaultStickyTTL.
 func NewSRR(backendServers *model.BEServers) *SRR {
+    return NewBoundedSRR(backendServers, DefaultStickySize, DefaultStickyTTL)
+}
+
+// NewBoundedSRR creates a SRR instance that remembers at most maxSize clients, each for ttl after its last request.
+func NewBoundedSRR(backendServers *model.BEServers, maxSize int, ttl time.Duration) *SRR {
+    servers := make(map[string]bool)
+    if backendServers != nil {
+        for addr := range *backendServers {
+            servers[addr] = true
+        }
+    }
     return &SRR{
-        AllClients: make(Clients),
+        AllClients: NewStickyTable(maxSize, ttl),
         rr:         NewRR(backendServers),
+        servers:    servers,
     }
 }
 
 // ChooseServer chooses a backend server for a incoming client.
 // It ensures that each client is consistently routed to the same backend server as long as its sticky criteria (IP address) remains the same, providing session affinity or sticky sessions.
-func (s *SRR) ChooseServer(req *http.Request) (string, error) {
+func (s *SRR) ChooseServer(req *http.Request) (Selection, error) {
     clientIP := getClientIP(req)
     s.Lock()
     defer s.Unlock()
-    beAddr, ok := s.AllClients[clientIP]
+    beAddr, ok := s.AllClients.Get(clientIP)
     if !ok {
-        assignedAddr, err := s.rr.ChooseServer(req)
+        assigned, err := s.rr.ChooseServer(req)
         if err != nil {
             // Error occurs when there's no server in pool.
-            return "", err
+            return Selection{}, err
         }
 
         // Store assigned addr.
-        s.AllClients[clientIP] = assignedAddr
-        return assignedAddr, nil
+        s.AllClients.Put(clientIP, assigned.Address)
+        return assigned, nil
     }
 
-    return beAddr, nil
+    // Pinned requests are acquired from the queue, as CookieSticky does with the algorithm it wraps.
+    return balancer.Acquire(s.rr, beAddr), nil
 }
 
 // Renew updates the round-robin queue and the server bound to the clients.
+// Only the clients of the servers that are no longer healthy are visited. Draining servers leave the queue, but keep
+// their clients.
 func (s *SRR) Renew(healthyServers model.BEServers) {
     // Update round-robin queue.
     s.rr.Renew(healthyServers)
 
-    // Go through all clients and replace the unhealthy servers.
     s.Lock()
     defer s.Unlock()
 
-    for client, BEServer := range s.AllClients {
-        if _, ok := healthyServers[BEServer]; !ok {
-            // Server bound with client not healthy.
-            // Replace with new.
+    s.servers = make(map[string]bool, len(healthyServers))
+    for addr := range healthyServers {
+        s.servers[addr] = true
+    }
+    s.AllClients.Expire()
+    for _, server := range s.AllClients.Servers() {
+        if _, ok := healthyServers[server]; ok {
+            continue
+        }
+
+        // Server bound with clients not healthy.
+        // Replace with new.
+        for _, client := range s.AllClients.Clients(server) {
             emptyReq := new(http.Request)
             emptyReq.RemoteAddr = client
             newChosenSrv, err := s.rr.ChooseServer(emptyReq)
             if err != nil {
-                break // no servers left to assign => empty queue.
+                // No servers left to assign => empty queue, the client is assigned again on its next request.
+                s.AllClients.Delete(client)
+                continue
             }
-            s.AllClients[client] = newChosenSrv
+            s.AllClients.Put(client, newChosenSrv.Address)
         }
     }
 }
+
+// Bindings returns a copy of the client bindings of SRR.
+func (s *SRR) Bindings() Clients {
+    return s.AllClients.Bindings()
+}
+
+// Bind pins the given clients to their servers when the servers are healthy. Draining servers keep their clients,
+// even though they left the round-robin queue.
+func (s *SRR) Bind(clients Clients) {
+    s.Lock()
+    defer s.Unlock()
+
+    for client, server := range clients {
+        if s.servers[server] {
+            s.AllClients.Put(client, server)
+        }
+    }
+}
+
+// StickyStats returns the metrics of the sticky table.
+func (s *SRR) StickyStats() StickyStats {
+    return s.AllClients.Stats()
+}
@@ -1,7 +1,7 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/model"
     "net/http"
     "testing"
 )
@@ -32,7 +32,7 @@ func TestSRR_ChooseServer(t *testing.T) {
         }
 
         // Set the first chosen server to test case.
-        tc.expectedChosen = chosen
+        tc.expectedChosen = chosen.Address
 
         // Choose again and compare.
         chosen, err = srr.ChooseServer(tc.clientReq)
@@ -40,8 +40,8 @@ func TestSRR_ChooseServer(t *testing.T) {
             t.Errorf("error choosing server: got %#v.\n", err)
         }
 
-        if chosen != tc.expectedChosen {
-            t.Errorf("error choosing server: expected %s, got %s.\n", tc.expectedChosen, chosen)
+        if chosen.Address != tc.expectedChosen {
+            t.Errorf("error choosing server: expected %s, got %s.\n", tc.expectedChosen, chosen.Address)
         }
     }
 }
@@ -62,7 +62,7 @@ func TestSRR_Renew(t *testing.T) {
         "10.0.0.3": "Address C",
     }
 
-    srr.AllClients = allClients
+    srr.Bind(allClients)
 
     newBes := model.BEServers{
         "Address B": new(model.BEServer),
@@ -75,16 +75,94 @@ func TestSRR_Renew(t *testing.T) {
     for client, originalBackendServer := range allClients {
         if _, ok := newBes[originalBackendServer]; ok {
             // Healthy server should remain.
-            updatedServer := srr.AllClients[client]
+            updatedServer, _ := srr.AllClients.Get(client)
             if updatedServer != originalBackendServer {
                 t.Errorf("error renewing server: expected %s, got %s.\n", originalBackendServer, updatedServer)
             }
         } else {
             // Unhealthy server should be updated with the next element in queue.
-            updatedServer := srr.AllClients[client]
+            updatedServer, _ := srr.AllClients.Get(client)
             if updatedServer == originalBackendServer {
                 t.Errorf("error renewing server: should be except but %s.\n", originalBackendServer)
             }
         }
     }
 }
+
+func TestSRR_Bind(t *testing.T) {
+    bes := &model.BEServers{
+        "Address A": new(model.BEServer),
+        "Address B": new(model.BEServer),
+    }
+
+    from := NewSRR(bes)
+    from.AllClients.Put("10.0.0.1", "Address A")
+    from.AllClients.Put("10.0.0.2", "Address B")
+    from.AllClients.Put("10.0.0.3", "Address C") // Server unknown to the new SRR.
+
+    to := NewSRR(bes)
+    to.Bind(from.Bindings())
+
+    expected := Clients{"10.0.0.1": "Address A", "10.0.0.2": "Address B"}
+    if to.AllClients.Len() != len(expected) {
+        t.Fatalf("error binding clients: expected %#v, got %#v.\n", expected, to.Bindings())
+    }
+    for client, server := range expected {
+        chosen, _ := to.ChooseServer(&http.Request{RemoteAddr: client})
+        if chosen.Address != server {
+            t.Errorf("error binding clients: expected %s for %s, got %s.\n", server, client, chosen.Address)
+        }
+    }
+}
+
+func TestSRR_RenewNoServer(t *testing.T) {
+    srr := NewSRR(&model.BEServers{"Address A": new(model.BEServer)})
+    _, _ = srr.ChooseServer(&http.Request{RemoteAddr: "10.0.0.1"})
+
+    // The only server is gone, the client is forgotten instead of staying bound to it.
+    srr.Renew(model.BEServers{})
+    if _, ok := srr.AllClients.Get("10.0.0.1"); ok {
+        t.Errorf("error renewing server: expected client %s to be forgotten.\n", "10.0.0.1")
+    }
+}
+
+func TestSRR_Draining(t *testing.T) {
+    bes := model.BEServers{
+        "Address A": new(model.BEServer),
+        "Address B": new(model.BEServer),
+    }
+    srr := NewSRR(&bes)
+    srr.Bind(Clients{"10.0.0.1": "Address A"})
+
+    bes["Address A"].Draining = true
+    srr.Renew(bes)
+
+    // The client of A stays, new clients go to B only.
+    if chosen, _ := srr.ChooseServer(&http.Request{RemoteAddr: "10.0.0.1"}); chosen.Address != "Address A" {
+        t.Errorf("error choosing server: expected %s, got %s.\n", "Address A", chosen.Address)
+    }
+    for _, client := range []string{"10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+        if chosen, _ := srr.ChooseServer(&http.Request{RemoteAddr: client}); chosen.Address != "Address B" {
+            t.Errorf("error choosing server: expected %s, got %s.\n", "Address B", chosen.Address)
+        }
+    }
+}
+
+func TestSRR_BindDraining(t *testing.T) {
+    bes := model.BEServers{
+        "Address A": &model.BEServer{Draining: true},
+        "Address B": new(model.BEServer),
+    }
+
+    // A swap during a drain hands the clients of the draining server over too.
+    to := NewSRR(nil)
+    to.Renew(bes)
+    to.Bind(Clients{"10.0.0.1": "Address A", "10.0.0.2": "Address C"})
+
+    if chosen, _ := to.ChooseServer(&http.Request{RemoteAddr: "10.0.0.1"}); chosen.Address != "Address A" {
+        t.Errorf("error binding client of draining server: expected %s, got %s.\n", "Address A", chosen.Address)
+    }
+    if _, ok := to.AllClients.Get("10.0.0.2"); ok {
+        t.Errorf("error binding client of unknown server: expected client %s to be dropped.\n", "10.0.0.2")
+    }
+}
@@ -0,0 +1,211 @@
+package lbalgo
+
+import (
+    "container/list"
+    "sync"
+    "time"
+)
+
+// Defaults of StickyTable.
+const (
+    DefaultStickySize = 100000
+    DefaultStickyTTL  = time.Hour
+)
+
+// StickyStats are the metrics of a StickyTable.
+type StickyStats struct {
+    Size        int    `json:"size"`
+    Hits        uint64 `json:"hits"`
+    Misses      uint64 `json:"misses"`
+    Evictions   uint64 `json:"evictions"`   // Clients evicted because the table was full.
+    Expirations uint64 `json:"expirations"` // Clients forgotten because they were idle for longer than the TTL.
+}
+
+// Add sums the metrics of two tables.
+func (s StickyStats) Add(other StickyStats) StickyStats {
+    return StickyStats{
+        Size:        s.Size + other.Size,
+        Hits:        s.Hits + other.Hits,
+        Misses:      s.Misses + other.Misses,
+        Evictions:   s.Evictions + other.Evictions,
+        Expirations: s.Expirations + other.Expirations,
+    }
+}
+
+// StickyReporter is implemented by algorithms that keep a StickyTable.
+type StickyReporter interface {
+    StickyStats() StickyStats
+}
+
+// StickyTable binds clients to servers. It holds at most maxSize clients and evicts the least recently used one when
+// it's full, and it forgets the clients idle for longer than the TTL.
+// The clients of every server are indexed, so that the clients of a failed server are found without a full scan.
+type StickyTable struct {
+    sync.Mutex
+    maxSize  int
+    ttl      time.Duration
+    entries  map[string]*list.Element       // Client: element of lru.
+    lru      *list.List                     // Entries from the most to the least recently used.
+    byServer map[string]map[string]struct{} // Server: its clients.
+    stats    StickyStats
+    now      func() time.Time
+}
+
+type stickyEntry struct {
+    Client string
+    Server string
+    Seen   time.Time
+}
+
+// NewStickyTable creates a StickyTable instance. maxSize and ttl fall back to their defaults when not positive.
+func NewStickyTable(maxSize int, ttl time.Duration) *StickyTable {
+    if maxSize <= 0 {
+        maxSize = DefaultStickySize
+    }
+    if ttl <= 0 {
+        ttl = DefaultStickyTTL
+    }
+
+    return &StickyTable{
+        maxSize:  maxSize,
+        ttl:      ttl,
+        entries:  make(map[string]*list.Element),
+        lru:      list.New(),
+        byServer: make(map[string]map[string]struct{}),
+        now:      time.Now,
+    }
+}
+
+// Get returns the server client is bound to and marks it as recently used.
+func (t *StickyTable) Get(client string) (string, bool) {
+    t.Lock()
+    defer t.Unlock()
+
+    elem, ok := t.entries[client]
+    if !ok {
+        t.stats.Misses++
+        return "", false
+    }
+    entry := elem.Value.(*stickyEntry)
+    now := t.now()
+    if now.Sub(entry.Seen) > t.ttl {
+        t.remove(elem)
+        t.stats.Expirations++
+        t.stats.Misses++
+        return "", false
+    }
+
+    entry.Seen = now
+    t.lru.MoveToFront(elem)
+    t.stats.Hits++
+    return entry.Server, true
+}
+
+// Put binds client to server, evicting the least recently used client when the table is full.
+func (t *StickyTable) Put(client, server string) {
+    t.Lock()
+    defer t.Unlock()
+
+    if elem, ok := t.entries[client]; ok {
+        t.remove(elem)
+    }
+    for t.lru.Len() >= t.maxSize {
+        t.remove(t.lru.Back())
+        t.stats.Evictions++
+    }
+
+    t.entries[client] = t.lru.PushFront(&stickyEntry{Client: client, Server: server, Seen: t.now()})
+    if t.byServer[server] == nil {
+        t.byServer[server] = make(map[string]struct{})
+    }
+    t.byServer[server][client] = struct{}{}
+}
+
+// Delete forgets client.
+func (t *StickyTable) Delete(client string) {
+    t.Lock()
+    defer t.Unlock()
+
+    if elem, ok := t.entries[client]; ok {
+        t.remove(elem)
+    }
+}
+
+// Servers returns the servers that have clients.
+func (t *StickyTable) Servers() []string {
+    t.Lock()
+    defer t.Unlock()
+
+    servers := make([]string, 0, len(t.byServer))
+    for server := range t.byServer {
+        servers = append(servers, server)
+    }
+    return servers
+}
+
+// Clients returns the clients bound to server.
+func (t *StickyTable) Clients(server string) []string {
+    t.Lock()
+    defer t.Unlock()
+
+    clients := make([]string, 0, len(t.byServer[server]))
+    for client := range t.byServer[server] {
+        clients = append(clients, client)
+    }
+    return clients
+}
+
+// Expire forgets the clients idle for longer than the TTL. The least recently used clients are at the back of the
+// list, so only the expired ones are visited.
+func (t *StickyTable) Expire() {
+    t.Lock()
+    defer t.Unlock()
+
+    now := t.now()
+    for elem := t.lru.Back(); elem != nil; elem = t.lru.Back() {
+        if now.Sub(elem.Value.(*stickyEntry).Seen) <= t.ttl {
+            return
+        }
+        t.remove(elem)
+        t.stats.Expirations++
+    }
+}
+
+// Bindings returns a copy of the bindings.
+func (t *StickyTable) Bindings() Clients {
+    t.Lock()
+    defer t.Unlock()
+
+    clients := make(Clients, len(t.entries))
+    for client, elem := range t.entries {
+        clients[client] = elem.Value.(*stickyEntry).Server
+    }
+    return clients
+}
+
+// Len returns the number of clients.
+func (t *StickyTable) Len() int {
+    t.Lock()
+    defer t.Unlock()
+    return t.lru.Len()
+}
+
+// Stats returns the metrics of the table.
+func (t *StickyTable) Stats() StickyStats {
+    t.Lock()
+    defer t.Unlock()
+
+    stats := t.stats
+    stats.Size = t.lru.Len()
+    return stats
+}
+
+// remove deletes elem from the table. Callers must hold the lock.
+func (t *StickyTable) remove(elem *list.Element) {
+    entry := t.lru.Remove(elem).(*stickyEntry)
+    delete(t.entries, entry.Client)
+    delete(t.byServer[entry.Server], entry.Client)
+    if len(t.byServer[entry.Server]) == 0 {
+        delete(t.byServer, entry.Server)
+    }
+}
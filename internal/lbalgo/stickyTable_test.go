@@ -0,0 +1,77 @@
+package lbalgo
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestStickyTable_LRU(t *testing.T) {
+    table := NewStickyTable(2, time.Hour)
+    table.Put("10.0.0.1", "Address A")
+    table.Put("10.0.0.2", "Address B")
+
+    // 10.0.0.1 is used, so 10.0.0.2 is the least recently used one when the table is full.
+    if server, ok := table.Get("10.0.0.1"); !ok || server != "Address A" {
+        t.Errorf("error getting client: expected %s, got %s.\n", "Address A", server)
+    }
+    table.Put("10.0.0.3", "Address A")
+
+    if _, ok := table.Get("10.0.0.2"); ok {
+        t.Errorf("error evicting client: expected %s to be evicted.\n", "10.0.0.2")
+    }
+    if _, ok := table.Get("10.0.0.1"); !ok {
+        t.Errorf("error evicting client: expected %s to be kept.\n", "10.0.0.1")
+    }
+
+    expected := StickyStats{Size: 2, Hits: 2, Misses: 1, Evictions: 1}
+    if stats := table.Stats(); stats != expected {
+        t.Errorf("error stats: expected %#v, got %#v.\n", expected, stats)
+    }
+    if servers := table.Servers(); len(servers) != 1 || servers[0] != "Address A" {
+        t.Errorf("error indexing servers: expected %#v, got %#v.\n", []string{"Address A"}, servers)
+    }
+}
+
+func TestStickyTable_TTL(t *testing.T) {
+    now := time.Now()
+    table := NewStickyTable(10, time.Minute)
+    table.now = func() time.Time { return now }
+
+    table.Put("10.0.0.1", "Address A")
+    table.Put("10.0.0.2", "Address B")
+
+    // 10.0.0.2 is refreshed half way, 10.0.0.1 stays idle.
+    now = now.Add(30 * time.Second)
+    table.Get("10.0.0.2")
+    now = now.Add(45 * time.Second)
+
+    if _, ok := table.Get("10.0.0.1"); ok {
+        t.Errorf("error expiring client: expected %s to be expired.\n", "10.0.0.1")
+    }
+    if _, ok := table.Get("10.0.0.2"); !ok {
+        t.Errorf("error expiring client: expected %s to be kept.\n", "10.0.0.2")
+    }
+
+    now = now.Add(2 * time.Minute)
+    table.Expire()
+    if stats := table.Stats(); stats.Size != 0 || stats.Expirations != 2 {
+        t.Errorf("error expiring clients: expected none left and %d expirations, got %#v.\n", 2, stats)
+    }
+}
+
+func TestStickyTable_Clients(t *testing.T) {
+    table := NewStickyTable(0, 0)
+    for i := 0; i < 10; i++ {
+        table.Put(fmt.Sprintf("10.0.0.%d", i), fmt.Sprintf("Address %d", i%2))
+    }
+    // Moving a client to another server updates the index.
+    table.Put("10.0.0.0", "Address 1")
+
+    if clients := table.Clients("Address 0"); len(clients) != 4 {
+        t.Errorf("error indexing clients: expected %d clients, got %#v.\n", 4, clients)
+    }
+    if clients := table.Clients("Address 1"); len(clients) != 6 {
+        t.Errorf("error indexing clients: expected %d clients, got %#v.\n", 6, clients)
+    }
+}
@@ -0,0 +1,190 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "container/heap"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// WLC is the struct used for Weighted Least Connection.
+// It picks the server with the lowest connections/weight ratio, servers with the same ratio take turns.
+// Servers are kept in an indexed binary heap, so a change of a connection count costs O(log n).
+type WLC struct {
+    sync.Mutex
+    servers wlcHeap
+    items   map[string]*wlcItem
+    ramping map[string]*wlcItem // Items whose weight still grows because they are slow-starting.
+    picks   uint64              // Number of picks so far, used to break ties in round-robin order.
+}
+
+type wlcItem struct {
+    Addr        string
+    Weight      int
+    SlowStart   model.SlowStart
+    Connections int
+    LastPick    uint64
+    effWeight   float64 // Weight used for the ratio, kept up to date by WLC.
+    index       int     // Position in the heap, maintained by wlcHeap.
+}
+
+// refresh recomputes the effective weight of the item at now. A server without weight counts as weight 1.
+// It reports whether the item is still slow-starting.
+func (i *wlcItem) refresh(now time.Time) bool {
+    i.effWeight = float64(max(i.Weight, 1)) * i.SlowStart.Factor(now)
+    return now.Before(i.SlowStart.Ends())
+}
+
+// wlcHeap implements heap.Interface.
+type wlcHeap []*wlcItem
+
+func (h wlcHeap) Len() int { return len(h) }
+func (h wlcHeap) Less(i, j int) bool {
+    // Compare Connections/Weight without dividing: a.C/a.W < b.C/b.W <=> a.C*b.W < b.C*a.W.
+    left := float64(h[i].Connections) * h[j].effWeight
+    right := float64(h[j].Connections) * h[i].effWeight
+    if left != right {
+        return left < right
+    }
+    // Same ratio, the server picked least recently goes first.
+    return h[i].LastPick < h[j].LastPick
+}
+func (h wlcHeap) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
+}
+func (h *wlcHeap) Push(x any) {
+    item := x.(*wlcItem)
+    item.index = len(*h)
+    *h = append(*h, item)
+}
+func (h *wlcHeap) Pop() any {
+    old := *h
+    n := len(old)
+    item := old[n-1]
+    old[n-1] = nil
+    item.index = -1
+    *h = old[:n-1]
+    return item
+}
+
+// NewWLC creates a WLC instance.
+func NewWLC(backendServers *model.BEServers) *WLC {
+    wlc := &WLC{
+        servers: make(wlcHeap, 0),
+        items:   make(map[string]*wlcItem),
+        ramping: make(map[string]*wlcItem),
+    }
+
+    if backendServers != nil {
+        wlc.Renew(*backendServers)
+    }
+    return wlc
+}
+
+// ChooseServer returns the server with the lowest connections/weight ratio and counts a new connection on it until
+// the request is done.
+func (w *WLC) ChooseServer(_ *http.Request) (Selection, error) {
+    w.Lock()
+    defer w.Unlock()
+
+    if len(w.servers) == 0 {
+        return Selection{}, ErrNoServer
+    }
+
+    // The weights of slow-starting servers grow over time, move them to their new place first.
+    now := time.Now()
+    for addr, item := range w.ramping {
+        if !item.refresh(now) {
+            delete(w.ramping, addr)
+        }
+        heap.Fix(&w.servers, item.index)
+    }
+
+    chosen := w.servers[0]
+    chosen.Connections++
+    w.picks++
+    chosen.LastPick = w.picks
+    heap.Fix(&w.servers, chosen.index)
+
+    return balancer.NewSelection(chosen.Addr, func(_ Result) {
+        w.release(chosen)
+    }), nil
+}
+
+// Acquire counts a connection on the server at address until the request is done.
+func (w *WLC) Acquire(address string) Selection {
+    w.Lock()
+    defer w.Unlock()
+
+    item, ok := w.items[address]
+    if !ok || item.index < 0 {
+        return balancer.NewSelection(address, nil)
+    }
+    item.Connections++
+    heap.Fix(&w.servers, item.index)
+    return balancer.NewSelection(address, func(_ Result) {
+        w.release(item)
+    })
+}
+
+// release closes the connection counted on item when it was chosen.
+func (w *WLC) release(item *wlcItem) {
+    w.Lock()
+    defer w.Unlock()
+
+    if item.index < 0 || item.Connections == 0 {
+        // Server removed meanwhile, or nothing to release.
+        return
+    }
+    item.Connections--
+    heap.Fix(&w.servers, item.index)
+}
+
+// Renew updates the heap within WLC with the given healthy servers.
+func (w *WLC) Renew(currentHealthyServers model.BEServers) {
+    currentHealthyServers = currentHealthyServers.Accepting()
+    w.Lock()
+    defer w.Unlock()
+
+    now := time.Now()
+    // 1. Remove down servers.
+    for addr, item := range w.items {
+        if _, ok := currentHealthyServers[addr]; !ok {
+            heap.Remove(&w.servers, item.index)
+            delete(w.items, addr)
+            delete(w.ramping, addr)
+        }
+    }
+
+    // 2. Add up servers and update the weights of the existing ones.
+    for addr, srv := range currentHealthyServers {
+        item, ok := w.items[addr]
+        if !ok {
+            item = &wlcItem{
+                Addr:        addr,
+                Weight:      srv.Weight,
+                SlowStart:   srv.SlowStart,
+                Connections: srv.Connections,
+            }
+            if item.refresh(now) {
+                w.ramping[addr] = item
+            }
+            w.items[addr] = item
+            heap.Push(&w.servers, item)
+            continue
+        }
+
+        if item.Weight != srv.Weight || item.SlowStart != srv.SlowStart {
+            item.Weight = srv.Weight
+            item.SlowStart = srv.SlowStart
+            if item.refresh(now) {
+                w.ramping[addr] = item
+            }
+            heap.Fix(&w.servers, item.index)
+        }
+    }
+}
@@ -0,0 +1,200 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/model"
+    "net/http"
+    "testing"
+    "time"
+)
+
+func TestWLC_ChooseServer(t *testing.T) {
+    emptyReq := new(http.Request)
+
+    t.Run("No servers", func(t *testing.T) {
+        wlc := NewWLC(new(model.BEServers))
+        if _, err := wlc.ChooseServer(emptyReq); err != ErrNoServer {
+            t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrNoServer, err)
+        }
+    })
+
+    t.Run("Lowest ratio", func(t *testing.T) {
+        bes := &model.BEServers{
+            "Address A": &model.BEServer{Address: "Address A", Weight: 16, Connections: 32}, // 2
+            "Address B": &model.BEServer{Address: "Address B", Weight: 2, Connections: 2},   // 1
+            "Address C": &model.BEServer{Address: "Address C", Weight: 1, Connections: 3},   // 3
+        }
+
+        wlc := NewWLC(bes)
+        chosen, err := wlc.ChooseServer(emptyReq)
+        if err != nil {
+            t.Errorf("error choosing server: got %#v.\n", err)
+        }
+        if chosen.Address != "Address B" {
+            t.Errorf("error choosing server: expected %s, got %s.\n", "Address B", chosen.Address)
+        }
+    })
+
+    t.Run("Connections follow weights", func(t *testing.T) {
+        bes := &model.BEServers{
+            "Address A": &model.BEServer{Address: "Address A", Weight: 16},
+            "Address B": &model.BEServer{Address: "Address B", Weight: 2},
+        }
+
+        wlc := NewWLC(bes)
+        counts := make(map[string]int)
+        for i := 0; i < 18; i++ {
+            chosen, err := wlc.ChooseServer(emptyReq)
+            if err != nil {
+                t.Fatalf("error choosing server: got %#v.\n", err)
+            }
+            counts[chosen.Address]++
+        }
+
+        if counts["Address A"] != 16 || counts["Address B"] != 2 {
+            t.Errorf("error choosing server: expected 16 and 2 connections, got %#v.\n", counts)
+        }
+    })
+
+    t.Run("Ties take turns", func(t *testing.T) {
+        bes := &model.BEServers{
+            "Address A": &model.BEServer{Address: "Address A", Weight: 1},
+            "Address B": &model.BEServer{Address: "Address B", Weight: 1},
+            "Address C": &model.BEServer{Address: "Address C", Weight: 1},
+        }
+
+        wlc := NewWLC(bes)
+        seen := make(map[string]struct{})
+        for i := 0; i < 3; i++ {
+            chosen, _ := wlc.ChooseServer(emptyReq)
+            // Release right away, so all servers keep the same ratio.
+            chosen.Done(Result{})
+            seen[chosen.Address] = struct{}{}
+        }
+
+        if len(seen) != 3 {
+            t.Errorf("error choosing server: expected all 3 servers to take turns, got %#v.\n", seen)
+        }
+    })
+}
+
+func TestWLC_Done(t *testing.T) {
+    bes := &model.BEServers{
+        "Address A": &model.BEServer{Address: "Address A", Weight: 1, Connections: 5},
+        "Address B": &model.BEServer{Address: "Address B", Weight: 1, Connections: 4},
+    }
+
+    wlc := NewWLC(bes)
+    // B goes up to 5 connections, then back to 4 when the request is done.
+    chosen, _ := wlc.ChooseServer(new(http.Request))
+    chosen.Done(Result{})
+
+    chosen, _ = wlc.ChooseServer(new(http.Request))
+    if chosen.Address != "Address B" {
+        t.Errorf("error choosing server: expected %s, got %s.\n", "Address B", chosen.Address)
+    }
+
+    // Requests done after their server was removed are ignored.
+    wlc.Renew(model.BEServers{"Address A": (*bes)["Address A"]})
+    chosen.Done(Result{})
+    if !isIndexedHeap(wlc.servers) {
+        t.Errorf("error heap invariant broken after Done")
+    }
+}
+
+func TestWLC_Renew(t *testing.T) {
+    bes := model.BEServers{
+        "Address A": &model.BEServer{Address: "Address A", Weight: 1, Connections: 1},
+        "Address B": &model.BEServer{Address: "Address B", Weight: 1, Connections: 2},
+        "Address C": &model.BEServer{Address: "Address C", Weight: 1, Connections: 3},
+        "Address D": &model.BEServer{Address: "Address D", Weight: 1, Connections: 4},
+    }
+
+    testCases := []struct {
+        newBes         model.BEServers
+        expectedChosen string
+    }{
+        {
+            newBes: model.BEServers{
+                "Address B": &model.BEServer{Address: "Address B", Weight: 1},
+                "Address C": &model.BEServer{Address: "Address C", Weight: 1},
+                "Address D": &model.BEServer{Address: "Address D", Weight: 1}, // Delete server A.
+            },
+            expectedChosen: "Address B",
+        },
+        {
+            newBes: model.BEServers{
+                "Address B": &model.BEServer{Address: "Address B", Weight: 1},
+                "Address D": &model.BEServer{Address: "Address D", Weight: 4}, // Raise the weight of D.
+            },
+            expectedChosen: "Address D",
+        },
+        {
+            newBes: model.BEServers{
+                "Address C": &model.BEServer{Address: "Address C", Weight: 1},
+                "Address E": &model.BEServer{Address: "Address E", Weight: 1}, // Add server E.
+            },
+            expectedChosen: "Address E",
+        },
+    }
+
+    for _, tc := range testCases {
+        wlc := NewWLC(&bes)
+        wlc.Renew(tc.newBes)
+
+        if len(wlc.servers) != len(tc.newBes) || !isIndexedHeap(wlc.servers) {
+            t.Errorf("error renewing server: invalid heap %#v.\n", wlc.servers)
+        }
+
+        chosen, err := wlc.ChooseServer(new(http.Request))
+        if err != nil {
+            t.Errorf("error choosing server: %v.\n", err)
+        }
+        if chosen.Address != tc.expectedChosen {
+            t.Errorf("error choosing server: expected %s, got %s.\n", tc.expectedChosen, chosen.Address)
+        }
+    }
+}
+
+// isIndexedHeap checks the heap property and that every item knows its own position.
+func isIndexedHeap(h wlcHeap) bool {
+    for n, item := range h {
+        if item.index != n {
+            return false
+        }
+        lChildIdx := leftChildIdx(n)
+        rChildIdx := rightChildIdx(n)
+        if lChildIdx < len(h) && h.Less(lChildIdx, n) {
+            return false
+        }
+        if rChildIdx < len(h) && h.Less(rChildIdx, n) {
+            return false
+        }
+    }
+    return true
+}
+
+func TestWLC_SlowStart(t *testing.T) {
+    bes := &model.BEServers{
+        "Address A": &model.BEServer{Address: "Address A", Weight: 1},
+        "Address B": &model.BEServer{
+            Address:   "Address B",
+            Weight:    1,
+            SlowStart: model.SlowStart{Since: time.Now(), Window: time.Hour}, // Just came back.
+        },
+    }
+
+    wlc := NewWLC(bes)
+    counts := make(map[string]int)
+    for i := 0; i < 11; i++ {
+        chosen, _ := wlc.ChooseServer(new(http.Request))
+        counts[chosen.Address]++
+    }
+
+    // B has a tenth of the weight of A, so it holds a tenth of the connections of A.
+    if counts["Address A"] != 10 || counts["Address B"] != 1 {
+        t.Errorf("error slow-start: expected 10 and 1 connections, got %#v.\n", counts)
+    }
+    if _, ok := wlc.ramping["Address B"]; !ok {
+        t.Errorf("error slow-start: expected Address B to be ramping.\n")
+    }
+}
@@ -0,0 +1,148 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "math/rand"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// rampRefresh is how often WR rebuilds its alias table while servers are slow-starting.
+const rampRefresh = 100 * time.Millisecond
+
+// WR is the struct used for Weighted Random load balancing.
+// Servers are picked at random with a probability proportional to their weight, in O(1) using an alias table.
+type WR struct {
+    sync.Mutex
+    servers    []string
+    weights    []int
+    slowStarts []model.SlowStart
+    prob       []float64 // prob[i] is the probability to keep column i instead of taking alias[i].
+    alias      []int
+    rand       *rand.Rand
+    builtAt    time.Time
+    rampEnds   time.Time // The alias table is rebuilt now and then until the last slow-start is over.
+}
+
+// NewWR creates a WR instance seeded with the current time.
+func NewWR(backendServers *model.BEServers) *WR {
+    w := &WR{
+        servers: make([]string, 0),
+        rand:    newRand(time.Now().UnixNano()),
+    }
+
+    if backendServers != nil {
+        w.Renew(*backendServers)
+    }
+    return w
+}
+
+// Seed makes the sequence of chosen servers reproducible.
+func (w *WR) Seed(seed int64) {
+    w.Lock()
+    defer w.Unlock()
+    w.rand = newRand(seed)
+}
+
+// ChooseServer throws a fair die to pick a column of the alias table, then a biased coin to pick the column's server or its alias.
+func (w *WR) ChooseServer(_ *http.Request) (Selection, error) {
+    w.Lock()
+    defer w.Unlock()
+
+    if len(w.servers) == 0 {
+        return Selection{}, ErrNoServer
+    }
+
+    if now := time.Now(); now.Before(w.rampEnds) && now.Sub(w.builtAt) >= rampRefresh {
+        w.build(now)
+    }
+
+    i := w.rand.Intn(len(w.servers))
+    if w.rand.Float64() < w.prob[i] {
+        return balancer.NewSelection(w.servers[i], nil), nil
+    }
+    return balancer.NewSelection(w.servers[w.alias[i]], nil), nil
+}
+
+// Renew rebuilds the alias table from the weights of the given healthy servers.
+func (w *WR) Renew(currentHealthyServers model.BEServers) {
+    currentHealthyServers = currentHealthyServers.Accepting()
+    servers := sortedAddresses(currentHealthyServers)
+    weights := make([]int, len(servers))
+    slowStarts := make([]model.SlowStart, len(servers))
+    rampEnds := time.Time{}
+    for n, addr := range servers {
+        weights[n] = currentHealthyServers[addr].Weight
+        slowStarts[n] = currentHealthyServers[addr].SlowStart
+        if ends := slowStarts[n].Ends(); ends.After(rampEnds) {
+            rampEnds = ends
+        }
+    }
+
+    w.Lock()
+    defer w.Unlock()
+    w.servers, w.weights, w.slowStarts, w.rampEnds = servers, weights, slowStarts, rampEnds
+    w.build(time.Now())
+}
+
+// build rebuilds the alias table from the effective weights at now. A weight below 1 counts as 1.
+func (w *WR) build(now time.Time) {
+    effective := make([]float64, len(w.weights))
+    for n, weight := range w.weights {
+        effective[n] = float64(max(weight, 1)) * w.slowStarts[n].Factor(now)
+    }
+    w.prob, w.alias = buildAliasTable(effective)
+    w.builtAt = now
+}
+
+// buildAliasTable builds the alias table of the given positive weights with Vose's method.
+func buildAliasTable(weights []float64) ([]float64, []int) {
+    n := len(weights)
+    prob := make([]float64, n)
+    alias := make([]int, n)
+    if n == 0 {
+        return prob, alias
+    }
+
+    total := 0.0
+    for _, weight := range weights {
+        total += weight
+    }
+
+    // Scale the weights so that their average is 1, then split the columns into under-full and over-full ones.
+    scaled := make([]float64, n)
+    small := make([]int, 0, n)
+    large := make([]int, 0, n)
+    for i, weight := range weights {
+        scaled[i] = weight * float64(n) / total
+        if scaled[i] < 1 {
+            small = append(small, i)
+        } else {
+            large = append(large, i)
+        }
+    }
+
+    // Fill every under-full column with the excess of an over-full one.
+    for len(small) > 0 && len(large) > 0 {
+        s, l := small[len(small)-1], large[len(large)-1]
+        small = small[:len(small)-1]
+
+        prob[s] = scaled[s]
+        alias[s] = l
+        scaled[l] = scaled[l] + scaled[s] - 1
+        if scaled[l] < 1 {
+            large = large[:len(large)-1]
+            small = append(small, l)
+        }
+    }
+
+    // The columns left are full, up to rounding errors.
+    for _, i := range append(small, large...) {
+        prob[i] = 1
+        alias[i] = i
+    }
+
+    return prob, alias
+}
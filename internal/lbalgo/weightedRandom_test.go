@@ -0,0 +1,81 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/model"
+    "errors"
+    "math"
+    "net/http"
+    "testing"
+)
+
+func TestWR_ChooseServer(t *testing.T) {
+    emptyReq := new(http.Request)
+    t.Run("No servers", func(t *testing.T) {
+        w := NewWR(new(model.BEServers))
+        _, err := w.ChooseServer(emptyReq)
+        if !errors.Is(err, ErrNoServer) {
+            t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrNoServer, err)
+        }
+    })
+
+    t.Run("Follow weights", func(t *testing.T) {
+        bes := &model.BEServers{
+            "Address A": &model.BEServer{Weight: 5},
+            "Address B": &model.BEServer{Weight: 2},
+            "Address C": &model.BEServer{Weight: 1},
+            "Address D": &model.BEServer{Weight: 0}, // Counts as 1.
+        }
+        w := NewWR(bes)
+        w.Seed(7)
+
+        const requests = 90000
+        counts := make(map[string]int)
+        for i := 0; i < requests; i++ {
+            chosen, err := w.ChooseServer(emptyReq)
+            if err != nil {
+                t.Fatalf("error choosing server: got %#v.\n", err)
+            }
+            counts[chosen.Address]++
+        }
+
+        expected := map[string]float64{"Address A": 5.0 / 9, "Address B": 2.0 / 9, "Address C": 1.0 / 9, "Address D": 1.0 / 9}
+        for addr, share := range expected {
+            got := float64(counts[addr]) / requests
+            if math.Abs(got-share) > 0.01 {
+                t.Errorf("error choosing server: expected share %.3f for %s, got %.3f.\n", share, addr, got)
+            }
+        }
+    })
+}
+
+func Test_buildAliasTable(t *testing.T) {
+    testCases := [][]float64{
+        {1},
+        {1, 1, 1, 1},
+        {5, 2, 1},
+        {10, 1, 1, 1, 7},
+        {0.1, 2.5, 1},
+    }
+
+    for _, weights := range testCases {
+        prob, alias := buildAliasTable(weights)
+
+        // Add up the probability every column gives to each server.
+        total := 0.0
+        for _, weight := range weights {
+            total += weight
+        }
+        shares := make([]float64, len(weights))
+        for i := range weights {
+            shares[i] += prob[i] / float64(len(weights))
+            shares[alias[i]] += (1 - prob[i]) / float64(len(weights))
+        }
+
+        for i, weight := range weights {
+            expected := weight / total
+            if math.Abs(shares[i]-expected) > 1e-9 {
+                t.Errorf("error alias table of %v: expected share %v for %d, got %v.\n", weights, expected, i, shares[i])
+            }
+        }
+    }
+}
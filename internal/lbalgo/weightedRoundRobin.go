@@ -1,21 +1,41 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "math"
     "net/http"
     "sort"
     "sync"
+    "time"
 )
 
+// WRR is the struct used for Weighted Round Robin.
+// By default, a server receives Weight consecutive requests before the queue rotates.
+// In smooth mode, requests are interleaved nginx-style so that no server gets a burst of its whole Weight.
 type WRR struct {
     sync.RWMutex
     servers []*weightedServer
+    smooth  bool
 }
 
 type weightedServer struct { // We need an extra data structure to keep track of the Count.
-    Addr   string
-    Weight int
-    Count  int
+    Addr          string
+    Weight        int
+    Count         int
+    CurrentWeight float64 // Only used in smooth mode.
+    SlowStart     model.SlowStart
+}
+
+// effectiveWeight returns the weight used for selection, scaled down while the server is slow-starting.
+// A server without weight counts as weight 1.
+func (ws *weightedServer) effectiveWeight(now time.Time) float64 {
+    return float64(max(ws.Weight, 1)) * ws.SlowStart.Factor(now)
+}
+
+// resetCount refills the Count of a server with its effective weight, at least one request.
+func (ws *weightedServer) resetCount(now time.Time) {
+    ws.Count = max(int(math.Round(ws.effectiveWeight(now))), 1)
 }
 
 func (w *WRR) Len() int      { return len(w.servers) }
@@ -24,38 +44,86 @@ func (w *WRR) Less(i, j int) bool {
     return w.servers[i].Weight > w.servers[j].Weight
 }
 
+// NewWRR creates a WRR instance that sends Weight consecutive requests to a server before rotating.
 func NewWRR(backendServers *model.BEServers) *WRR {
+    return newWRR(backendServers, false)
+}
+
+// NewSWRR creates a WRR instance in smooth mode, which interleaves the servers instead of sending Weight consecutive
+// requests to each, e.g. A B A A C A B A for weights (5, 2, 1).
+func NewSWRR(backendServers *model.BEServers) *WRR {
+    return newWRR(backendServers, true)
+}
+
+func newWRR(backendServers *model.BEServers, smooth bool) *WRR {
     servers := make([]*weightedServer, 0)
+    now := time.Now()
     if backendServers != nil {
         for addr, srv := range *backendServers {
             ws := &weightedServer{
-                Addr:   addr,
-                Weight: srv.Weight,
-                Count:  srv.Weight,
+                Addr:      addr,
+                Weight:    srv.Weight,
+                SlowStart: srv.SlowStart,
             }
+            ws.resetCount(now)
             servers = append(servers, ws)
         }
     }
-    wrr := &WRR{servers: servers}
+    wrr := &WRR{servers: servers, smooth: smooth}
     // Sort by weight.
     sort.Sort(wrr)
     return wrr
 }
 
-func (w *WRR) ChooseServer(_ *http.Request) (string, error) {
-    if len(w.servers) != 0 {
-        chosenServer := w.servers[0].Addr
-        w.servers[0].Count--
-        if w.servers[0].Count <= 0 {
-            w.servers[0].Count = w.servers[0].Weight
-            w.rotate()
+// ChooseServer returns the next server in the weighted rotation.
+func (w *WRR) ChooseServer(_ *http.Request) (Selection, error) {
+    w.Lock()
+    defer w.Unlock()
+
+    if len(w.servers) == 0 {
+        return Selection{}, ErrNoServer
+    }
+
+    now := time.Now()
+    if w.smooth {
+        return balancer.NewSelection(w.chooseSmooth(now).Addr, nil), nil
+    }
+
+    chosenServer := w.servers[0].Addr
+    w.servers[0].Count--
+    if w.servers[0].Count <= 0 {
+        w.servers[0].resetCount(now)
+        w.rotate()
+    }
+    return balancer.NewSelection(chosenServer, nil), nil
+}
+
+// chooseSmooth picks the server with the highest CurrentWeight after raising every CurrentWeight by its weight,
+// then lowers the chosen one by the total weight.
+// Every server is chosen exactly effectiveWeight times per cycle, spread as evenly as possible.
+func (w *WRR) chooseSmooth(now time.Time) *weightedServer {
+    var best *weightedServer
+    total := 0.0
+    for _, server := range w.servers {
+        weight := server.effectiveWeight(now)
+        server.CurrentWeight += weight
+        total += weight
+        if best == nil || server.CurrentWeight > best.CurrentWeight {
+            best = server
         }
-        return chosenServer, nil
     }
-    return "", ErrNoServer
+
+    best.CurrentWeight -= total
+    return best
 }
 
+// Renew updates the servers within WRR with the given healthy servers.
 func (w *WRR) Renew(currentHealthyServers model.BEServers) {
+    currentHealthyServers = currentHealthyServers.Accepting()
+    w.Lock()
+    defer w.Unlock()
+
+    now := time.Now()
     // 1. Check down servers.
     for _, server := range w.servers {
         if _, ok := currentHealthyServers[server.Addr]; !ok {
@@ -68,20 +136,28 @@ func (w *WRR) Renew(currentHealthyServers model.BEServers) {
     for addr, server := range currentHealthyServers {
         if srv, exist := w.exists(addr); !exist {
             ws := &weightedServer{
-                Addr:   addr,
-                Weight: server.Weight,
-                Count:  server.Weight,
+                Addr:      addr,
+                Weight:    server.Weight,
+                SlowStart: server.SlowStart,
             }
+            ws.resetCount(now)
             w.push(ws)
         } else {
+            if srv.Weight != server.Weight {
+                // Restart the smooth sequence of a server whose weight changed.
+                srv.CurrentWeight = 0
+            }
             srv.Weight = server.Weight
-            srv.Count = server.Weight
+            srv.SlowStart = server.SlowStart
+            srv.resetCount(now)
         }
     }
 
     sort.Sort(w)
 }
 
+// The helpers below don't lock. Callers must hold the lock of WRR.
+
 func (w *WRR) rotate() *weightedServer {
     head := w.pop()
     if head != nil {
@@ -91,16 +167,10 @@ func (w *WRR) rotate() *weightedServer {
 }
 
 func (w *WRR) push(server *weightedServer) {
-    w.Lock()
-    defer w.Unlock()
-
     w.servers = append(w.servers, server)
 }
 
 func (w *WRR) pop() *weightedServer {
-    w.Lock()
-    defer w.Unlock()
-
     var head *weightedServer
     if len(w.servers) != 0 {
         head = w.servers[0]
@@ -110,8 +180,6 @@ func (w *WRR) pop() *weightedServer {
 }
 
 func (w *WRR) exists(serverAddress string) (*weightedServer, bool) {
-    w.RLock()
-    defer w.RUnlock()
     // TODO: Should use binary search.
     for _, server := range w.servers {
         if serverAddress == server.Addr {
@@ -122,9 +190,6 @@ func (w *WRR) exists(serverAddress string) (*weightedServer, bool) {
 }
 
 func (w *WRR) remove(serverAddress string) {
-    w.Lock()
-    defer w.Unlock()
-
     newServers := make([]*weightedServer, 0)
     for _, server := range w.servers {
         if serverAddress != server.Addr {
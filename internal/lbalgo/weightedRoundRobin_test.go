@@ -1,9 +1,11 @@
 package lbalgo
 
 import (
-    "LoadBalancer/internal/model"
+    "LoadBalancer/pkg/model"
     "net/http"
+    "sync"
     "testing"
+    "time"
 )
 
 func TestWRR_ChooseServer(t *testing.T) {
@@ -46,7 +48,7 @@ func TestWRR_ChooseServer(t *testing.T) {
     for _, tc := range testCases {
         wrr := NewWRR(&bes)
 
-        var chosen string
+        var chosen Selection
         var err error
         emptyReq := new(http.Request)
         for i := 0; i < tc.requests; i++ {
@@ -56,8 +58,8 @@ func TestWRR_ChooseServer(t *testing.T) {
             }
         }
 
-        if chosen != tc.expectedChosen {
-            t.Errorf("error choosing server: expected %s, got %s.\n", tc.expectedChosen, chosen)
+        if chosen.Address != tc.expectedChosen {
+            t.Errorf("error choosing server: expected %s, got %s.\n", tc.expectedChosen, chosen.Address)
         }
     }
 }
@@ -114,3 +116,120 @@ func TestWRR_Renew(t *testing.T) {
         }
     }
 }
+
+func TestSWRR_ChooseServer(t *testing.T) {
+    bes := model.BEServers{
+        "Address A": &model.BEServer{Weight: 5},
+        "Address B": &model.BEServer{Weight: 2},
+        "Address C": &model.BEServer{Weight: 1},
+    }
+
+    expected := []string{
+        "Address A", "Address B", "Address A", "Address A",
+        "Address C", "Address A", "Address B", "Address A",
+    }
+
+    swrr := NewSWRR(&bes)
+    emptyReq := new(http.Request)
+    counts := make(map[string]int)
+    runs := make(map[string]int)
+    var previous string
+    run := 0
+    // Run three full cycles, each cycle should repeat the same sequence.
+    for i := 0; i < 3*len(expected); i++ {
+        chosen, err := swrr.ChooseServer(emptyReq)
+        if err != nil {
+            t.Fatalf("error choosing server: got %#v.\n", err)
+        }
+
+        if chosen.Address != expected[i%len(expected)] {
+            t.Errorf("error choosing server at request %d: expected %s, got %s.\n", i, expected[i%len(expected)], chosen.Address)
+        }
+
+        if chosen.Address == previous {
+            run++
+        } else {
+            run = 1
+        }
+        if run > runs[chosen.Address] {
+            runs[chosen.Address] = run
+        }
+        previous = chosen.Address
+        counts[chosen.Address]++
+    }
+
+    for addr, srv := range bes {
+        if counts[addr] != 3*srv.Weight {
+            t.Errorf("error distributing requests to %s: expected %d, got %d.\n", addr, 3*srv.Weight, counts[addr])
+        }
+        if runs[addr] > srv.Weight {
+            t.Errorf("error burst of %s: longest run %d exceeds weight %d.\n", addr, runs[addr], srv.Weight)
+        }
+    }
+
+    // The heaviest server should be interleaved instead of getting all its requests in a row.
+    if runs["Address A"] > 2 {
+        t.Errorf("error smooth interleaving: expected longest run of Address A to be at most 2, got %d.\n", runs["Address A"])
+    }
+}
+
+func TestWRR_ConcurrentChooseServer(t *testing.T) {
+    bes := model.BEServers{
+        "Address A": &model.BEServer{Weight: 3},
+        "Address B": &model.BEServer{Weight: 1},
+    }
+
+    for _, wrr := range []*WRR{NewWRR(&bes), NewSWRR(&bes)} {
+        const goroutines, requests = 8, 100
+
+        var mu sync.Mutex
+        counts := make(map[string]int)
+        var wg sync.WaitGroup
+        for g := 0; g < goroutines; g++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                emptyReq := new(http.Request)
+                for i := 0; i < requests; i++ {
+                    chosen, err := wrr.ChooseServer(emptyReq)
+                    if err != nil {
+                        t.Errorf("error choosing server: got %#v.\n", err)
+                        return
+                    }
+                    mu.Lock()
+                    counts[chosen.Address]++
+                    mu.Unlock()
+                }
+            }()
+        }
+        wg.Wait()
+
+        // 800 requests are exactly 200 cycles of weight 4.
+        if counts["Address A"] != 600 || counts["Address B"] != 200 {
+            t.Errorf("error distributing requests concurrently: got %#v.\n", counts)
+        }
+    }
+}
+
+func TestSWRR_SlowStart(t *testing.T) {
+    bes := model.BEServers{
+        "Address A": &model.BEServer{Weight: 10},
+        "Address B": &model.BEServer{
+            Weight:    10,
+            SlowStart: model.SlowStart{Since: time.Now(), Window: time.Hour}, // Just came back.
+        },
+    }
+
+    swrr := NewSWRR(&bes)
+    counts := make(map[string]int)
+    emptyReq := new(http.Request)
+    for i := 0; i < 110; i++ {
+        chosen, _ := swrr.ChooseServer(emptyReq)
+        counts[chosen.Address]++
+    }
+
+    // B starts at a tenth of its weight: 10 requests out of 110.
+    if counts["Address B"] != 10 {
+        t.Errorf("error slow-start: expected %d requests on Address B, got %d.\n", 10, counts["Address B"])
+    }
+}
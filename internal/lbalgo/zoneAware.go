@@ -0,0 +1,197 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/balancer"
+    "LoadBalancer/pkg/model"
+    "net/http"
+    "sync"
+)
+
+// Locality levels of a server seen from the load balancer, from the nearest to the farthest.
+const (
+    SameZone = iota
+    SameRegion
+    OtherRegion
+    localityLevels
+)
+
+// DefaultOverflow is the default percentage of clients spilled to the next locality level.
+const DefaultOverflow = 50
+
+// ZoneConfig describes where the load balancer runs and when it spills traffic out of its zone.
+type ZoneConfig struct {
+    Region string
+    Zone   string
+    // MinLocal is the number of healthy servers a locality level needs to carry its traffic alone.
+    MinLocal int
+    // Overflow is the percentage of clients sent to the next level while a level has fewer than MinLocal servers.
+    Overflow int
+}
+
+// ZoneAware wraps one instance of any LBAlgo per locality level.
+// Requests go to the nearest level that has healthy servers. While that level has fewer than MinLocal healthy servers,
+// Overflow percent of the clients spill over to the next level that has any. A client always spills over, or never,
+// so that the algorithms that pin clients keep it on one server.
+type ZoneAware struct {
+    sync.RWMutex
+    config  ZoneConfig
+    levels  [localityLevels]LBAlgo
+    counts  [localityLevels]int
+    levelOf map[string]int // Level of every server, used to hand bindings over to the right algorithm.
+}
+
+// NewZoneAware creates a ZoneAware instance, newAlgo creates the algorithm used within every locality level.
+func NewZoneAware(config ZoneConfig, newAlgo func() LBAlgo, backendServers *model.BEServers) *ZoneAware {
+    z := &ZoneAware{
+        config:  config,
+        levelOf: make(map[string]int),
+    }
+    for level := range z.levels {
+        z.levels[level] = newAlgo()
+    }
+
+    if backendServers != nil {
+        z.Renew(*backendServers)
+    }
+    return z
+}
+
+// locality returns the locality level of srv.
+func (z *ZoneAware) locality(srv *model.BEServer) int {
+    if srv.Region != z.config.Region {
+        return OtherRegion
+    }
+    if srv.Zone != z.config.Zone {
+        return SameRegion
+    }
+    return SameZone
+}
+
+// ChooseServer chooses the locality level of the request, then lets the algorithm of that level choose the server.
+// The Selection of that algorithm is returned as is, so that it hears about the result of the request.
+func (z *ZoneAware) ChooseServer(req *http.Request) (Selection, error) {
+    z.RLock()
+    level := z.chooseLevel(req)
+    z.RUnlock()
+
+    if level < 0 {
+        return Selection{}, ErrNoServer
+    }
+    return z.levels[level].ChooseServer(req)
+}
+
+// Acquire acquires the server at address from the algorithm of its level.
+func (z *ZoneAware) Acquire(address string) Selection {
+    z.RLock()
+    level, ok := z.levelOf[address]
+    z.RUnlock()
+
+    if !ok {
+        return balancer.NewSelection(address, nil)
+    }
+    return balancer.Acquire(z.levels[level], address)
+}
+
+// chooseLevel returns the locality level req goes to, -1 if there's no server at all.
+func (z *ZoneAware) chooseLevel(req *http.Request) int {
+    nearest := z.nextLevel(-1)
+    if nearest < 0 || z.counts[nearest] >= z.config.MinLocal {
+        return nearest
+    }
+
+    // The nearest level is short of servers, spill Overflow percent of the clients over, by hash of their IP.
+    next := z.nextLevel(nearest)
+    if next >= 0 && ihash(getClientIP(req))%100 < z.config.Overflow {
+        return next
+    }
+    return nearest
+}
+
+// nextLevel returns the first level after level with healthy servers, -1 if there's none.
+func (z *ZoneAware) nextLevel(level int) int {
+    for next := level + 1; next < localityLevels; next++ {
+        if z.counts[next] > 0 {
+            return next
+        }
+    }
+    return -1
+}
+
+// Renew splits the healthy servers by locality level and renews the algorithm of every level.
+func (z *ZoneAware) Renew(currentHealthyServers model.BEServers) {
+    var split [localityLevels]model.BEServers
+    for level := range split {
+        split[level] = make(model.BEServers)
+    }
+    levelOf := make(map[string]int, len(currentHealthyServers))
+    for addr, srv := range currentHealthyServers {
+        level := z.locality(srv)
+        split[level][addr] = srv
+        levelOf[addr] = level
+    }
+
+    z.Lock()
+    defer z.Unlock()
+    for level, servers := range split {
+        z.levels[level].Renew(servers)
+        // Draining servers don't carry the traffic of their level.
+        z.counts[level] = len(servers.Accepting())
+    }
+    z.levelOf = levelOf
+}
+
+// Seed seeds the algorithm of every level that makes random choices.
+func (z *ZoneAware) Seed(seed int64) {
+    for level, algo := range z.levels {
+        if seeder, ok := algo.(Seeder); ok {
+            seeder.Seed(seed + int64(level))
+        }
+    }
+}
+
+// StickyStats returns the metrics of the sticky tables of the algorithms of all levels.
+func (z *ZoneAware) StickyStats() StickyStats {
+    var stats StickyStats
+    for _, algo := range z.levels {
+        if reporter, ok := algo.(StickyReporter); ok {
+            stats = stats.Add(reporter.StickyStats())
+        }
+    }
+    return stats
+}
+
+// Bindings returns the client bindings of the algorithms of all levels.
+func (z *ZoneAware) Bindings() Clients {
+    clients := make(Clients)
+    for _, algo := range z.levels {
+        if sticky, ok := algo.(Sticky); ok {
+            for client, server := range sticky.Bindings() {
+                clients[client] = server
+            }
+        }
+    }
+    return clients
+}
+
+// Bind hands every binding over to the algorithm of the level its server belongs to.
+func (z *ZoneAware) Bind(clients Clients) {
+    var split [localityLevels]Clients
+    z.RLock()
+    for client, server := range clients {
+        level, ok := z.levelOf[server]
+        if !ok {
+            continue
+        }
+        if split[level] == nil {
+            split[level] = make(Clients)
+        }
+        split[level][client] = server
+    }
+    z.RUnlock()
+
+    for level, algo := range z.levels {
+        if sticky, ok := algo.(Sticky); ok && split[level] != nil {
+            sticky.Bind(split[level])
+        }
+    }
+}
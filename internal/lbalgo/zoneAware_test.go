@@ -0,0 +1,146 @@
+package lbalgo
+
+import (
+    "LoadBalancer/pkg/model"
+    "errors"
+    "fmt"
+    "net/http"
+    "testing"
+)
+
+func TestZoneAware_ChooseServer(t *testing.T) {
+    config := ZoneConfig{Region: "eu", Zone: "eu-1", MinLocal: 2, Overflow: 25}
+    newRR := func() LBAlgo { return NewRR(nil) }
+    emptyReq := new(http.Request)
+    // Clients spill over by hash of their IP, a thousand of them spread about as Overflow says.
+    clients := 1000
+    tolerance := 5
+
+    local := func() *model.BEServer { return &model.BEServer{Region: "eu", Zone: "eu-1"} }
+    regional := func() *model.BEServer { return &model.BEServer{Region: "eu", Zone: "eu-2"} }
+    remote := func() *model.BEServer { return &model.BEServer{Region: "us", Zone: "us-1"} }
+
+    testCases := []struct {
+        name     string
+        servers  model.BEServers
+        expected map[string]int // Percentage of the clients per server, within tolerance.
+    }{
+        {
+            name: "Enough local servers",
+            servers: model.BEServers{
+                "Local A":    local(),
+                "Local B":    local(),
+                "Regional A": regional(),
+            },
+            expected: map[string]int{"Local A": 50, "Local B": 50},
+        },
+        {
+            name: "Local capacity insufficient",
+            servers: model.BEServers{
+                "Local A":    local(),
+                "Regional A": regional(),
+                "Remote A":   remote(),
+            },
+            expected: map[string]int{"Local A": 75, "Regional A": 25},
+        },
+        {
+            name: "No local servers",
+            servers: model.BEServers{
+                "Regional A": regional(),
+                "Remote A":   remote(),
+            },
+            // The region is the nearest level now, and it's short of servers too.
+            expected: map[string]int{"Regional A": 75, "Remote A": 25},
+        },
+        {
+            name: "Only remote servers",
+            servers: model.BEServers{
+                "Remote A": remote(),
+            },
+            expected: map[string]int{"Remote A": 100},
+        },
+    }
+
+    for _, tc := range testCases {
+        z := NewZoneAware(config, newRR, &tc.servers)
+        counts := make(map[string]int)
+        for i := 0; i < clients; i++ {
+            req := &http.Request{RemoteAddr: fmt.Sprintf("10.0.%d.%d:1234", i/256, i%256)}
+            chosen, err := z.ChooseServer(req)
+            if err != nil {
+                t.Fatalf("%s: error choosing server: got %#v.\n", tc.name, err)
+            }
+            counts[chosen.Address]++
+        }
+
+        if len(counts) != len(tc.expected) {
+            t.Errorf("%s: expected %#v, got %#v.\n", tc.name, tc.expected, counts)
+            continue
+        }
+        for addr, n := range tc.expected {
+            if percent := counts[addr] * 100 / clients; percent < n-tolerance || percent > n+tolerance {
+                t.Errorf("%s: expected %#v, got %#v.\n", tc.name, tc.expected, counts)
+                break
+            }
+        }
+    }
+
+    t.Run("No servers", func(t *testing.T) {
+        z := NewZoneAware(config, newRR, nil)
+        if _, err := z.ChooseServer(emptyReq); !errors.Is(err, ErrNoServer) {
+            t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrNoServer, err)
+        }
+    })
+}
+
+func TestZoneAware_Sticky(t *testing.T) {
+    config := ZoneConfig{Region: "eu", Zone: "eu-1", MinLocal: 2, Overflow: 50}
+    z := NewZoneAware(config, func() LBAlgo { return NewSRR(nil) }, &model.BEServers{
+        "Local A":    &model.BEServer{Region: "eu", Zone: "eu-1"},
+        "Regional A": &model.BEServer{Region: "eu", Zone: "eu-2"},
+        "Regional B": &model.BEServer{Region: "eu", Zone: "eu-2"},
+    })
+
+    // The local level is short of servers, half of the clients spill over, and every client keeps its server.
+    servers := make(map[string]bool)
+    for i := 0; i < 20; i++ {
+        req := &http.Request{RemoteAddr: fmt.Sprintf("10.0.0.%d:1234", i)}
+        first, err := z.ChooseServer(req)
+        if err != nil {
+            t.Fatalf("error choosing server: got %#v.\n", err)
+        }
+        servers[first.Address] = true
+        for j := 0; j < 10; j++ {
+            if chosen, _ := z.ChooseServer(req); chosen.Address != first.Address {
+                t.Fatalf("error choosing server: expected %s for %s, got %s.\n", first.Address, req.RemoteAddr, chosen.Address)
+            }
+        }
+    }
+    if len(servers) != 3 {
+        t.Errorf("error choosing server: expected the clients spread over %d servers, got %v.\n", 3, servers)
+    }
+}
+
+func TestZoneAware_Renew(t *testing.T) {
+    config := ZoneConfig{Region: "eu", Zone: "eu-1", MinLocal: 1}
+    z := NewZoneAware(config, func() LBAlgo { return NewWLC(nil) }, &model.BEServers{
+        "Regional A": &model.BEServer{Region: "eu", Zone: "eu-2"},
+    })
+
+    // A local server comes up, it takes all the traffic back.
+    z.Renew(model.BEServers{
+        "Local A":    &model.BEServer{Region: "eu", Zone: "eu-1"},
+        "Regional A": &model.BEServer{Region: "eu", Zone: "eu-2"},
+    })
+
+    chosen, _ := z.ChooseServer(new(http.Request))
+    if chosen.Address != "Local A" {
+        t.Errorf("error choosing server: expected %s, got %s.\n", "Local A", chosen.Address)
+    }
+
+    // The selection reports back to the algorithm of the level that chose the server.
+    chosen.Done(Result{})
+    if conns := z.levels[SameZone].(*WLC).items["Local A"].Connections; conns != 0 {
+        t.Errorf("error done: expected %d connections, got %d.\n", 0, conns)
+    }
+}
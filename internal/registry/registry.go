@@ -0,0 +1,101 @@
+package registry
+
+import (
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+)
+
+// Registration is a registered backend server, as it's persisted.
+type Registration struct {
+    Address     string `json:"address"`
+    Pool        string `json:"pool,omitempty"` // Name of the pool of the server, the default pool when empty.
+    Weight      int    `json:"weight"`
+    Priority    int    `json:"priority"`
+    Region      string `json:"region,omitempty"`
+    Zone        string `json:"zone,omitempty"`
+    Draining    bool   `json:"draining,omitempty"`
+    Maintenance bool   `json:"maintenance,omitempty"`
+    Lease       string `json:"lease,omitempty"`  // ID of the lease of the registration, empty when it's permanent.
+    TTL         int    `json:"ttl,omitempty"`    // TTL of the lease in seconds.
+    Static      bool   `json:"static,omitempty"` // Static backend of the configuration, only its state is restored.
+}
+
+// Store persists the registrations so that they survive a restart of the load balancer.
+type Store interface {
+    // Load returns the registrations saved last, none when nothing was saved yet.
+    Load() ([]Registration, error)
+    // Save replaces the saved registrations with registrations.
+    Save(registrations []Registration) error
+}
+
+// FileStore keeps the registrations in a JSON file. The file is replaced atomically: a crash leaves either the
+// previous registrations or the new ones, never a mix of both.
+type FileStore struct {
+    Path string
+}
+
+// NewFileStore creates a FileStore that keeps the registrations in the file at path.
+func NewFileStore(path string) *FileStore {
+    return &FileStore{Path: path}
+}
+
+// Load reads the registrations from the file, none when it doesn't exist yet.
+func (f *FileStore) Load() ([]Registration, error) {
+    data, err := os.ReadFile(f.Path)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var registrations []Registration
+    if err = json.Unmarshal(data, &registrations); err != nil {
+        return nil, err
+    }
+    return registrations, nil
+}
+
+// Save writes the registrations to a temporary file synced to disk, then renames it over the file.
+func (f *FileStore) Save(registrations []Registration) error {
+    if registrations == nil {
+        registrations = []Registration{}
+    }
+    data, err := json.MarshalIndent(registrations, "", "  ")
+    if err != nil {
+        return err
+    }
+
+    dir := filepath.Dir(f.Path)
+    tmp, err := os.CreateTemp(dir, filepath.Base(f.Path)+".tmp*")
+    if err != nil {
+        return err
+    }
+    // Removing fails once the file is renamed, which is expected.
+    defer os.Remove(tmp.Name())
+
+    if _, err = tmp.Write(data); err != nil {
+        _ = tmp.Close()
+        return err
+    }
+    if err = tmp.Sync(); err != nil {
+        _ = tmp.Close()
+        return err
+    }
+    if err = tmp.Close(); err != nil {
+        return err
+    }
+    if err = os.Rename(tmp.Name(), f.Path); err != nil {
+        return err
+    }
+
+    // Sync the directory too, so that the rename itself survives a crash.
+    d, err := os.Open(dir)
+    if err != nil {
+        return err
+    }
+    defer d.Close()
+    return d.Sync()
+}
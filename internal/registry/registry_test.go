@@ -0,0 +1,58 @@
+package registry
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func TestFileStore_SaveLoad(t *testing.T) {
+    dir := t.TempDir()
+    store := NewFileStore(filepath.Join(dir, "registry.json"))
+
+    // Nothing saved yet.
+    registrations, err := store.Load()
+    if err != nil || len(registrations) != 0 {
+        t.Fatalf("error loading missing file: expected no registration, got %#v %v.\n", registrations, err)
+    }
+
+    expected := []Registration{
+        {Address: "http://127.0.0.1:1080", Weight: 5, Priority: 1, Zone: "eu-west-1a", Lease: "abc", TTL: 30},
+        {Address: "http://127.0.0.1:1081", Weight: 1, Draining: true, Maintenance: true},
+    }
+    if err = store.Save(expected); err != nil {
+        t.Fatal(err)
+    }
+    if registrations, err = store.Load(); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(registrations, expected) {
+        t.Errorf("error loading registrations: expected %#v, got %#v.\n", expected, registrations)
+    }
+
+    // Saving again replaces the registrations and leaves no temporary file behind.
+    if err = store.Save(nil); err != nil {
+        t.Fatal(err)
+    }
+    if registrations, err = store.Load(); err != nil || len(registrations) != 0 {
+        t.Errorf("error replacing registrations: expected none, got %#v %v.\n", registrations, err)
+    }
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(entries) != 1 {
+        t.Errorf("error saving registrations: expected 1 file, got %d.\n", len(entries))
+    }
+}
+
+func TestFileStore_LoadCorrupted(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "registry.json")
+    if err := os.WriteFile(path, []byte(`[{"address": `), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := NewFileStore(path).Load(); err == nil {
+        t.Errorf("error loading corrupted file: expected an error, got nil.\n")
+    }
+}
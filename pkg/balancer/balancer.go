@@ -0,0 +1,101 @@
+// Package balancer defines the interface of load balancing algorithms and a registry of them.
+//
+// An algorithm implemented outside of this repository registers itself in the init function of its package:
+//
+//	func init() {
+//	    balancer.Register("MYALGO", func(params balancer.Params) (balancer.LBAlgo, error) {
+//	        return NewMyAlgo(), nil
+//	    })
+//	}
+//
+// Importing the package for its side effects in cmd/main.go makes the algorithm available to flag -algo.
+package balancer
+
+import (
+    "LoadBalancer/pkg/model"
+    "errors"
+    "net/http"
+    "time"
+)
+
+var (
+    ErrNoServer    = errors.New("error no available server")
+    ErrUnknownAlgo = errors.New("error unknown algorithm")
+)
+
+// LBAlgo is a load balancing algorithm.
+type LBAlgo interface {
+    // ChooseServer returns the server req should be sent to, ErrNoServer when there's none.
+    ChooseServer(req *http.Request) (Selection, error)
+    // Renew replaces the servers of the algorithm with the servers currently taking traffic.
+    // Draining servers must not be chosen for new clients, see model.BEServers.Accepting. Algorithms that pin clients
+    // keep sending the clients already bound to a draining server to it.
+    Renew(servers model.BEServers)
+}
+
+// Result describes how a request sent to the chosen server went.
+type Result struct {
+    Latency time.Duration // Time from sending the request to reading the whole response.
+    Status  int           // Status code of the response, 0 when there's none.
+    Bytes   int64         // Size of the response body.
+    Err     error         // Error sending the request or reading the response.
+}
+
+// Failed reports whether the request failed, either with an error or a 5xx response.
+func (r Result) Failed() bool {
+    return r.Err != nil || r.Status >= http.StatusInternalServerError
+}
+
+// Selection is the server chosen for a request.
+// The load balancer calls Done exactly once, when the request has completed, so that the algorithm can keep its own
+// state, like connections in flight or latency, up to date.
+type Selection struct {
+    Address string
+    done    func(result Result)
+}
+
+// NewSelection creates a Selection of address. done is called with the result of the request, it can be nil.
+func NewSelection(address string, done func(result Result)) Selection {
+    return Selection{Address: address, done: done}
+}
+
+// Done reports the result of the request to the algorithm that chose the server.
+func (s Selection) Done(result Result) {
+    if s.done != nil {
+        s.done(result)
+    }
+}
+
+// Acquirer is implemented by algorithms that track the load of their servers, like connections in flight or latency.
+// An algorithm that sends a request to a server without asking the algorithm it wraps, e.g. because the client is
+// pinned to it, acquires the server from the wrapped algorithm so that the request still counts.
+type Acquirer interface {
+    // Acquire counts a request on the server at address as if the algorithm had chosen it, until the Selection is
+    // done. A server the algorithm doesn't hold, e.g. a draining one, gets a Selection that counts nothing.
+    Acquire(address string) Selection
+}
+
+// Acquire returns a Selection of address that counts the request on algo when it tracks the load of its servers.
+func Acquire(algo LBAlgo, address string) Selection {
+    if acquirer, ok := algo.(Acquirer); ok {
+        return acquirer.Acquire(address)
+    }
+    return NewSelection(address, nil)
+}
+
+// Seeder is implemented by algorithms that make random choices.
+// Seeding an algorithm with a fixed value makes its sequence of choices reproducible.
+type Seeder interface {
+    Seed(seed int64)
+}
+
+type Clients map[string]string // client-ip: server-ip
+
+// Sticky is implemented by algorithms that pin clients to servers.
+// When the algorithm is swapped at runtime, the bindings of the old algorithm are handed over to the new one.
+type Sticky interface {
+    // Bindings returns a copy of the client bindings.
+    Bindings() Clients
+    // Bind pins the given clients to their servers, bindings to unknown servers are ignored.
+    Bind(clients Clients)
+}
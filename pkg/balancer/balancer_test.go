@@ -0,0 +1,37 @@
+package balancer
+
+import (
+    "errors"
+    "net/http"
+    "testing"
+)
+
+func TestResult_Failed(t *testing.T) {
+    testCases := []struct {
+        result   Result
+        expected bool
+    }{
+        {result: Result{Status: http.StatusOK}},
+        {result: Result{Status: http.StatusNotFound}},
+        {result: Result{Status: http.StatusServiceUnavailable}, expected: true},
+        {result: Result{Err: errors.New("connection refused")}, expected: true},
+    }
+
+    for _, tc := range testCases {
+        if failed := tc.result.Failed(); failed != tc.expected {
+            t.Errorf("error failed: expected %t for %#v, got %t.\n", tc.expected, tc.result, failed)
+        }
+    }
+}
+
+func TestSelection_Done(t *testing.T) {
+    // A selection without callback can be done.
+    NewSelection("Address A", nil).Done(Result{})
+    Selection{}.Done(Result{})
+
+    var got Result
+    NewSelection("Address A", func(result Result) { got = result }).Done(Result{Status: http.StatusOK})
+    if got.Status != http.StatusOK {
+        t.Errorf("error done: expected status %d, got %d.\n", http.StatusOK, got.Status)
+    }
+}
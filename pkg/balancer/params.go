@@ -0,0 +1,99 @@
+package balancer
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+var ErrInvalidParams = errors.New("error invalid parameters")
+
+// Params are the parameters of an algorithm, such as the number of virtual nodes, ε or the decay of an average.
+type Params map[string]string
+
+// ParseParams parses parameters in the form "key=value,key=value". An empty string gives no parameters.
+func ParseParams(s string) (Params, error) {
+    params := make(Params)
+    if strings.TrimSpace(s) == "" {
+        return params, nil
+    }
+
+    for _, pair := range strings.Split(s, ",") {
+        key, value, ok := strings.Cut(pair, "=")
+        key = strings.TrimSpace(key)
+        if !ok || key == "" {
+            return nil, fmt.Errorf("%w: %q isn't in the form key=value", ErrInvalidParams, pair)
+        }
+        params[key] = strings.TrimSpace(value)
+    }
+    return params, nil
+}
+
+// String formats the parameters the way ParseParams reads them.
+func (p Params) String() string {
+    pairs := make([]string, 0, len(p))
+    for key, value := range p {
+        pairs = append(pairs, key+"="+value)
+    }
+    sort.Strings(pairs)
+    return strings.Join(pairs, ",")
+}
+
+// Check returns an error if p holds a parameter that isn't in allowed.
+func (p Params) Check(allowed ...string) error {
+    for key := range p {
+        known := false
+        for _, name := range allowed {
+            if key == name {
+                known = true
+                break
+            }
+        }
+        if !known {
+            return fmt.Errorf("%w: unknown parameter %q", ErrInvalidParams, key)
+        }
+    }
+    return nil
+}
+
+// Int returns the parameter key as an int, def when it's not set.
+func (p Params) Int(key string, def int) (int, error) {
+    value, ok := p[key]
+    if !ok {
+        return def, nil
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil {
+        return 0, fmt.Errorf("%w: %s: %v", ErrInvalidParams, key, err)
+    }
+    return n, nil
+}
+
+// Float returns the parameter key as a float64, def when it's not set.
+func (p Params) Float(key string, def float64) (float64, error) {
+    value, ok := p[key]
+    if !ok {
+        return def, nil
+    }
+    f, err := strconv.ParseFloat(value, 64)
+    if err != nil {
+        return 0, fmt.Errorf("%w: %s: %v", ErrInvalidParams, key, err)
+    }
+    return f, nil
+}
+
+// Duration returns the parameter key as a time.Duration, def when it's not set.
+func (p Params) Duration(key string, def time.Duration) (time.Duration, error) {
+    value, ok := p[key]
+    if !ok {
+        return def, nil
+    }
+    d, err := time.ParseDuration(value)
+    if err != nil {
+        return 0, fmt.Errorf("%w: %s: %v", ErrInvalidParams, key, err)
+    }
+    return d, nil
+}
@@ -0,0 +1,58 @@
+package balancer
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestParseParams(t *testing.T) {
+    testCases := []struct {
+        input    string
+        expected Params
+        err      error
+    }{
+        {input: "", expected: Params{}},
+        {input: "decay=5s", expected: Params{"decay": "5s"}},
+        {input: " vnodes = 100 , epsilon=0.1", expected: Params{"vnodes": "100", "epsilon": "0.1"}},
+        {input: "vnodes", err: ErrInvalidParams},
+        {input: "=100", err: ErrInvalidParams},
+    }
+
+    for _, tc := range testCases {
+        params, err := ParseParams(tc.input)
+        if !errors.Is(err, tc.err) {
+            t.Errorf("%q: expected error %#v, got %#v.\n", tc.input, tc.err, err)
+            continue
+        }
+        if params.String() != tc.expected.String() {
+            t.Errorf("%q: expected %s, got %s.\n", tc.input, tc.expected, params)
+        }
+    }
+}
+
+func TestParams_Values(t *testing.T) {
+    params := Params{"vnodes": "100", "epsilon": "0.1", "decay": "5s", "bad": "x"}
+
+    if n, err := params.Int("vnodes", 10); n != 100 || err != nil {
+        t.Errorf("error reading int: got %d, %v.\n", n, err)
+    }
+    if n, err := params.Int("missing", 10); n != 10 || err != nil {
+        t.Errorf("error reading default int: got %d, %v.\n", n, err)
+    }
+    if f, err := params.Float("epsilon", 0.5); f != 0.1 || err != nil {
+        t.Errorf("error reading float: got %v, %v.\n", f, err)
+    }
+    if d, err := params.Duration("decay", time.Second); d != 5*time.Second || err != nil {
+        t.Errorf("error reading duration: got %v, %v.\n", d, err)
+    }
+    for _, read := range []func() error{
+        func() error { _, err := params.Int("bad", 0); return err },
+        func() error { _, err := params.Float("bad", 0); return err },
+        func() error { _, err := params.Duration("bad", 0); return err },
+    } {
+        if err := read(); !errors.Is(err, ErrInvalidParams) {
+            t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrInvalidParams, err)
+        }
+    }
+}
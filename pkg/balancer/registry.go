@@ -0,0 +1,62 @@
+package balancer
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Factory creates an instance of an algorithm with the given parameters.
+type Factory func(params Params) (LBAlgo, error)
+
+var (
+    registryMu sync.RWMutex
+    registry   = make(map[string]Factory)
+)
+
+// Register makes an algorithm available under name. Names aren't case-sensitive.
+// It panics if factory is nil or if name is already registered.
+func Register(name string, factory Factory) {
+    registryMu.Lock()
+    defer registryMu.Unlock()
+
+    name = strings.ToUpper(name)
+    if factory == nil {
+        panic("balancer: Register factory is nil for " + name)
+    }
+    if _, dup := registry[name]; dup {
+        panic("balancer: Register called twice for " + name)
+    }
+    registry[name] = factory
+}
+
+// New creates an instance of the algorithm registered under name.
+func New(name string, params Params) (LBAlgo, error) {
+    registryMu.RLock()
+    factory, ok := registry[strings.ToUpper(name)]
+    registryMu.RUnlock()
+
+    if !ok {
+        return nil, ErrUnknownAlgo
+    }
+
+    algo, err := factory(params)
+    if err != nil {
+        return nil, fmt.Errorf("algorithm %s: %w", strings.ToUpper(name), err)
+    }
+    return algo, nil
+}
+
+// Algorithms returns the sorted names of the registered algorithms.
+func Algorithms() []string {
+    registryMu.RLock()
+    defer registryMu.RUnlock()
+
+    names := make([]string, 0, len(registry))
+    for name := range registry {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
@@ -0,0 +1,68 @@
+package balancer
+
+import (
+    "LoadBalancer/pkg/model"
+    "errors"
+    "net/http"
+    "testing"
+)
+
+// fixed always chooses the same server.
+type fixed struct {
+    addr string
+}
+
+func (f *fixed) ChooseServer(_ *http.Request) (Selection, error) {
+    return NewSelection(f.addr, nil), nil
+}
+func (f *fixed) Renew(_ model.BEServers) {}
+
+func TestRegister(t *testing.T) {
+    Register("fixed", func(params Params) (LBAlgo, error) {
+        if err := params.Check("addr"); err != nil {
+            return nil, err
+        }
+        return &fixed{addr: params["addr"]}, nil
+    })
+
+    t.Run("New", func(t *testing.T) {
+        algo, err := New("FIXED", Params{"addr": "Address A"})
+        if err != nil {
+            t.Fatalf("error creating algorithm: got %#v.\n", err)
+        }
+        if chosen, _ := algo.ChooseServer(new(http.Request)); chosen.Address != "Address A" {
+            t.Errorf("error choosing server: expected %s, got %s.\n", "Address A", chosen.Address)
+        }
+    })
+
+    t.Run("Invalid params", func(t *testing.T) {
+        if _, err := New("fixed", Params{"vnodes": "100"}); !errors.Is(err, ErrInvalidParams) {
+            t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrInvalidParams, err)
+        }
+    })
+
+    t.Run("Unknown algorithm", func(t *testing.T) {
+        if _, err := New("nope", nil); !errors.Is(err, ErrUnknownAlgo) {
+            t.Errorf("error incorrect error: expected %#v, got %#v.\n", ErrUnknownAlgo, err)
+        }
+    })
+
+    t.Run("Listed", func(t *testing.T) {
+        found := false
+        for _, name := range Algorithms() {
+            found = found || name == "FIXED"
+        }
+        if !found {
+            t.Errorf("error listing algorithms: FIXED missing from %#v.\n", Algorithms())
+        }
+    })
+
+    t.Run("Duplicate", func(t *testing.T) {
+        defer func() {
+            if recover() == nil {
+                t.Errorf("error registering twice: expected a panic.\n")
+            }
+        }()
+        Register("Fixed", func(Params) (LBAlgo, error) { return &fixed{}, nil })
+    })
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Priority tiers of backend servers. A lower value means a higher priority.
+const (
+    PriorityPrimary = iota
+    PrioritySecondary
+    PriorityDisasterRecovery
+)
+
+type BEServers map[string]*BEServer
+type BEServer struct {
+    Address        string
+    Weight         int
+    Priority       int    // Tier of the server, traffic only spills over to a lower tier when the higher ones are unhealthy.
+    Region         string // Locality labels of the server.
+    Zone           string
+    SlowStart      SlowStart // Ramp-up of the server since it last became healthy.
+    Draining       bool      // Draining servers get no new clients, only the requests of their sticky clients.
+    Maintenance    bool      // Servers under maintenance take no traffic and aren't probed until it's cleared.
+    ConnectionTime time.Duration
+    Connections    int
+}
+
+// NewBEServer creates a new instance of BEServer.
+func NewBEServer(address string, weight int) *BEServer {
+    return &BEServer{
+        Address: address,
+        Weight:  weight,
+    }
+}
+
+// Accepting returns the servers that accept new clients, the ones that aren't draining.
+func (s BEServers) Accepting() BEServers {
+    accepting := make(BEServers, len(s))
+    for addr, srv := range s {
+        if !srv.Draining {
+            accepting[addr] = srv
+        }
+    }
+    return accepting
+}
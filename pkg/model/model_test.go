@@ -0,0 +1,15 @@
+package model
+
+import "testing"
+
+func TestBEServers_Accepting(t *testing.T) {
+    servers := BEServers{
+        "Address A": &BEServer{Address: "Address A"},
+        "Address B": &BEServer{Address: "Address B", Draining: true},
+    }
+
+    accepting := servers.Accepting()
+    if _, ok := accepting["Address A"]; !ok || len(accepting) != 1 {
+        t.Errorf("error filtering servers: expected only %s, got %#v.\n", "Address A", accepting)
+    }
+}
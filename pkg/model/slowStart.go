@@ -0,0 +1,54 @@
+package model
+
+import (
+    "math"
+    "time"
+)
+
+// Slow-start ramp modes.
+const (
+    SlowStartLinear      = "linear"
+    SlowStartExponential = "exponential"
+)
+
+// SlowStartMinFactor is the share of its weight a server gets right after it becomes healthy.
+const SlowStartMinFactor = 0.1
+
+// SlowStart describes the ramp-up of a server that just became healthy.
+// During Window after Since, its effective weight grows from SlowStartMinFactor of its weight up to the full weight.
+// The zero value means no ramp-up.
+type SlowStart struct {
+    Since  time.Time
+    Window time.Duration
+    Mode   string
+}
+
+// Factor returns the share of its weight, between SlowStartMinFactor and 1, the server should get at now.
+func (s SlowStart) Factor(now time.Time) float64 {
+    if s.Window <= 0 || s.Since.IsZero() {
+        return 1
+    }
+
+    elapsed := now.Sub(s.Since)
+    if elapsed >= s.Window {
+        return 1
+    }
+    if elapsed < 0 {
+        elapsed = 0
+    }
+
+    progress := float64(elapsed) / float64(s.Window)
+    if s.Mode == SlowStartExponential {
+        // Grow by the same ratio every instant: 0.1, 0.1^0.5, ... 1.
+        return math.Pow(SlowStartMinFactor, 1-progress)
+    }
+    return SlowStartMinFactor + (1-SlowStartMinFactor)*progress
+}
+
+// Ends returns when the ramp-up is over.
+func (s SlowStart) Ends() time.Time {
+    if s.Window <= 0 {
+        return s.Since
+    }
+    return s.Since.Add(s.Window)
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+    "math"
+    "testing"
+    "time"
+)
+
+func TestSlowStart_Factor(t *testing.T) {
+    since := time.Now()
+    window := 10 * time.Second
+
+    testCases := []struct {
+        slowStart SlowStart
+        elapsed   time.Duration
+        expected  float64
+    }{
+        {slowStart: SlowStart{}, elapsed: 0, expected: 1},
+        {slowStart: SlowStart{Since: since}, elapsed: 0, expected: 1},
+        {slowStart: SlowStart{Since: since, Window: window}, elapsed: 0, expected: SlowStartMinFactor},
+        {slowStart: SlowStart{Since: since, Window: window}, elapsed: 5 * time.Second, expected: 0.55},
+        {slowStart: SlowStart{Since: since, Window: window}, elapsed: window, expected: 1},
+        {slowStart: SlowStart{Since: since, Window: window, Mode: SlowStartExponential}, elapsed: 0, expected: SlowStartMinFactor},
+        {slowStart: SlowStart{Since: since, Window: window, Mode: SlowStartExponential}, elapsed: 5 * time.Second, expected: math.Sqrt(SlowStartMinFactor)},
+        {slowStart: SlowStart{Since: since, Window: window, Mode: SlowStartExponential}, elapsed: 20 * time.Second, expected: 1},
+    }
+
+    for _, tc := range testCases {
+        factor := tc.slowStart.Factor(since.Add(tc.elapsed))
+        if math.Abs(factor-tc.expected) > 1e-9 {
+            t.Errorf("error slow-start factor after %v: expected %v, got %v.\n", tc.elapsed, tc.expected, factor)
+        }
+    }
+}